@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"simulator/pkg/abtest"
+	"simulator/pkg/schema"
+)
+
+type abVariant struct {
+	ScenarioID string           `json:"scenario_id,omitempty"`
+	Scenario   *schema.Scenario `json:"scenario,omitempty"`
+}
+
+type abTestRequest struct {
+	A            abVariant `json:"a"`
+	B            abVariant `json:"b"`
+	Replications int       `json:"replications,omitempty"`
+	Seed         int64     `json:"seed,omitempty"`
+	Metrics      []string  `json:"metrics,omitempty"`
+	Limit        int       `json:"limit,omitempty"`
+}
+
+// handleABTest runs two variants with common random numbers and returns
+// paired metric deltas with confidence intervals.
+func handleABTest(w http.ResponseWriter, r *http.Request) {
+	var req abTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	a, ok := resolveScenario(w, runRequest{ScenarioID: req.A.ScenarioID, Scenario: req.A.Scenario})
+	if !ok {
+		return
+	}
+	b, ok := resolveScenario(w, runRequest{ScenarioID: req.B.ScenarioID, Scenario: req.B.Scenario})
+	if !ok {
+		return
+	}
+	res, err := abtest.Compare(abtest.Spec{
+		A:            a,
+		B:            b,
+		Replications: req.Replications,
+		Seed:         req.Seed,
+		Metrics:      req.Metrics,
+		Limit:        req.Limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"simulator/pkg/compare"
+)
+
+type compareRequest struct {
+	RunIDs     []string            `json:"run_ids"`
+	Baseline   string              `json:"baseline,omitempty"` // default the first run
+	Thresholds []compare.Threshold `json:"thresholds,omitempty"`
+}
+
+// handleCompare diffs stored serving runs against a baseline run.
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+	var req compareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if len(req.RunIDs) < 2 {
+		writeError(w, http.StatusBadRequest, "at least two run_ids are required")
+		return
+	}
+	baseline := -1
+	runs := make([]compare.Run, 0, len(req.RunIDs))
+	for i, id := range req.RunIDs {
+		rec, ok := rnStore.get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "run not found: "+id)
+			return
+		}
+		if rec.scenario.IsTraining() {
+			writeError(w, http.StatusBadRequest, "comparisons support serving runs only")
+			return
+		}
+		if id == req.Baseline || (req.Baseline == "" && i == 0) {
+			baseline = i
+		}
+		runs = append(runs, compareRun(rec))
+	}
+	if baseline < 0 {
+		writeError(w, http.StatusBadRequest, "baseline must be one of run_ids")
+		return
+	}
+	res, err := compare.Compare(runs, baseline, req.Thresholds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// compareRun takes stage aggregates from the sketches when the breakdown
+// only kept a sample of the requests.
+func compareRun(rec runRecord) compare.Run {
+	run := compare.Run{
+		ID:       rec.result.RunID,
+		Scenario: rec.scenario,
+		Summary:  rec.result.Summary,
+		Stages:   rec.breakdown.StageAggregates,
+	}
+	if agg := rec.result.Aggregate; agg != nil && agg.RetainedRequests < agg.Requests {
+		run.Stages = compare.StagesFromReport(*agg)
+	}
+	return run
+}
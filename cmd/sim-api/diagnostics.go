@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"simulator/pkg/diagnose"
+)
+
+// handleRunDiagnostics diagnoses a run from its retained requests, or from
+// its trace when none were retained (training runs, or long runs with
+// retain_every < 0). Runs that kept or traced only every n-th request
+// weigh each span n times, so occupancy reflects the whole load.
+func handleRunDiagnostics(w http.ResponseWriter, r *http.Request) {
+	rec, ok := rnStore.get(chi.URLParam(r, "id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	opts, ok := diagnoseOptions(w, r.URL.Query())
+	if !ok {
+		return
+	}
+	target := rec.scenario.Target
+	spans := diagnose.FromRequests(rec.breakdown.Requests)
+	opts.GPUSlots = diagnose.GPUSlots(target)
+	opts.SampleEvery = rec.options.RetainEvery
+	if len(spans) == 0 {
+		var err error
+		if spans, err = diagnose.FromTrace(rec.trace); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		// sharded stages appear once per device in the trace; a training
+		// trace is one replica's step loop
+		opts.GPUSlots = target.Concurrency * target.Devices()
+		opts.SampleEvery = rec.options.TraceEvery
+		if rec.scenario.IsTraining() {
+			opts.GPUSlots = 1
+		}
+	}
+	rep, err := diagnose.Analyze(spans, opts)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "run kept no requests or trace spans to diagnose")
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+func handleRealTraceDiagnostics(w http.ResponseWriter, r *http.Request) {
+	tracePath, _ := realTracePaths(chi.URLParam(r, "id"))
+	data, err := loadFile(tracePath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "trace not found")
+		return
+	}
+	opts, ok := diagnoseOptions(w, r.URL.Query())
+	if !ok {
+		return
+	}
+	spans, err := diagnose.FromTrace(data)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rep, err := diagnose.Analyze(spans, opts)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// diagnoseOptions reads the optional bins and min_window_ms parameters.
+func diagnoseOptions(w http.ResponseWriter, q url.Values) (diagnose.Options, bool) {
+	var opts diagnose.Options
+	if s := q.Get("bins"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 10000 {
+			writeError(w, http.StatusBadRequest, "bins must be between 1 and 10000")
+			return opts, false
+		}
+		opts.Bins = n
+	}
+	if s := q.Get("min_window_ms"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 {
+			writeError(w, http.StatusBadRequest, "min_window_ms must be a non-negative number")
+			return opts, false
+		}
+		opts.MinWindowMS = f
+	}
+	return opts, true
+}
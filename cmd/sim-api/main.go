@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 
+	"simulator/pkg/analytic"
 	"simulator/pkg/nsys"
 	"simulator/pkg/schema"
 	"simulator/pkg/sim"
@@ -29,6 +30,8 @@ type runStore struct {
 }
 
 type runRecord struct {
+	scenario  schema.Scenario
+	options   sim.Options
 	result    schema.RunResult
 	trace     []byte
 	breakdown schema.Breakdown
@@ -37,6 +40,7 @@ type runRecord struct {
 type runRequest struct {
 	ScenarioID string           `json:"scenario_id,omitempty"`
 	Scenario   *schema.Scenario `json:"scenario,omitempty"`
+	Options    *sim.Options     `json:"options,omitempty"`
 }
 
 var (
@@ -58,9 +62,21 @@ func main() {
 	r.Get("/v1/runs/{id}", handleGetRun)
 	r.Get("/v1/runs/{id}/trace", handleGetTrace)
 	r.Get("/v1/runs/{id}/breakdown", handleGetBreakdown)
+	r.Get("/v1/runs/{id}/requests", handleListRequests)
+	r.Get("/v1/runs/{id}/requests/{rid}", handleGetRequest)
+	r.Get("/v1/runs/{id}/diagnostics", handleRunDiagnostics)
+	r.Post("/v1/estimate", handleEstimate)
+	r.Post("/v1/sweeps", handleCreateSweep)
+	r.Get("/v1/sweeps/{id}", handleGetSweep)
+	r.Post("/v1/plan", handlePlan)
+	r.Post("/v1/optimize", handleOptimize)
+	r.Post("/v1/sensitivity", handleSensitivity)
+	r.Post("/v1/abtests", handleABTest)
+	r.Post("/v1/compare", handleCompare)
 	r.Post("/v1/realtraces", handleUploadRealTrace)
 	r.Get("/v1/realtraces/{id}/trace", handleGetRealTrace)
 	r.Get("/v1/realtraces/{id}/metrics", handleGetRealMetrics)
+	r.Get("/v1/realtraces/{id}/diagnostics", handleRealTraceDiagnostics)
 
 	addr := ":8080"
 	if v := os.Getenv("PORT"); v != "" {
@@ -112,31 +128,38 @@ func handleCreateRun(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid JSON")
 		return
 	}
-	var sc schema.Scenario
-	var ok bool
-	if req.Scenario != nil {
-		sc = *req.Scenario
-	} else if req.ScenarioID != "" {
-		sc, ok = scStore.get(req.ScenarioID)
-		if !ok {
-			writeError(w, http.StatusNotFound, "scenario not found")
-			return
-		}
-	} else {
-		writeError(w, http.StatusBadRequest, "scenario_id or scenario required")
-		return
-	}
-	if err := schema.ValidateScenario(sc); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	sc, ok := resolveScenario(w, req)
+	if !ok {
 		return
 	}
 
 	runID := newID("run")
 	seed := hashToInt(runID)
 
-	results, tr := sim.Run(sc, seed)
+	if sc.IsTraining() {
+		createTrainingRun(w, runID, req.ScenarioID, sc, seed)
+		return
+	}
+
+	var opts sim.Options
+	if req.Options != nil {
+		opts = *req.Options
+	}
+	out := sim.RunWithOptions(sc, seed, opts)
+	results, tr := out.Results, out.Trace
 	summary := sim.Summarize(results, sc.Workload.Duration, sc.Target)
+	var aggregate *schema.AggregateReport
+	if out.Aggregate != nil {
+		// sketches see every request, retained results only a sample
+		summary = out.Aggregate.Summary(sc.Workload.Duration)
+		rep := out.Aggregate.Report()
+		aggregate = &rep
+	}
 	breakdown := sim.Breakdown(results)
+	var metadata map[string]string
+	if opts.RetainEvery == 0 {
+		metadata = sim.CheckLaws(sc, seed, results)
+	}
 
 	traceBytes, err := tr.Marshal()
 	if err != nil {
@@ -145,11 +168,15 @@ func handleCreateRun(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rec := runRecord{
+		scenario: sc,
+		options:  opts,
 		result: schema.RunResult{
 			RunID:      runID,
 			ScenarioID: req.ScenarioID,
 			Summary:    summary,
 			TracePath:  "/v1/runs/" + runID + "/trace",
+			Metadata:   metadata,
+			Aggregate:  aggregate,
 		},
 		trace:     traceBytes,
 		breakdown: breakdown,
@@ -162,6 +189,81 @@ func handleCreateRun(w http.ResponseWriter, r *http.Request) {
 		"run_id":    runID,
 		"summary":   summary,
 		"breakdown": breakdown,
+		"estimate":  analytic.Analyze(sc),
+		"metadata":  rec.result.Metadata,
+		"aggregate": aggregate,
+		"artifacts": map[string]string{"trace": rec.result.TracePath},
+	})
+}
+
+// resolveScenario returns the inline or stored scenario of a request after
+// validation, writing the error response itself when it fails.
+func resolveScenario(w http.ResponseWriter, req runRequest) (schema.Scenario, bool) {
+	var sc schema.Scenario
+	if req.Scenario != nil {
+		sc = *req.Scenario
+	} else if req.ScenarioID != "" {
+		var ok bool
+		sc, ok = scStore.get(req.ScenarioID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "scenario not found")
+			return sc, false
+		}
+	} else {
+		writeError(w, http.StatusBadRequest, "scenario_id or scenario required")
+		return sc, false
+	}
+	if err := schema.ValidateScenario(sc); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return sc, false
+	}
+	return sc, true
+}
+
+// handleEstimate returns the analytic queueing estimate for a serving
+// scenario without running the simulator.
+func handleEstimate(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	sc, ok := resolveScenario(w, req)
+	if !ok {
+		return
+	}
+	if sc.IsTraining() {
+		writeError(w, http.StatusBadRequest, "estimates are only available for serving scenarios")
+		return
+	}
+	writeJSON(w, http.StatusOK, analytic.Analyze(sc))
+}
+
+func createTrainingRun(w http.ResponseWriter, runID, scenarioID string, sc schema.Scenario, seed int64) {
+	training, tr := sim.RunTraining(sc, seed)
+	traceBytes, err := tr.Marshal()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to marshal trace")
+		return
+	}
+	rec := runRecord{
+		scenario: sc,
+		result: schema.RunResult{
+			RunID:      runID,
+			ScenarioID: scenarioID,
+			Summary:    schema.Summary{DurationS: training.DurationS},
+			TracePath:  "/v1/runs/" + runID + "/trace",
+			Training:   &training,
+		},
+		trace: traceBytes,
+	}
+	rnStore.mu.Lock()
+	rnStore.runs[runID] = rec
+	rnStore.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"run_id":    runID,
+		"training":  training,
 		"artifacts": map[string]string{"trace": rec.result.TracePath},
 	})
 }
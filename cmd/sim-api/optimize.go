@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"simulator/pkg/optimize"
+	"simulator/pkg/schema"
+	"simulator/pkg/sweep"
+)
+
+type optimizeRequest struct {
+	ScenarioID   string               `json:"scenario_id,omitempty"`
+	Scenario     *schema.Scenario     `json:"scenario,omitempty"`
+	Candidates   []schema.GPUProfile  `json:"candidates,omitempty"`
+	Knobs        []sweep.Axis         `json:"knobs,omitempty"`
+	Objectives   []optimize.Objective `json:"objectives,omitempty"`
+	Budget       int                  `json:"budget,omitempty"`
+	Replications int                  `json:"replications,omitempty"`
+	Seed         int64                `json:"seed,omitempty"`
+}
+
+// handleOptimize returns the Pareto frontier over candidate GPUs and knobs.
+// The search runs inline, bounded by the run budget.
+func handleOptimize(w http.ResponseWriter, r *http.Request) {
+	var req optimizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	sc, ok := resolveScenario(w, runRequest{ScenarioID: req.ScenarioID, Scenario: req.Scenario})
+	if !ok {
+		return
+	}
+	if sc.IsTraining() {
+		writeError(w, http.StatusBadRequest, "optimization supports serving scenarios only")
+		return
+	}
+	res, err := optimize.Run(optimize.Spec{
+		Scenario:     sc,
+		Candidates:   req.Candidates,
+		Knobs:        req.Knobs,
+		Objectives:   req.Objectives,
+		Budget:       req.Budget,
+		Replications: req.Replications,
+		Seed:         req.Seed,
+		Workers:      maxSweepWorkers,
+		Slots:        sweepSlots,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"simulator/pkg/capacity"
+	"simulator/pkg/schema"
+)
+
+type planRequest struct {
+	ScenarioID string           `json:"scenario_id,omitempty"`
+	Scenario   *schema.Scenario `json:"scenario,omitempty"`
+	SLO        capacity.SLO     `json:"slo"`
+	Options    capacity.Options `json:"options,omitempty"`
+	TargetRPS  float64          `json:"target_rps,omitempty"`
+}
+
+// planOutcome is one search; Error is set when the SLO cannot be met within
+// the search range, and Result still carries the evaluations.
+type planOutcome struct {
+	capacity.Result
+	Error string `json:"error,omitempty"`
+}
+
+func newPlanOutcome(res capacity.Result, err error) *planOutcome {
+	out := &planOutcome{Result: res}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return out
+}
+
+// handlePlan reports the maximum RPS that meets the SLO and, when a target
+// RPS is given, the fewest replicas and the lowest concurrency that meet it.
+func handlePlan(w http.ResponseWriter, r *http.Request) {
+	var req planRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	sc, ok := resolveScenario(w, runRequest{ScenarioID: req.ScenarioID, Scenario: req.Scenario})
+	if !ok {
+		return
+	}
+	if sc.IsTraining() {
+		writeError(w, http.StatusBadRequest, "capacity planning supports serving scenarios only")
+		return
+	}
+	if req.TargetRPS < 0 {
+		writeError(w, http.StatusBadRequest, "target_rps must be >= 0")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"slo":     req.SLO,
+		"max_rps": newPlanOutcome(capacity.MaxRPS(sc, req.SLO, req.Options)),
+	}
+	if req.TargetRPS > 0 {
+		resp["target_rps"] = req.TargetRPS
+		resp["replicas"] = newPlanOutcome(capacity.MinReplicas(sc, req.TargetRPS, req.SLO, req.Options))
+		resp["concurrency"] = newPlanOutcome(capacity.MinConcurrency(sc, req.TargetRPS, req.SLO, req.Options))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"simulator/pkg/query"
+)
+
+func handleListRequests(w http.ResponseWriter, r *http.Request) {
+	rec, ok := rnStore.get(chi.URLParam(r, "id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	spec, err := query.Parse(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, query.Select(rec.breakdown.Requests, spec))
+}
+
+func handleGetRequest(w http.ResponseWriter, r *http.Request) {
+	rec, ok := rnStore.get(chi.URLParam(r, "id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	rid, err := strconv.Atoi(chi.URLParam(r, "rid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "request id must be an integer")
+		return
+	}
+	req, ok := query.Find(rec.breakdown.Requests, rid)
+	if !ok {
+		writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, query.Detail{RequestBreakdown: req, CriticalPath: query.Critical(req)})
+}
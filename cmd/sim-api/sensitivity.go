@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sensitivity"
+)
+
+type sensitivityRequest struct {
+	ScenarioID   string              `json:"scenario_id,omitempty"`
+	Scenario     *schema.Scenario    `json:"scenario,omitempty"`
+	Params       []sensitivity.Param `json:"params,omitempty"`
+	Method       sensitivity.Method  `json:"method,omitempty"`
+	Metric       string              `json:"metric,omitempty"` // shorthand for a single metric
+	Metrics      []string            `json:"metrics,omitempty"`
+	Trajectories int                 `json:"trajectories,omitempty"`
+	Levels       int                 `json:"levels,omitempty"`
+	Seed         int64               `json:"seed,omitempty"`
+}
+
+// handleSensitivity ranks scenario parameters by their effect on summary
+// metrics and returns one table per metric, most influential first.
+func handleSensitivity(w http.ResponseWriter, r *http.Request) {
+	var req sensitivityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	sc, ok := resolveScenario(w, runRequest{ScenarioID: req.ScenarioID, Scenario: req.Scenario})
+	if !ok {
+		return
+	}
+	if sc.IsTraining() {
+		writeError(w, http.StatusBadRequest, "sensitivity analysis supports serving scenarios only")
+		return
+	}
+	metrics := req.Metrics
+	if req.Metric != "" {
+		metrics = append([]string{req.Metric}, metrics...)
+	}
+	res, err := sensitivity.Analyze(sensitivity.Spec{
+		Scenario:     sc,
+		Params:       req.Params,
+		Method:       req.Method,
+		Metrics:      metrics,
+		Trajectories: req.Trajectories,
+		Levels:       req.Levels,
+		Seed:         req.Seed,
+		Workers:      maxSweepWorkers,
+		Slots:        sweepSlots,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
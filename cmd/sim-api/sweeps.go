@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sweep"
+)
+
+const maxSweepWorkers = 16
+
+// sweepSlots caps the points simulated at once across all sweeps,
+// optimizations and sensitivity analyses, so concurrent requests share
+// maxSweepWorkers instead of each starting that many.
+var sweepSlots = make(chan struct{}, maxSweepWorkers)
+
+// sweepSaveInterval spaces out progress writes, so a long sweep rewrites its
+// record a bounded number of times instead of once per point.
+const sweepSaveInterval = 2 * time.Second
+
+type sweepRequest struct {
+	ScenarioID string           `json:"scenario_id,omitempty"`
+	Scenario   *schema.Scenario `json:"scenario,omitempty"`
+	Axes       []sweep.Axis     `json:"axes"`
+	Mode       sweep.Mode       `json:"mode,omitempty"`
+	Samples    int              `json:"samples,omitempty"`
+	Seed       int64            `json:"seed,omitempty"`
+	Workers    int              `json:"workers,omitempty"`
+}
+
+// sweepRecord is the persisted state of a sweep; Results is ordered by point
+// index and filled in as points finish.
+type sweepRecord struct {
+	SweepID    string         `json:"sweep_id"`
+	ScenarioID string         `json:"scenario_id,omitempty"`
+	Status     string         `json:"status"` // running, done, interrupted
+	Mode       sweep.Mode     `json:"mode"`
+	Axes       []sweep.Axis   `json:"axes"`
+	Total      int            `json:"total"`
+	Completed  int            `json:"completed"`
+	Failed     int            `json:"failed"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Results    []sweep.Result `json:"results"`
+}
+
+type sweepStore struct {
+	mu     sync.RWMutex
+	sweeps map[string]*sweepRecord
+}
+
+var swStore = sweepStore{sweeps: map[string]*sweepRecord{}}
+
+func handleCreateSweep(w http.ResponseWriter, r *http.Request) {
+	var req sweepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	sc, ok := resolveScenario(w, runRequest{ScenarioID: req.ScenarioID, Scenario: req.Scenario})
+	if !ok {
+		return
+	}
+	if sc.IsTraining() {
+		writeError(w, http.StatusBadRequest, "sweeps support serving scenarios only")
+		return
+	}
+	spec := sweep.Spec{
+		Scenario: sc,
+		Axes:     req.Axes,
+		Mode:     req.Mode,
+		Samples:  req.Samples,
+		Seed:     req.Seed,
+		Workers:  req.Workers,
+		Slots:    sweepSlots,
+	}
+	if spec.Mode == "" {
+		spec.Mode = sweep.ModeGrid
+	}
+	if spec.Workers > maxSweepWorkers {
+		spec.Workers = maxSweepWorkers
+	}
+	points, err := sweep.Points(spec)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := newID("sweep")
+	rec := &sweepRecord{
+		SweepID:    id,
+		ScenarioID: req.ScenarioID,
+		Status:     "running",
+		Mode:       spec.Mode,
+		Axes:       spec.Axes,
+		Total:      len(points),
+		CreatedAt:  time.Now().UTC(),
+		Results:    make([]sweep.Result, len(points)),
+	}
+	for i, p := range points {
+		rec.Results[i] = sweep.Result{Point: p}
+	}
+	swStore.mu.Lock()
+	swStore.sweeps[id] = rec
+	swStore.mu.Unlock()
+	saveSweep(rec)
+
+	go func() {
+		stop := make(chan struct{})
+		saved := make(chan struct{})
+		go func() {
+			persistSweep(rec, stop)
+			close(saved)
+		}()
+		sweep.Run(spec, points, hashToInt(id), func(res sweep.Result) {
+			swStore.mu.Lock()
+			defer swStore.mu.Unlock()
+			rec.Results[res.Index] = res
+			rec.Completed++
+			if res.Error != "" {
+				rec.Failed++
+			}
+			if rec.Completed == rec.Total {
+				rec.Status = "done"
+			}
+		})
+		close(stop)
+		<-saved
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"sweep_id": id,
+		"total":    len(points),
+		"status":   rec.Status,
+		"results":  "/v1/sweeps/" + id,
+	})
+}
+
+func handleGetSweep(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	swStore.mu.RLock()
+	rec, ok := swStore.sweeps[id]
+	var data []byte
+	var err error
+	if ok {
+		data, err = json.Marshal(rec)
+	}
+	swStore.mu.RUnlock()
+	if !ok {
+		data, err = loadStaleSweep(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "sweep not found")
+			return
+		}
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to marshal sweep")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func sweepPath(id string) string {
+	return artifactPath("sweeps", id+".json")
+}
+
+// loadStaleSweep serves a sweep from an earlier process from its last
+// persisted state. A sweep still marked running there was cut short by a
+// crash or restart, so it is reported as interrupted, with the points that
+// finished.
+func loadStaleSweep(id string) ([]byte, error) {
+	data, err := loadFile(sweepPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var rec sweepRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	if rec.Status != "running" {
+		return data, nil
+	}
+	rec.Status = "interrupted"
+	return json.Marshal(&rec)
+}
+
+// persistSweep saves the sweep's progress every sweepSaveInterval while
+// points finish, and once more when stop is closed. Writes happen outside
+// swStore.mu, so workers and readers never wait on the disk.
+func persistSweep(rec *sweepRecord, stop <-chan struct{}) {
+	tick := time.NewTicker(sweepSaveInterval)
+	defer tick.Stop()
+	saved := 0
+	for {
+		select {
+		case <-tick.C:
+			swStore.mu.RLock()
+			completed := rec.Completed
+			swStore.mu.RUnlock()
+			if completed != saved {
+				saveSweep(rec)
+				saved = completed
+			}
+		case <-stop:
+			saveSweep(rec)
+			return
+		}
+	}
+}
+
+// saveSweep writes the sweep's progress atomically. It snapshots the record
+// under swStore.mu and writes without holding it; callers must not hold it.
+func saveSweep(rec *sweepRecord) {
+	swStore.mu.RLock()
+	data, err := json.Marshal(rec)
+	swStore.mu.RUnlock()
+	if err == nil {
+		path := sweepPath(rec.SweepID)
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			tmp := path + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o644); err == nil {
+				err = os.Rename(tmp, path)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("sweep %s: saving progress: %v", rec.SweepID, err)
+	}
+}
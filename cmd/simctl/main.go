@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"simulator/pkg/capacity"
+	"simulator/pkg/compare"
+	"simulator/pkg/diagnose"
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		return
+	}
+	switch os.Args[1] {
+	case "plan":
+		planCmd := flag.NewFlagSet("plan", flag.ExitOnError)
+		scenarioPath := planCmd.String("scenario", "", "path to scenario JSON")
+		p99 := planCmd.Float64("p99", 0, "p99 latency SLO in ms")
+		p50 := planCmd.Float64("p50", 0, "p50 latency SLO in ms")
+		queue := planCmd.Float64("queue", 0, "average queue wait SLO in ms")
+		drops := planCmd.Float64("max-drop", 0, "maximum dropped requests in percent")
+		target := planCmd.Float64("target-rps", 0, "also size replicas and concurrency for this RPS")
+		reps := planCmd.Int("replications", 3, "seeds per load level")
+		maxRPS := planCmd.Float64("max-rps", 0, "search ceiling (default from the analytic capacity)")
+		jsonOut := planCmd.Bool("json", false, "print results as JSON")
+		_ = planCmd.Parse(os.Args[2:])
+		if *scenarioPath == "" {
+			log.Fatalf("--scenario is required")
+		}
+		sc, err := loadScenario(*scenarioPath)
+		if err != nil {
+			log.Fatalf("load scenario: %v", err)
+		}
+		slo := capacity.SLO{P99MS: *p99, P50MS: *p50, AvgQueueMS: *queue, MaxDropPct: *drops}
+		opts := capacity.Options{Replications: *reps, MaxRPS: *maxRPS}
+		runPlan(sc, slo, opts, *target, *jsonOut)
+	case "diagnose":
+		diagCmd := flag.NewFlagSet("diagnose", flag.ExitOnError)
+		scenarioPath := diagCmd.String("scenario", "", "simulate this scenario JSON and diagnose the run")
+		tracePath := diagCmd.String("trace", "", "diagnose a Chrome trace JSON (e.g. a real trace from nsys)")
+		seed := diagCmd.Int64("seed", 1, "run seed for --scenario")
+		jsonOut := diagCmd.Bool("json", false, "print the report as JSON")
+		_ = diagCmd.Parse(os.Args[2:])
+		if (*scenarioPath == "") == (*tracePath == "") {
+			log.Fatalf("exactly one of --scenario or --trace is required")
+		}
+		rep, err := runDiagnose(*scenarioPath, *tracePath, *seed)
+		if err != nil {
+			log.Fatalf("diagnose: %v", err)
+		}
+		printDiagnosis(rep, *jsonOut)
+	case "compare":
+		cmpCmd := flag.NewFlagSet("compare", flag.ExitOnError)
+		basePath := cmpCmd.String("baseline", "", "baseline scenario JSON")
+		var candidates stringList
+		cmpCmd.Var(&candidates, "candidate", "candidate scenario JSON (repeatable)")
+		var thresholds thresholdList
+		cmpCmd.Var(&thresholds, "threshold", "metric=+pct, metric=-pct or metric=pct (repeatable; default p99_ms=+10 and throughput_rps=-10)")
+		seed := cmpCmd.Int64("seed", 1, "seed shared by every run")
+		jsonOut := cmpCmd.Bool("json", false, "print the comparison as JSON")
+		_ = cmpCmd.Parse(os.Args[2:])
+		if *basePath == "" || len(candidates) == 0 {
+			log.Fatalf("--baseline and at least one --candidate are required")
+		}
+		res, err := runCompare(append([]string{*basePath}, candidates...), *seed, thresholds)
+		if err != nil {
+			log.Fatalf("compare: %v", err)
+		}
+		printComparison(res, *jsonOut)
+		if res.Verdict.Regressed {
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Println("simctl commands:")
+	fmt.Println("  plan --scenario <path> [--p99 ms] [--p50 ms] [--queue ms] [--max-drop pct] [--target-rps rps] [--json]")
+	fmt.Println("  diagnose (--scenario <path> [--seed n] | --trace <path>) [--json]")
+	fmt.Println("  compare --baseline <path> --candidate <path> [--candidate <path>...] [--threshold p99_ms=+10] [--seed n] [--json]")
+}
+
+func loadScenario(path string) (schema.Scenario, error) {
+	var sc schema.Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return sc, err
+	}
+	return sc, schema.ValidateScenario(sc)
+}
+
+type planLine struct {
+	name   string
+	value  string
+	res    capacity.Result
+	err    error
+	target bool
+}
+
+func runPlan(sc schema.Scenario, slo capacity.SLO, opts capacity.Options, target float64, jsonOut bool) {
+	var lines []planLine
+	res, err := capacity.MaxRPS(sc, slo, opts)
+	lines = append(lines, planLine{name: "max_rps", value: fmt.Sprintf("%.2f", res.MaxRPS), res: res, err: err})
+	if target > 0 {
+		res, err = capacity.MinReplicas(sc, target, slo, opts)
+		lines = append(lines, planLine{name: "replicas", value: fmt.Sprint(res.Replicas), res: res, err: err, target: true})
+		res, err = capacity.MinConcurrency(sc, target, slo, opts)
+		lines = append(lines, planLine{name: "concurrency", value: fmt.Sprint(res.Concurrency), res: res, err: err, target: true})
+	}
+
+	if jsonOut {
+		out := map[string]interface{}{}
+		for _, l := range lines {
+			entry := map[string]interface{}{"result": l.res}
+			if l.err != nil {
+				entry["error"] = l.err.Error()
+			}
+			out[l.name] = entry
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEARCH\tRESULT\tBINDING\tRUNS\tNOTE")
+	for _, l := range lines {
+		note := ""
+		if l.target {
+			note = fmt.Sprintf("at %.2f rps", target)
+		}
+		if l.err != nil {
+			note = l.err.Error()
+			l.value = "-"
+		}
+		binding := l.res.Binding
+		if l.res.BindingResource != "" {
+			binding = strings.TrimSpace(fmt.Sprintf("%s (%s %.1f%%)", binding, l.res.BindingResource, l.res.BindingUtilization*100))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", l.name, l.value, binding, len(l.res.Evaluations), note)
+	}
+	_ = w.Flush()
+}
+
+// runDiagnose diagnoses a simulated serving run, or a training run through
+// its trace, or a trace file.
+func runDiagnose(scenarioPath, tracePath string, seed int64) (diagnose.Report, error) {
+	if tracePath != "" {
+		data, err := os.ReadFile(tracePath)
+		if err != nil {
+			return diagnose.Report{}, err
+		}
+		spans, err := diagnose.FromTrace(data)
+		if err != nil {
+			return diagnose.Report{}, err
+		}
+		return diagnose.Analyze(spans, diagnose.Options{})
+	}
+	sc, err := loadScenario(scenarioPath)
+	if err != nil {
+		return diagnose.Report{}, err
+	}
+	if sc.IsTraining() {
+		_, tr := sim.RunTraining(sc, seed)
+		data, err := tr.Marshal()
+		if err != nil {
+			return diagnose.Report{}, err
+		}
+		spans, err := diagnose.FromTrace(data)
+		if err != nil {
+			return diagnose.Report{}, err
+		}
+		return diagnose.Analyze(spans, diagnose.Options{})
+	}
+	results, _ := sim.Run(sc, seed)
+	spans := diagnose.FromRequests(sim.Breakdown(results).Requests)
+	return diagnose.Analyze(spans, diagnose.Options{GPUSlots: diagnose.GPUSlots(sc.Target)})
+}
+
+func printDiagnosis(rep diagnose.Report, jsonOut bool) {
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+		return
+	}
+	fmt.Printf("%s\n", rep.Primary)
+	for _, e := range rep.Evidence {
+		fmt.Printf("  - %s\n", e)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tSHARE\tTIME_MS")
+	rows := []struct {
+		name      string
+		share, ms float64
+	}{
+		{"gpu", rep.Shares.GPU, rep.TimeMS.GPU},
+		{"transfer", rep.Shares.Transfer, rep.TimeMS.Transfer},
+		{"cpu", rep.Shares.CPU, rep.TimeMS.CPU},
+		{"queue", rep.Shares.Queue, rep.TimeMS.Queue},
+		{"other", rep.Shares.Other, rep.TimeMS.Other},
+	}
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%.1f%%\t%.1f\n", r.name, r.share*100, r.ms)
+	}
+	_ = w.Flush()
+	for _, win := range rep.Saturation {
+		fmt.Printf("saturated %s: %.1f-%.1f ms\n", win.Resource, win.StartMS, win.EndMS)
+	}
+}
+
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// thresholdList parses metric=+pct (may not rise by more than pct),
+// metric=-pct (may not fall by more) and metric=pct (either way).
+type thresholdList []compare.Threshold
+
+func (l *thresholdList) String() string { return fmt.Sprint(*l) }
+
+func (l *thresholdList) Set(v string) error {
+	metric, limit, ok := strings.Cut(v, "=")
+	if !ok || metric == "" {
+		return fmt.Errorf("expected metric=+pct, metric=-pct or metric=pct")
+	}
+	pct, err := strconv.ParseFloat(strings.TrimLeft(limit, "+-"), 64)
+	if err != nil || pct < 0 {
+		return fmt.Errorf("bad percentage %q", limit)
+	}
+	th := compare.Threshold{Metric: metric}
+	switch {
+	case strings.HasPrefix(limit, "+"):
+		th.MaxIncreasePct = pct
+	case strings.HasPrefix(limit, "-"):
+		th.MaxDecreasePct = pct
+	default:
+		th.MaxIncreasePct, th.MaxDecreasePct = pct, pct
+	}
+	*l = append(*l, th)
+	return nil
+}
+
+// runCompare simulates each scenario with the same seed, so request i sees
+// the same random draws in every run, and compares them with the first.
+func runCompare(paths []string, seed int64, thresholds []compare.Threshold) (compare.Result, error) {
+	runs := make([]compare.Run, 0, len(paths))
+	for _, path := range paths {
+		sc, err := loadScenario(path)
+		if err != nil {
+			return compare.Result{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if sc.IsTraining() {
+			return compare.Result{}, fmt.Errorf("%s: comparisons support serving scenarios only", path)
+		}
+		results := sim.RunWithOptions(sc, seed, sim.Options{TraceEvery: -1}).Results
+		runs = append(runs, compare.Run{
+			ID:       path,
+			Scenario: sc,
+			Summary:  sim.Summarize(results, sc.Workload.Duration, sc.Target),
+			Stages:   sim.Breakdown(results).StageAggregates,
+		})
+	}
+	return compare.Compare(runs, 0, thresholds)
+}
+
+func printComparison(res compare.Result, jsonOut bool) {
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(res)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(res.Scenario) > 0 {
+		fmt.Fprintln(w, "FIELD\t"+strings.Join(res.Runs, "\t"))
+		for _, d := range res.Scenario {
+			vals := make([]string, len(d.Values))
+			for i, v := range d.Values {
+				vals[i] = compare.Label(v)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", d.Path, strings.Join(vals, "\t"))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "RUN\tMETRIC\tBASELINE\tVALUE\tDELTA\tRATIO")
+	for _, c := range res.Comparisons {
+		for _, m := range c.Metrics {
+			ratio := "-"
+			if m.Ratio != nil {
+				ratio = fmt.Sprintf("%.3f", *m.Ratio)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.3f\t%.3f\t%+.3f\t%s\n", c.Run, m.Metric, m.Baseline, m.Value, m.Delta, ratio)
+		}
+	}
+	_ = w.Flush()
+	if !res.Verdict.Regressed {
+		fmt.Println("no regressions")
+		return
+	}
+	for _, r := range res.Verdict.Regressions {
+		fmt.Printf("REGRESSION %s: %s %.3f -> %.3f (%+.1f%%, limit %+.1f%%)\n", r.Run, r.Metric, r.Baseline, r.Value, r.ChangePct, r.LimitPct)
+	}
+}
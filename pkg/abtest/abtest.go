@@ -0,0 +1,272 @@
+// Package abtest compares two scenario variants with common random numbers:
+// both variants run with the same seeds, and the simulator derives every
+// per-request draw from the seed and the request id, so request i sees the
+// same arrival, class, branch and jitter draws in A and B. Differences
+// between paired requests then come from the change itself rather than from
+// sampling noise, which tightens the confidence intervals on the deltas.
+package abtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+	"simulator/pkg/sweep"
+)
+
+// Replication and row limits.
+const (
+	DefaultReplications = 5
+	MaxReplications     = 100
+	DefaultLimit        = 100
+)
+
+// Spec describes a comparison. Metrics are summary fields by JSON name and
+// default to DefaultMetrics; Limit caps the per-request rows returned.
+type Spec struct {
+	A            schema.Scenario `json:"a"`
+	B            schema.Scenario `json:"b"`
+	Replications int             `json:"replications,omitempty"`
+	Seed         int64           `json:"seed,omitempty"`
+	Metrics      []string        `json:"metrics,omitempty"`
+	Limit        int             `json:"limit,omitempty"`
+}
+
+// DefaultMetrics are compared when the spec names none.
+func DefaultMetrics() []string {
+	return []string{"p50_ms", "p90_ms", "p99_ms", "avg_queue_ms", "throughput_rps"}
+}
+
+// Delta is a B−A difference averaged over replications, with a 95%
+// Student-t interval across replications. Significant is set when the
+// interval excludes zero.
+type Delta struct {
+	A           float64 `json:"a"`
+	B           float64 `json:"b"`
+	Delta       float64 `json:"delta"`
+	DeltaPct    float64 `json:"delta_percent,omitempty"`
+	CILow       float64 `json:"ci_low"`
+	CIHigh      float64 `json:"ci_high"`
+	Significant bool    `json:"significant"`
+}
+
+// MetricDelta is the paired difference of one summary metric.
+type MetricDelta struct {
+	Metric string `json:"metric"`
+	Delta
+}
+
+// RequestDelta is one request's paired difference within a replication.
+type RequestDelta struct {
+	Replication  int     `json:"replication"`
+	ID           int     `json:"id"`
+	Class        string  `json:"class,omitempty"`
+	LatencyAMS   float64 `json:"latency_a_ms"`
+	LatencyBMS   float64 `json:"latency_b_ms"`
+	DeltaMS      float64 `json:"delta_ms"`
+	QueueAMS     float64 `json:"queue_a_ms"`
+	QueueBMS     float64 `json:"queue_b_ms"`
+	QueueDeltaMS float64 `json:"queue_delta_ms"`
+}
+
+// Paired summarizes the per-request deltas: the mean is taken within each
+// replication and the interval across replications, since requests in one
+// run share queues and are not independent.
+type Paired struct {
+	Pairs    int     `json:"pairs"`
+	Unpaired int     `json:"unpaired,omitempty"` // ids run or completed in one variant only
+	Improved int     `json:"improved"`           // B faster than A
+	Worse    int     `json:"worse"`
+	StdDevMS float64 `json:"stddev_ms"` // spread of individual deltas
+	Latency  Delta   `json:"latency_ms"`
+	Queue    Delta   `json:"queue_ms"`
+}
+
+// Result is the outcome of a comparison. Requests holds the largest
+// per-request latency changes first, up to the spec's limit.
+type Result struct {
+	Replications int            `json:"replications"`
+	Seeds        []int64        `json:"seeds"`
+	Metrics      []MetricDelta  `json:"metrics"`
+	Paired       Paired         `json:"paired"`
+	Requests     []RequestDelta `json:"requests"`
+}
+
+// Compare runs both variants over the replications and pairs their results.
+func Compare(spec Spec) (Result, error) {
+	if spec.A.IsTraining() || spec.B.IsTraining() {
+		return Result{}, fmt.Errorf("a/b comparisons support serving scenarios only")
+	}
+	if spec.Replications == 0 {
+		spec.Replications = DefaultReplications
+	}
+	if spec.Replications < 2 || spec.Replications > MaxReplications {
+		return Result{}, fmt.Errorf("replications must be between 2 and %d", MaxReplications)
+	}
+	if len(spec.Metrics) == 0 {
+		spec.Metrics = DefaultMetrics()
+	}
+	for _, m := range spec.Metrics {
+		if !sweep.IsMetric(m) {
+			return Result{}, fmt.Errorf("unknown metric %q", m)
+		}
+	}
+	if spec.Limit <= 0 {
+		spec.Limit = DefaultLimit
+	}
+
+	n := spec.Replications
+	res := Result{Replications: n, Seeds: make([]int64, n)}
+	metricA := make([][]float64, len(spec.Metrics))
+	metricB := make([][]float64, len(spec.Metrics))
+	var latA, latB, queueA, queueB []float64 // per-replication means over pairs
+	var all []RequestDelta
+	var sum, sumSq float64
+	for r := 0; r < n; r++ {
+		seed := spec.Seed + int64(r)
+		res.Seeds[r] = seed
+		untraced := sim.Options{TraceEvery: -1}
+		ra := sim.RunWithOptions(spec.A, seed, untraced).Results
+		rb := sim.RunWithOptions(spec.B, seed, untraced).Results
+		ma := sweep.Metrics(sim.Summarize(ra, spec.A.Workload.Duration, spec.A.Target))
+		mb := sweep.Metrics(sim.Summarize(rb, spec.B.Workload.Duration, spec.B.Target))
+		for i, m := range spec.Metrics {
+			metricA[i] = append(metricA[i], ma[m])
+			metricB[i] = append(metricB[i], mb[m])
+		}
+
+		pairs, unpaired := pair(ra, rb, r)
+		res.Paired.Unpaired += unpaired
+		var la, lb, qa, qb float64
+		for _, d := range pairs {
+			la += d.LatencyAMS
+			lb += d.LatencyBMS
+			qa += d.QueueAMS
+			qb += d.QueueBMS
+			sum += d.DeltaMS
+			sumSq += d.DeltaMS * d.DeltaMS
+			switch {
+			case d.DeltaMS < 0:
+				res.Paired.Improved++
+			case d.DeltaMS > 0:
+				res.Paired.Worse++
+			}
+		}
+		if len(pairs) == 0 {
+			continue
+		}
+		k := float64(len(pairs))
+		latA, latB = append(latA, la/k), append(latB, lb/k)
+		queueA, queueB = append(queueA, qa/k), append(queueB, qb/k)
+		res.Paired.Pairs += len(pairs)
+		all = append(all, pairs...)
+	}
+	if res.Paired.Pairs == 0 {
+		return Result{}, fmt.Errorf("no request completed in both variants")
+	}
+
+	for i, m := range spec.Metrics {
+		res.Metrics = append(res.Metrics, MetricDelta{Metric: m, Delta: paired(metricA[i], metricB[i])})
+	}
+	res.Paired.Latency = paired(latA, latB)
+	res.Paired.Queue = paired(queueA, queueB)
+	k := float64(res.Paired.Pairs)
+	if k > 1 {
+		res.Paired.StdDevMS = math.Sqrt(math.Max(0, (sumSq-sum*sum/k)/(k-1)))
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return math.Abs(all[i].DeltaMS) > math.Abs(all[j].DeltaMS) })
+	if len(all) > spec.Limit {
+		all = all[:spec.Limit]
+	}
+	res.Requests = all
+	return res, nil
+}
+
+// pair matches completed requests of replication r by id.
+func pair(a, b []sim.RequestResult, r int) ([]RequestDelta, int) {
+	byID := make(map[int]sim.RequestResult, len(b))
+	for _, x := range b {
+		if !x.Dropped {
+			byID[x.ID] = x
+		}
+	}
+	var out []RequestDelta
+	unpaired := len(byID)
+	for _, x := range a {
+		y, ok := byID[x.ID]
+		if x.Dropped || !ok {
+			if !x.Dropped {
+				unpaired++
+			}
+			continue
+		}
+		unpaired--
+		out = append(out, RequestDelta{
+			Replication:  r,
+			ID:           x.ID,
+			Class:        x.Class,
+			LatencyAMS:   x.LatencyMS,
+			LatencyBMS:   y.LatencyMS,
+			DeltaMS:      y.LatencyMS - x.LatencyMS,
+			QueueAMS:     x.QueueMS,
+			QueueBMS:     y.QueueMS,
+			QueueDeltaMS: y.QueueMS - x.QueueMS,
+		})
+	}
+	return out, unpaired
+}
+
+// paired averages per-replication values of A and B and puts a 95% interval
+// on the mean of their differences.
+func paired(a, b []float64) Delta {
+	n := len(a)
+	if n == 0 {
+		return Delta{}
+	}
+	var d Delta
+	diffs := make([]float64, n)
+	for i := range a {
+		d.A += a[i] / float64(n)
+		d.B += b[i] / float64(n)
+		diffs[i] = b[i] - a[i]
+	}
+	d.Delta = d.B - d.A
+	if d.A != 0 {
+		d.DeltaPct = d.Delta / math.Abs(d.A) * 100
+	}
+	half := 0.0
+	if n > 1 {
+		var ss float64
+		for _, x := range diffs {
+			ss += (x - d.Delta) * (x - d.Delta)
+		}
+		half = tQuantile(n-1) * math.Sqrt(ss/float64(n-1)/float64(n))
+	}
+	d.CILow, d.CIHigh = d.Delta-half, d.Delta+half
+	d.Significant = d.CILow > 0 || d.CIHigh < 0
+	return d
+}
+
+// tQuantile is the two-sided 95% Student-t critical value for df degrees of
+// freedom.
+func tQuantile(df int) float64 {
+	table := []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	switch {
+	case df < 1:
+		return math.Inf(1)
+	case df <= len(table):
+		return table[df-1]
+	case df <= 60:
+		return 2.000
+	case df <= 120:
+		return 1.980
+	}
+	return 1.960
+}
@@ -0,0 +1,69 @@
+package abtest
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name:     "ab",
+		Workload: schema.Workload{Name: "wl", RPS: 20, Duration: 5, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "decode", Kind: schema.StageFixedMs, Value: 2},
+			{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		},
+		Target: schema.GPUProfile{
+			Name:        "GPU",
+			TFLOPS:      100,
+			MemGBps:     2000,
+			TokenCost:   0.1,
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 4,
+		},
+	}
+}
+
+func TestIdenticalVariantsHaveZeroDelta(t *testing.T) {
+	res, err := Compare(Spec{A: baseScenario(), B: baseScenario(), Seed: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range res.Metrics {
+		if m.Delta.Delta != 0 || m.CILow != 0 || m.CIHigh != 0 || m.Significant {
+			t.Fatalf("%s: identical variants should not differ: %+v", m.Metric, m.Delta)
+		}
+	}
+	if res.Paired.Pairs != 5*100 || res.Paired.Improved+res.Paired.Worse != 0 {
+		t.Fatalf("every request should pair with zero delta: %+v", res.Paired)
+	}
+}
+
+func TestSlowerComputeIsSignificant(t *testing.T) {
+	b := baseScenario()
+	b.Pipeline[1].Value = 120
+	res, err := Compare(Spec{A: baseScenario(), B: b, Seed: 7, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat := res.Paired.Latency
+	if !lat.Significant || lat.CILow <= 0 {
+		t.Fatalf("expected a significant latency increase: %+v", lat)
+	}
+	// common random numbers: every request sees the same jitter draw, so
+	// the 2ms extra compute dominates each pair
+	if res.Paired.Worse < res.Paired.Pairs*9/10 {
+		t.Fatalf("most requests should be slower in B: %+v", res.Paired)
+	}
+	if len(res.Requests) != 10 || res.Requests[0].DeltaMS < res.Requests[9].DeltaMS {
+		t.Fatalf("expected the 10 largest deltas first: %+v", res.Requests)
+	}
+}
+
+func TestRejectsUnknownMetric(t *testing.T) {
+	if _, err := Compare(Spec{A: baseScenario(), B: baseScenario(), Metrics: []string{"nope"}}); err == nil {
+		t.Fatal("expected an error for an unknown metric")
+	}
+}
@@ -0,0 +1,155 @@
+// Package analytic estimates serving latency with closed-form queueing
+// models, as a quick cross-check of the simulator and a way to spot
+// unstable scenarios before running them.
+package analytic
+
+import (
+	"math"
+	"sort"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+)
+
+// Queueing models reported for the bottleneck resource.
+const (
+	ModelMMc = "M/M/c"
+	ModelMDc = "M/D/c"
+	ModelGGc = "G/G/c"
+)
+
+// Resource is the offered load on one contended resource.
+type Resource struct {
+	Name        string  `json:"name"`
+	Servers     int     `json:"servers"`
+	ServiceMS   float64 `json:"service_ms"`
+	Utilization float64 `json:"utilization"`
+}
+
+// ModelEstimate is one queueing model's prediction for the whole request.
+// Wait times are at the bottleneck; latencies add the unloaded pipeline.
+type ModelEstimate struct {
+	Model         string  `json:"model"`
+	WaitProb      float64 `json:"wait_prob"`
+	MeanWaitMS    float64 `json:"mean_wait_ms"`
+	P99WaitMS     float64 `json:"p99_wait_ms"`
+	MeanLatencyMS float64 `json:"mean_latency_ms"`
+	P99LatencyMS  float64 `json:"p99_latency_ms"`
+}
+
+// Estimate is the analytic view of a serving scenario. ArrivalSCV and
+// ServiceSCV are the squared coefficients of variation of the engine's
+// inter-arrival and service times that the G/G/c model uses.
+type Estimate struct {
+	ArrivalRPS        float64         `json:"arrival_rps"`
+	ArrivalSCV        float64         `json:"arrival_scv"`
+	ServiceSCV        float64         `json:"service_scv"`
+	UnloadedLatencyMS float64         `json:"unloaded_latency_ms"`
+	Bottleneck        *Resource       `json:"bottleneck,omitempty"`
+	Resources         []Resource      `json:"resources"`
+	Models            []ModelEstimate `json:"models,omitempty"`
+	Stable            bool            `json:"stable"`
+	Warning           string          `json:"warning,omitempty"`
+}
+
+// Analyze treats the most utilized resource as the only queue. M/M/c and
+// M/D/c assume Poisson arrivals and serve as references; G/G/c
+// (Allen–Cunneen) uses the variability the engine actually generates.
+// Arrivals sit on a regular grid, each shifted uniformly within ±jitter_pct
+// of the interval, and service times are drawn uniformly within
+// ±jitter_pct of their mean.
+func Analyze(s schema.Scenario) Estimate {
+	demands, unloaded := sim.Demands(s)
+	lambda := s.Workload.RPS
+	jitter := s.Workload.JitterPct
+	if jitter == 0 {
+		jitter = 5
+	}
+	j := jitter / 100
+	est := Estimate{
+		ArrivalRPS: lambda,
+		// A uniform on [-j, j] has variance j²/3; an inter-arrival time is
+		// the difference of two independent shifts.
+		ArrivalSCV:        2 * j * j / 3,
+		ServiceSCV:        j * j / 3,
+		UnloadedLatencyMS: unloaded * 1000,
+		Stable:            true,
+	}
+	for _, d := range demands {
+		if d.ServiceS <= 0 || d.Servers < 1 {
+			continue
+		}
+		est.Resources = append(est.Resources, Resource{
+			Name:        d.Name,
+			Servers:     d.Servers,
+			ServiceMS:   d.ServiceS * 1000,
+			Utilization: lambda * d.ServiceS / float64(d.Servers),
+		})
+	}
+	sort.SliceStable(est.Resources, func(i, j int) bool {
+		return est.Resources[i].Utilization > est.Resources[j].Utilization
+	})
+	if len(est.Resources) == 0 {
+		return est
+	}
+	b := est.Resources[0]
+	est.Bottleneck = &b
+	if b.Utilization >= 1 {
+		est.Stable = false
+		est.Warning = "offered load exceeds capacity of " + b.Name + "; queues grow without bound"
+		return est
+	}
+
+	service := b.ServiceMS / 1000
+	for _, m := range []struct {
+		name     string
+		ca2, cs2 float64
+	}{
+		{ModelMMc, 1, 1},
+		{ModelMDc, 1, 0},
+		{ModelGGc, est.ArrivalSCV, est.ServiceSCV},
+	} {
+		pw, wq, p99 := queueWait(lambda, service, b.Servers, m.ca2, m.cs2)
+		est.Models = append(est.Models, ModelEstimate{
+			Model:         m.name,
+			WaitProb:      pw,
+			MeanWaitMS:    wq * 1000,
+			P99WaitMS:     p99 * 1000,
+			MeanLatencyMS: (unloaded + wq) * 1000,
+			P99LatencyMS:  (unloaded + p99) * 1000,
+		})
+	}
+	return est
+}
+
+// queueWait returns the probability of waiting, the mean wait and the p99
+// wait for a c-server queue with arrival rate lambda and mean service time
+// service. The M/M/c result is scaled by the Allen–Cunneen factor
+// (ca²+cs²)/2; the p99 applies the same factor to the exponential tail of
+// the M/M/c waiting time.
+func queueWait(lambda, service float64, c int, ca2, cs2 float64) (float64, float64, float64) {
+	rho := lambda * service / float64(c)
+	if rho >= 1 {
+		return 1, math.Inf(1), math.Inf(1)
+	}
+	pw := erlangC(c, lambda*service)
+	factor := (ca2 + cs2) / 2
+	drain := float64(c)/service - lambda // rate of the waiting-time tail
+	wq := pw / drain * factor
+	var p99 float64
+	if pw > 0.01 {
+		p99 = math.Log(pw/0.01) / drain * factor
+	}
+	return pw, wq, p99
+}
+
+// erlangC is the probability that an arrival waits in an M/M/c queue with
+// offered load a = λ/μ, computed from the Erlang B recurrence.
+func erlangC(c int, a float64) float64 {
+	b := 1.0
+	for k := 1; k <= c; k++ {
+		b = a * b / (float64(k) + a*b)
+	}
+	rho := a / float64(c)
+	return b / (1 - rho*(1-b))
+}
@@ -0,0 +1,108 @@
+package analytic
+
+import (
+	"math"
+	"testing"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+)
+
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name: "mm1",
+		Workload: schema.Workload{
+			Name:     "wl",
+			RPS:      50,
+			Duration: 20,
+			Batch:    1,
+		},
+		Pipeline: []schema.Stage{
+			{Name: "prep", Kind: schema.StageFixedMs, Value: 2},
+			{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		},
+		Target: schema.GPUProfile{
+			Name:        "GPU",
+			TFLOPS:      100,
+			MemGBps:     2000,
+			TokenCost:   0.1, // 10ms per request
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 1,
+		},
+	}
+}
+
+func TestErlangCSingleServerIsUtilization(t *testing.T) {
+	if got := erlangC(1, 0.6); math.Abs(got-0.6) > 1e-12 {
+		t.Fatalf("expected C(1, 0.6) = 0.6, got %f", got)
+	}
+	// textbook value for c=2, a=1
+	if got := erlangC(2, 1); math.Abs(got-1.0/3) > 1e-12 {
+		t.Fatalf("expected C(2, 1) = 1/3, got %f", got)
+	}
+}
+
+func TestEstimateMM1(t *testing.T) {
+	est := Analyze(baseScenario())
+	if !est.Stable || est.Bottleneck == nil || est.Bottleneck.Name != "gpu" {
+		t.Fatalf("unexpected estimate %+v", est)
+	}
+	if math.Abs(est.Bottleneck.Utilization-0.5) > 1e-9 {
+		t.Fatalf("expected 50%% utilization, got %f", est.Bottleneck.Utilization)
+	}
+	var mmc, mdc ModelEstimate
+	for _, m := range est.Models {
+		switch m.Model {
+		case ModelMMc:
+			mmc = m
+		case ModelMDc:
+			mdc = m
+		}
+	}
+	// M/M/1: Wq = ρ/(μ-λ) = 0.5/50 s
+	if math.Abs(mmc.MeanWaitMS-10) > 1e-9 {
+		t.Fatalf("expected 10ms M/M/1 wait, got %f", mmc.MeanWaitMS)
+	}
+	if math.Abs(mdc.MeanWaitMS-5) > 1e-9 {
+		t.Fatalf("expected 5ms M/D/1 wait, got %f", mdc.MeanWaitMS)
+	}
+	if math.Abs(mmc.MeanLatencyMS-22) > 1e-9 || mmc.P99LatencyMS <= mmc.MeanLatencyMS {
+		t.Fatalf("unexpected latency %+v", mmc)
+	}
+}
+
+func TestEstimateFlagsOverload(t *testing.T) {
+	s := baseScenario()
+	s.Workload.RPS = 150
+	est := Analyze(s)
+	if est.Stable || est.Warning == "" || len(est.Models) != 0 {
+		t.Fatalf("expected unstable estimate, got %+v", est)
+	}
+}
+
+func TestGGcTracksSimulatedQueueing(t *testing.T) {
+	s := baseScenario()
+	s.Workload.RPS = 90
+	est := Analyze(s)
+	if math.Abs(est.ArrivalSCV-2*0.05*0.05/3) > 1e-12 || math.Abs(est.ServiceSCV-0.05*0.05/3) > 1e-12 {
+		t.Fatalf("unexpected variability %+v", est)
+	}
+	waits := map[string]float64{}
+	for _, m := range est.Models {
+		waits[m.Model] = m.MeanWaitMS
+	}
+	// Allen–Cunneen scales the M/M/c wait by (ca²+cs²)/2
+	if want := waits[ModelMMc] * (est.ArrivalSCV + est.ServiceSCV) / 2; math.Abs(waits[ModelGGc]-want) > 1e-9 {
+		t.Fatalf("G/G/1 wait %f, want %f", waits[ModelGGc], want)
+	}
+
+	var simWait float64
+	for seed := int64(1); seed <= 3; seed++ {
+		results, _ := sim.Run(s, seed)
+		simWait += sim.Summarize(results, s.Workload.Duration, s.Target).AvgQueueMS / 3
+	}
+	if math.Abs(simWait-waits[ModelGGc]) >= math.Abs(simWait-waits[ModelMMc]) {
+		t.Fatalf("simulated wait %f should be closer to G/G/1 (%f) than M/M/1 (%f)", simWait, waits[ModelGGc], waits[ModelMMc])
+	}
+}
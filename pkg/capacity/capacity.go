@@ -0,0 +1,323 @@
+// Package capacity searches for the largest load a scenario sustains within
+// an SLO, and for the smallest deployment that sustains a target load.
+package capacity
+
+import (
+	"fmt"
+	"math"
+
+	"simulator/pkg/analytic"
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+)
+
+// Constraint names reported as the binding constraint.
+const (
+	ConstraintP50        = "p50_ms"
+	ConstraintP99        = "p99_ms"
+	ConstraintQueue      = "avg_queue_ms"
+	ConstraintDrops      = "drop_percent"
+	ConstraintThroughput = "throughput"
+	ConstraintSearch     = "search_limit"
+)
+
+// SLO lists the limits a load level must meet; zero fields are not checked.
+// Throughput is always checked: completions must keep up with MinGoodputPct
+// of the arrivals over the second half of the run (default 95).
+type SLO struct {
+	P50MS         float64 `json:"p50_ms,omitempty"`
+	P99MS         float64 `json:"p99_ms,omitempty"`
+	AvgQueueMS    float64 `json:"avg_queue_ms,omitempty"`
+	MaxDropPct    float64 `json:"max_drop_percent,omitempty"`
+	MinGoodputPct float64 `json:"min_goodput_percent,omitempty"`
+}
+
+// Options tunes the search.
+type Options struct {
+	Replications   int     `json:"replications,omitempty"`      // seeds per load level, default 3
+	TolerancePct   float64 `json:"tolerance_percent,omitempty"` // bisection stops within this of the boundary, default 2
+	MaxRPS         float64 `json:"max_rps,omitempty"`           // search ceiling, default from the analytic capacity
+	MaxReplicas    int     `json:"max_replicas,omitempty"`      // default 256
+	MaxConcurrency int     `json:"max_concurrency,omitempty"`   // default 256
+	Seed           int64   `json:"seed,omitempty"`
+}
+
+// Evaluation is one simulated load level, averaged over replications.
+type Evaluation struct {
+	RPS         float64 `json:"rps"`
+	Replicas    int     `json:"replicas,omitempty"`
+	Concurrency int     `json:"concurrency,omitempty"`
+	P50MS       float64 `json:"p50_ms"`
+	P99MS       float64 `json:"p99_ms"`
+	AvgQueueMS  float64 `json:"avg_queue_ms"`
+	DropPct     float64 `json:"drop_percent"`
+	GoodputPct  float64 `json:"goodput_percent"`
+	CapacityRPS float64 `json:"capacity_rps"` // completion rate when offered twice the load
+	Feasible    bool    `json:"feasible"`
+	Violated    string  `json:"violated,omitempty"`
+}
+
+// Result is the outcome of a search. Binding names the constraint that fails
+// just above the reported limit. BindingResource is the resource that
+// saturates first there: "gpu", a pipeline stage "gpu:pp<n>", a shared
+// "link:<name>", "streams" or a stream "engine:<kind>", with its analytic
+// utilization at the limit.
+type Result struct {
+	MaxRPS             float64      `json:"max_rps,omitempty"`
+	Replicas           int          `json:"replicas,omitempty"`
+	Concurrency        int          `json:"concurrency,omitempty"`
+	Binding            string       `json:"binding_constraint"`
+	BindingResource    string       `json:"binding_resource,omitempty"`
+	BindingUtilization float64      `json:"binding_utilization,omitempty"`
+	Evaluations        []Evaluation `json:"evaluations"`
+}
+
+// setResource records the bottleneck of s, the scenario at the limit.
+func (r *Result) setResource(s schema.Scenario) {
+	if b := analytic.Analyze(s).Bottleneck; b != nil {
+		r.BindingResource, r.BindingUtilization = b.Name, b.Utilization
+	}
+}
+
+type planner struct {
+	base  schema.Scenario
+	slo   SLO
+	opts  Options
+	evals []Evaluation
+}
+
+func newPlanner(s schema.Scenario, slo SLO, opts Options) *planner {
+	if opts.Replications < 1 {
+		opts.Replications = 3
+	}
+	if opts.TolerancePct <= 0 {
+		opts.TolerancePct = 2
+	}
+	if opts.MaxReplicas < 1 {
+		opts.MaxReplicas = 256
+	}
+	if opts.MaxConcurrency < 1 {
+		opts.MaxConcurrency = 256
+	}
+	if slo.MinGoodputPct <= 0 {
+		slo.MinGoodputPct = 95
+	}
+	return &planner{base: s, slo: slo, opts: opts}
+}
+
+// evaluate runs s at its configured load over every replication and checks
+// the averaged metrics against the SLO.
+func (p *planner) evaluate(s schema.Scenario) Evaluation {
+	ev := Evaluation{RPS: s.Workload.RPS, Concurrency: s.Target.Concurrency}
+	untraced := sim.Options{TraceEvery: -1}
+	overload := s
+	overload.Workload.RPS *= 2
+	n := float64(p.opts.Replications)
+	for r := 0; r < p.opts.Replications; r++ {
+		seed := p.opts.Seed + int64(r)
+		results := sim.RunWithOptions(s, seed, untraced).Results
+		sum := sim.Summarize(results, s.Workload.Duration, s.Target)
+		ev.P50MS += sum.P50LatencyMS / n
+		ev.P99MS += sum.P99LatencyMS / n
+		ev.AvgQueueMS += sum.AvgQueueMS / n
+		if sum.TotalRequests > 0 {
+			ev.DropPct += float64(sum.DroppedRequests) / float64(sum.TotalRequests) * 100 / n
+		}
+		ev.GoodputPct += goodputPct(results, s.Workload.Duration) / n
+		// offered twice the load, the deployment completes requests at
+		// its capacity, or keeps up and has headroom to spare
+		saturated := sim.RunWithOptions(overload, seed, untraced).Results
+		ev.CapacityRPS += completionRate(saturated, s.Workload.Duration) / n
+	}
+	ev.Violated = p.violated(ev)
+	ev.Feasible = ev.Violated == ""
+	p.evals = append(p.evals, ev)
+	return ev
+}
+
+// violated returns the first SLO term ev breaks, checking throughput first
+// since latency percentiles are meaningless once the system cannot keep up.
+// A load within the search tolerance of the simulated capacity also fails
+// throughput: just above capacity the backlog builds too slowly to show in
+// a short window, so the run alone cannot tell the two apart.
+func (p *planner) violated(ev Evaluation) string {
+	switch {
+	case ev.GoodputPct < p.slo.MinGoodputPct || ev.RPS > ev.CapacityRPS*(1-p.opts.TolerancePct/100):
+		return ConstraintThroughput
+	case p.slo.MaxDropPct > 0 && ev.DropPct > p.slo.MaxDropPct:
+		return ConstraintDrops
+	case p.slo.P99MS > 0 && ev.P99MS > p.slo.P99MS:
+		return ConstraintP99
+	case p.slo.P50MS > 0 && ev.P50MS > p.slo.P50MS:
+		return ConstraintP50
+	case p.slo.AvgQueueMS > 0 && ev.AvgQueueMS > p.slo.AvgQueueMS:
+		return ConstraintQueue
+	}
+	return ""
+}
+
+func (p *planner) withRPS(rps float64) schema.Scenario {
+	s := p.base
+	s.Workload.RPS = rps
+	return s
+}
+
+// MaxRPS bisects on the offered load for the highest RPS that meets slo.
+// The ceiling defaults to 1.5× the analytic capacity of the bottleneck
+// resource, beyond which no queue is stable.
+func MaxRPS(s schema.Scenario, slo SLO, opts Options) (Result, error) {
+	p := newPlanner(s, slo, opts)
+	hi := p.opts.MaxRPS
+	if hi <= 0 {
+		hi = analyticCeiling(s)
+	}
+	if hi <= 0 {
+		return Result{}, fmt.Errorf("no contended resource bounds the load; set max_rps")
+	}
+	lo := 0.0
+	ev := p.evaluate(p.withRPS(hi))
+	if ev.Feasible {
+		res := Result{MaxRPS: hi, Binding: ConstraintSearch, Evaluations: p.evals}
+		res.setResource(p.withRPS(hi))
+		return res, nil
+	}
+	res := Result{Binding: ev.Violated}
+	// lowest level worth simulating: one request over the workload window
+	floor := 1 / s.Workload.Duration
+	for (hi-lo)/hi*100 > p.opts.TolerancePct {
+		mid := (lo + hi) / 2
+		if mid < floor {
+			break
+		}
+		if ev := p.evaluate(p.withRPS(mid)); ev.Feasible {
+			lo = mid
+		} else {
+			hi = mid
+			res.Binding = ev.Violated
+		}
+	}
+	res.MaxRPS = lo
+	res.Evaluations = p.evals
+	if lo == 0 {
+		return res, fmt.Errorf("slo is not met at any load; first violation: %s", res.Binding)
+	}
+	res.setResource(p.withRPS(lo))
+	return res, nil
+}
+
+// MinReplicas finds the fewest identical replicas that meet slo when target
+// RPS is split evenly across them.
+func MinReplicas(s schema.Scenario, target float64, slo SLO, opts Options) (Result, error) {
+	p := newPlanner(s, slo, opts)
+	check := func(n int) Evaluation {
+		ev := p.evaluate(p.withRPS(target / float64(n)))
+		p.evals[len(p.evals)-1].Replicas = n
+		return ev
+	}
+	n, binding, err := searchInt(1, p.opts.MaxReplicas, check)
+	res := Result{Replicas: n, Binding: binding, Evaluations: p.evals}
+	if err == nil {
+		res.setResource(p.withRPS(target / float64(n)))
+	}
+	return res, err
+}
+
+// MinConcurrency finds the fewest concurrency slots per device that meet
+// slo at the target RPS.
+func MinConcurrency(s schema.Scenario, target float64, slo SLO, opts Options) (Result, error) {
+	p := newPlanner(s, slo, opts)
+	check := func(c int) Evaluation {
+		sc := p.withRPS(target)
+		sc.Target.Concurrency = c
+		return p.evaluate(sc)
+	}
+	n, binding, err := searchInt(1, p.opts.MaxConcurrency, check)
+	res := Result{Concurrency: n, Binding: binding, Evaluations: p.evals}
+	if err == nil {
+		sc := p.withRPS(target)
+		sc.Target.Concurrency = n
+		res.setResource(sc)
+	}
+	return res, err
+}
+
+// searchInt finds the smallest n in [lo, max] for which check is feasible,
+// assuming feasibility is monotone in n. It grows n geometrically to bracket
+// the answer, then bisects. binding is the violation just below the answer.
+func searchInt(lo, max int, check func(int) Evaluation) (int, string, error) {
+	var binding string
+	n := lo
+	prev := lo - 1
+	for {
+		ev := check(n)
+		if ev.Feasible {
+			break
+		}
+		binding = ev.Violated
+		if n == max {
+			return 0, binding, fmt.Errorf("slo is not met with %d; first violation: %s", max, binding)
+		}
+		prev = n
+		n *= 2
+		if n > max {
+			n = max
+		}
+	}
+	// prev fails (or is below the range) and n passes
+	for n-prev > 1 {
+		mid := (prev + n) / 2
+		if ev := check(mid); ev.Feasible {
+			n = mid
+		} else {
+			prev = mid
+			binding = ev.Violated
+		}
+	}
+	return n, binding, nil
+}
+
+// analyticCeiling is 1.5× the RPS at which the bottleneck saturates, or 0
+// when nothing is contended.
+func analyticCeiling(s schema.Scenario) float64 {
+	s.Workload.RPS = 1
+	est := analytic.Analyze(s)
+	if est.Bottleneck == nil || est.Bottleneck.Utilization <= 0 {
+		return 0
+	}
+	return 1.5 / est.Bottleneck.Utilization
+}
+
+// goodputPct compares completions to arrivals over the second half of the
+// arrival window, leaving the first half as warm-up. Requests completing
+// there arrived about one latency earlier, so a system that keeps up
+// completes as many requests as arrive, while a saturated one completes
+// only its capacity and its backlog grows. Dropped requests do not count.
+func goodputPct(results []sim.RequestResult, durationS float64) float64 {
+	from, to := durationS/2*1000, durationS*1000
+	var arrived, done float64
+	for _, r := range results {
+		if r.ArrivalMS >= from && r.ArrivalMS < to {
+			arrived++
+		}
+		if !r.Dropped && r.EndMS >= from && r.EndMS < to {
+			done++
+		}
+	}
+	if arrived == 0 {
+		return 100
+	}
+	return math.Min(done/arrived, 1) * 100
+}
+
+// completionRate is the rate at which requests completed over the second
+// half of the arrival window, in requests per second.
+func completionRate(results []sim.RequestResult, durationS float64) float64 {
+	from, to := durationS/2*1000, durationS*1000
+	var done float64
+	for _, r := range results {
+		if !r.Dropped && r.EndMS >= from && r.EndMS < to {
+			done++
+		}
+	}
+	return done / (durationS / 2)
+}
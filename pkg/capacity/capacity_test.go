@@ -0,0 +1,139 @@
+package capacity
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+)
+
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name:     "plan",
+		Workload: schema.Workload{Name: "wl", RPS: 10, Duration: 5, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "pre", Kind: schema.StageFixedMs, Value: 2},
+			{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		},
+		Target: schema.GPUProfile{
+			Name:        "GPU",
+			TFLOPS:      100,
+			MemGBps:     2000,
+			TokenCost:   0.1, // 10ms per request, 100 rps per slot
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 1,
+		},
+	}
+}
+
+func TestMaxRPSStaysBelowSaturation(t *testing.T) {
+	res, err := MaxRPS(baseScenario(), SLO{P99MS: 100}, Options{Replications: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MaxRPS <= 5 || res.MaxRPS >= 100 {
+		t.Fatalf("expected max rps below the 100 rps capacity, got %f", res.MaxRPS)
+	}
+	if res.Binding != ConstraintP99 && res.Binding != ConstraintThroughput {
+		t.Fatalf("unexpected binding constraint %q", res.Binding)
+	}
+	if res.BindingResource != "gpu" || res.BindingUtilization <= 0 || res.BindingUtilization >= 1 {
+		t.Fatalf("expected the gpu to bind below saturation, got %s at %f", res.BindingResource, res.BindingUtilization)
+	}
+	for _, ev := range res.Evaluations {
+		if ev.Feasible && ev.RPS > res.MaxRPS {
+			t.Fatalf("feasible level %f above reported max %f", ev.RPS, res.MaxRPS)
+		}
+	}
+}
+
+func TestMaxRPSLooseSLOHitsSearchLimit(t *testing.T) {
+	res, err := MaxRPS(baseScenario(), SLO{P99MS: 1e9, MinGoodputPct: 1}, Options{Replications: 1, MaxRPS: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MaxRPS != 50 || res.Binding != ConstraintSearch {
+		t.Fatalf("expected the ceiling to bind, got %+v", res)
+	}
+}
+
+func TestMinReplicasAndConcurrency(t *testing.T) {
+	slo := SLO{P99MS: 100}
+	reps, err := MinReplicas(baseScenario(), 300, slo, Options{Replications: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reps.Replicas < 4 {
+		t.Fatalf("300 rps cannot fit in %d replicas of 100 rps", reps.Replicas)
+	}
+	if reps.Binding == "" {
+		t.Fatal("expected a binding constraint below the answer")
+	}
+	conc, err := MinConcurrency(baseScenario(), 300, slo, Options{Replications: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conc.Concurrency < 4 {
+		t.Fatalf("300 rps cannot fit in %d slots of 100 rps", conc.Concurrency)
+	}
+	for _, ev := range conc.Evaluations {
+		if ev.Concurrency < conc.Concurrency && ev.Feasible {
+			t.Fatalf("concurrency %d passed below the answer %d", ev.Concurrency, conc.Concurrency)
+		}
+	}
+}
+
+func TestSearchIntFailsAtMax(t *testing.T) {
+	_, binding, err := searchInt(1, 8, func(n int) Evaluation {
+		return Evaluation{Violated: ConstraintP99}
+	})
+	if err == nil || binding != ConstraintP99 {
+		t.Fatalf("expected failure with p99 binding, got %q %v", binding, err)
+	}
+}
+
+func TestBindingResourceNamesSharedLink(t *testing.T) {
+	s := baseScenario()
+	s.Links = []schema.Link{{Name: "s3", Kind: schema.StageStorage, BandwidthGBps: 1, LatencyMS: 5}}
+	// 20ms of contended transfer per request against 10ms of compute
+	s.Pipeline = append([]schema.Stage{{Name: "fetch", Kind: schema.StageStorage, Value: 20e6, Link: "s3"}}, s.Pipeline...)
+	res, err := MaxRPS(s, SLO{P99MS: 500}, Options{Replications: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.BindingResource != "link:s3" || res.MaxRPS >= 50 {
+		t.Fatalf("expected the s3 link to bind below 50 rps, got %s at %.1f rps", res.BindingResource, res.MaxRPS)
+	}
+}
+
+func TestGoodputComparesCompletionsToArrivals(t *testing.T) {
+	s := baseScenario()
+	s.Workload.RPS = 50
+	results, _ := sim.Run(s, 1)
+	if g := goodputPct(results, s.Workload.Duration); g != 100 {
+		t.Fatalf("a stable system should complete what arrives, got %.1f%%", g)
+	}
+	// twice the 100 rps capacity
+	s.Workload.RPS = 200
+	results, _ = sim.Run(s, 1)
+	if g := goodputPct(results, s.Workload.Duration); g < 45 || g > 55 {
+		t.Fatalf("expected about half the offered load to complete, got %.1f%%", g)
+	}
+}
+
+func TestFeasibilityComesFromTheSimulation(t *testing.T) {
+	s := baseScenario()
+	// every request after the first hits and skips compute; the analytic
+	// model treats replay caches as misses and puts capacity at 100 rps
+	s.Pipeline = append([]schema.Stage{{Name: "lookup", Kind: schema.StageCache, Value: 0.1, Cache: &schema.CacheConfig{
+		Model: schema.CacheReplay, Keys: []string{"hot"}, OnHit: schema.CacheSkip,
+	}}}, s.Pipeline...)
+	res, err := MaxRPS(s, SLO{P99MS: 50}, Options{Replications: 1, MaxRPS: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Binding != ConstraintSearch || res.MaxRPS != 150 {
+		t.Fatalf("expected 150 rps to pass in simulation, got %+v", res)
+	}
+}
@@ -0,0 +1,324 @@
+// Package compare diffs runs against a baseline: the scenarios field by
+// field, the summaries metric by metric and the stage aggregates stage by
+// stage, and flags regressions that exceed configured thresholds.
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sweep"
+)
+
+// Run is one side of a comparison.
+type Run struct {
+	ID       string
+	Scenario schema.Scenario
+	Summary  schema.Summary
+	Stages   []schema.StageAggregate
+}
+
+// Threshold bounds how far a summary metric may move from the baseline, in
+// percent of the baseline value. Zero leaves that direction unchecked; use
+// MaxIncreasePct for latencies and MaxDecreasePct for throughput.
+type Threshold struct {
+	Metric         string  `json:"metric"`
+	MaxIncreasePct float64 `json:"max_increase_percent,omitempty"`
+	MaxDecreasePct float64 `json:"max_decrease_percent,omitempty"`
+}
+
+// DefaultThresholds apply when a spec names none: p99 may not rise and
+// throughput may not fall by more than 10%.
+func DefaultThresholds() []Threshold {
+	return []Threshold{
+		{Metric: "p99_ms", MaxIncreasePct: 10},
+		{Metric: "throughput_rps", MaxDecreasePct: 10},
+	}
+}
+
+// FieldDiff is a scenario field whose value differs between runs. Values
+// holds one entry per run, in input order, nil where the field is absent.
+type FieldDiff struct {
+	Path   string        `json:"path"`
+	Values []interface{} `json:"values"`
+}
+
+// MetricDelta compares one summary metric with the baseline. Ratio is
+// Value/Baseline and is omitted when the baseline is zero.
+type MetricDelta struct {
+	Metric   string   `json:"metric"`
+	Baseline float64  `json:"baseline"`
+	Value    float64  `json:"value"`
+	Delta    float64  `json:"delta"`
+	DeltaPct *float64 `json:"delta_percent,omitempty"`
+	Ratio    *float64 `json:"ratio,omitempty"`
+}
+
+// StageDelta compares one stage aggregate with the baseline. Status is
+// "added" or "removed" when the stage exists on one side only.
+type StageDelta struct {
+	Name          string  `json:"name"`
+	Category      string  `json:"category"`
+	Status        string  `json:"status,omitempty"`
+	BaselineAvgMS float64 `json:"baseline_avg_ms"`
+	AvgMS         float64 `json:"avg_ms"`
+	DeltaAvgMS    float64 `json:"delta_avg_ms"`
+	BaselineP99MS float64 `json:"baseline_p99_ms"`
+	P99MS         float64 `json:"p99_ms"`
+	DeltaP99MS    float64 `json:"delta_p99_ms"`
+}
+
+// Comparison is one run measured against the baseline.
+type Comparison struct {
+	Run     string        `json:"run"`
+	Metrics []MetricDelta `json:"metrics"`
+	Stages  []StageDelta  `json:"stages"`
+}
+
+// Regression is a threshold a run exceeded. ChangePct is +Inf, reported as
+// null, when the baseline is zero.
+type Regression struct {
+	Run       string  `json:"run"`
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Value     float64 `json:"value"`
+	ChangePct float64 `json:"change_percent"`
+	LimitPct  float64 `json:"limit_percent"`
+}
+
+// Verdict summarizes the threshold checks over every compared run.
+type Verdict struct {
+	Regressed   bool         `json:"regressed"`
+	Thresholds  []Threshold  `json:"thresholds"`
+	Regressions []Regression `json:"regressions"`
+}
+
+// Result is the outcome of a comparison.
+type Result struct {
+	Baseline    string       `json:"baseline"`
+	Runs        []string     `json:"runs"`
+	Scenario    []FieldDiff  `json:"scenario_diff"`
+	Comparisons []Comparison `json:"comparisons"`
+	Verdict     Verdict      `json:"verdict"`
+}
+
+// Compare measures every run against runs[baseline].
+func Compare(runs []Run, baseline int, thresholds []Threshold) (Result, error) {
+	if len(runs) < 2 {
+		return Result{}, fmt.Errorf("at least two runs are required")
+	}
+	if baseline < 0 || baseline >= len(runs) {
+		return Result{}, fmt.Errorf("baseline index %d out of range", baseline)
+	}
+	if len(thresholds) == 0 {
+		thresholds = DefaultThresholds()
+	}
+	for _, th := range thresholds {
+		if !sweep.IsMetric(th.Metric) {
+			return Result{}, fmt.Errorf("unknown metric %q", th.Metric)
+		}
+		if th.MaxIncreasePct < 0 || th.MaxDecreasePct < 0 {
+			return Result{}, fmt.Errorf("%s: thresholds must be non-negative", th.Metric)
+		}
+	}
+
+	base := runs[baseline]
+	res := Result{
+		Baseline:    base.ID,
+		Comparisons: []Comparison{},
+		Verdict:     Verdict{Thresholds: thresholds, Regressions: []Regression{}},
+	}
+	for _, r := range runs {
+		res.Runs = append(res.Runs, r.ID)
+	}
+	diff, err := scenarioDiff(runs)
+	if err != nil {
+		return Result{}, err
+	}
+	res.Scenario = diff
+
+	baseMetrics := sweep.Metrics(base.Summary)
+	for i, r := range runs {
+		if i == baseline {
+			continue
+		}
+		metrics := sweep.Metrics(r.Summary)
+		res.Comparisons = append(res.Comparisons, Comparison{
+			Run:     r.ID,
+			Metrics: metricDeltas(baseMetrics, metrics),
+			Stages:  stageDeltas(base.Stages, r.Stages),
+		})
+		for _, th := range thresholds {
+			if reg, ok := check(th, r.ID, baseMetrics[th.Metric], metrics[th.Metric]); ok {
+				res.Verdict.Regressions = append(res.Verdict.Regressions, reg)
+			}
+		}
+	}
+	res.Verdict.Regressed = len(res.Verdict.Regressions) > 0
+	return res, nil
+}
+
+// check reports whether value moved past th relative to base.
+func check(th Threshold, run string, base, value float64) (Regression, bool) {
+	reg := Regression{Run: run, Metric: th.Metric, Baseline: base, Value: value}
+	switch {
+	case base != 0:
+		reg.ChangePct = (value - base) / math.Abs(base) * 100
+	case value > 0:
+		reg.ChangePct = math.Inf(1)
+	case value < 0:
+		reg.ChangePct = math.Inf(-1)
+	}
+	if th.MaxIncreasePct > 0 && reg.ChangePct > th.MaxIncreasePct {
+		reg.LimitPct = th.MaxIncreasePct
+		return reg, true
+	}
+	if th.MaxDecreasePct > 0 && -reg.ChangePct > th.MaxDecreasePct {
+		reg.LimitPct = -th.MaxDecreasePct
+		return reg, true
+	}
+	return Regression{}, false
+}
+
+// MarshalJSON writes an unbounded change as null, since JSON has no
+// infinity.
+func (r Regression) MarshalJSON() ([]byte, error) {
+	type plain Regression
+	out := struct {
+		plain
+		ChangePct *float64 `json:"change_percent"`
+	}{plain: plain(r)}
+	if !math.IsInf(r.ChangePct, 0) {
+		out.ChangePct = &r.ChangePct
+	}
+	return json.Marshal(out)
+}
+
+func metricDeltas(base, other map[string]float64) []MetricDelta {
+	names := make([]string, 0, len(base))
+	for name := range base {
+		if base[name] != 0 || other[name] != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	out := make([]MetricDelta, 0, len(names))
+	for _, name := range names {
+		d := MetricDelta{Metric: name, Baseline: base[name], Value: other[name], Delta: other[name] - base[name]}
+		if d.Baseline != 0 {
+			pct := d.Delta / math.Abs(d.Baseline) * 100
+			ratio := d.Value / d.Baseline
+			d.DeltaPct, d.Ratio = &pct, &ratio
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+type stageKey struct{ cat, name string }
+
+func stageDeltas(base, other []schema.StageAggregate) []StageDelta {
+	byKey := map[stageKey]*schema.StageAggregate{}
+	for i := range other {
+		byKey[stageKey{other[i].Category, other[i].Name}] = &other[i]
+	}
+	out := []StageDelta{}
+	for _, b := range base {
+		key := stageKey{b.Category, b.Name}
+		d := StageDelta{Name: b.Name, Category: b.Category, BaselineAvgMS: b.AvgMS, BaselineP99MS: b.P99MS}
+		if o := byKey[key]; o != nil {
+			d.AvgMS, d.P99MS = o.AvgMS, o.P99MS
+			delete(byKey, key)
+		} else {
+			d.Status = "removed"
+		}
+		d.DeltaAvgMS, d.DeltaP99MS = d.AvgMS-d.BaselineAvgMS, d.P99MS-d.BaselineP99MS
+		out = append(out, d)
+	}
+	for _, o := range byKey {
+		out = append(out, StageDelta{
+			Name: o.Name, Category: o.Category, Status: "added",
+			AvgMS: o.AvgMS, DeltaAvgMS: o.AvgMS, P99MS: o.P99MS, DeltaP99MS: o.P99MS,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Category != out[j].Category {
+			return out[i].Category < out[j].Category
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// StagesFromReport turns the sketch-based stage statistics of a streaming
+// run into stage aggregates, for runs whose breakdown kept no requests.
+func StagesFromReport(rep schema.AggregateReport) []schema.StageAggregate {
+	out := make([]schema.StageAggregate, 0, len(rep.Stages))
+	for _, s := range rep.Stages {
+		out = append(out, schema.StageAggregate{
+			Name:     s.Name,
+			Category: s.Category,
+			AvgMS:    s.MeanMS,
+			TotalMS:  s.MeanMS * float64(s.Count),
+			Count:    s.Count,
+			P50MS:    s.P50MS,
+			P90MS:    s.P90MS,
+			P99MS:    s.P99MS,
+			MaxMS:    s.MaxMS,
+		})
+	}
+	return out
+}
+
+// scenarioDiff flattens each scenario to leaf paths in the "a.b[2].c" form
+// sweeps and sensitivity analyses accept, and keeps the paths where any run
+// differs.
+func scenarioDiff(runs []Run) ([]FieldDiff, error) {
+	flat := make([]map[string]interface{}, len(runs))
+	paths := map[string]bool{}
+	for i, r := range runs {
+		leaves, err := sweep.Leaves(r.Scenario)
+		if err != nil {
+			return nil, err
+		}
+		flat[i] = leaves
+		for p := range flat[i] {
+			paths[p] = true
+		}
+	}
+	out := []FieldDiff{}
+	for p := range paths {
+		d := FieldDiff{Path: p, Values: make([]interface{}, len(runs))}
+		v0, ok0 := flat[0][p]
+		same := true
+		for i := range runs {
+			v, ok := flat[i][p]
+			d.Values[i] = v
+			if ok != ok0 || !reflect.DeepEqual(v, v0) {
+				same = false
+			}
+		}
+		if !same {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// Label renders a field value for text output.
+func Label(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	raw, _ := json.Marshal(v)
+	return strings.TrimSpace(string(raw))
+}
@@ -0,0 +1,104 @@
+package compare
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func scenario(tflops float64, stages ...string) schema.Scenario {
+	s := schema.Scenario{
+		Name:     "s",
+		Workload: schema.Workload{Name: "w", RPS: 10, Duration: 1, Batch: 1},
+		Target:   schema.GPUProfile{Name: "G", TFLOPS: tflops, Concurrency: 1},
+	}
+	for _, name := range stages {
+		s.Pipeline = append(s.Pipeline, schema.Stage{Name: name, Kind: schema.StageTokens, Value: 10})
+	}
+	return s
+}
+
+func TestCompareDiffsAndVerdict(t *testing.T) {
+	runs := []Run{
+		{
+			ID: "base", Scenario: scenario(100, "prefill"),
+			Summary: schema.Summary{P99LatencyMS: 100, Throughput: 50},
+			Stages:  []schema.StageAggregate{{Name: "prefill", Category: "compute", AvgMS: 8, P99MS: 12}},
+		},
+		{
+			ID: "slower", Scenario: scenario(80, "prefill", "decode"),
+			Summary: schema.Summary{P99LatencyMS: 125, Throughput: 49},
+			Stages: []schema.StageAggregate{
+				{Name: "prefill", Category: "compute", AvgMS: 10, P99MS: 15},
+				{Name: "decode", Category: "compute", AvgMS: 3, P99MS: 4},
+			},
+		},
+		{ID: "faster", Scenario: scenario(100, "prefill"), Summary: schema.Summary{P99LatencyMS: 90, Throughput: 40}},
+	}
+	res, err := Compare(runs, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := map[string][]interface{}{}
+	for _, d := range res.Scenario {
+		paths[d.Path] = d.Values
+	}
+	if v := paths["target.tflops"]; len(v) != 3 || v[0] != 100.0 || v[1] != 80.0 {
+		t.Fatalf("tflops diff %v in %+v", v, res.Scenario)
+	}
+	if v := paths["pipeline[1].name"]; v == nil || v[0] != nil || v[1] != "decode" {
+		t.Fatalf("added stage should show up by path: %v", v)
+	}
+	if _, ok := paths["workload.rps"]; ok {
+		t.Fatalf("unchanged fields should not be listed")
+	}
+
+	slower := res.Comparisons[0]
+	for _, m := range slower.Metrics {
+		if m.Metric == "p99_ms" && (m.Delta != 25 || *m.Ratio != 1.25 || *m.DeltaPct != 25) {
+			t.Fatalf("p99 delta %+v", m)
+		}
+	}
+	if len(slower.Stages) != 2 || slower.Stages[0].Name != "decode" || slower.Stages[0].Status != "added" || slower.Stages[1].DeltaP99MS != 3 {
+		t.Fatalf("stage deltas %+v", slower.Stages)
+	}
+
+	if !res.Verdict.Regressed || len(res.Verdict.Regressions) != 2 {
+		t.Fatalf("verdict %+v", res.Verdict)
+	}
+	if r := res.Verdict.Regressions[0]; r.Run != "slower" || r.Metric != "p99_ms" || r.LimitPct != 10 {
+		t.Fatalf("p99 regression %+v", r)
+	}
+	if r := res.Verdict.Regressions[1]; r.Run != "faster" || r.Metric != "throughput_rps" || r.ChangePct != -20 {
+		t.Fatalf("throughput regression %+v", r)
+	}
+
+	res, _ = Compare(runs[:2], 0, []Threshold{{Metric: "p99_ms", MaxIncreasePct: 30}})
+	if res.Verdict.Regressed {
+		t.Fatalf("25%% is within a 30%% threshold: %+v", res.Verdict)
+	}
+	if _, err := Compare(runs, 0, []Threshold{{Metric: "nope", MaxIncreasePct: 1}}); err == nil {
+		t.Fatal("expected an unknown metric error")
+	}
+	if _, err := Compare(runs[:1], 0, nil); err == nil {
+		t.Fatal("expected an error for a single run")
+	}
+}
+
+func TestUnboundedRegressionMarshalsAsNull(t *testing.T) {
+	runs := []Run{
+		{ID: "a", Scenario: scenario(1)},
+		{ID: "b", Scenario: scenario(1), Summary: schema.Summary{AvgQueueMS: 5}},
+	}
+	res, err := Compare(runs, 0, []Threshold{{Metric: "avg_queue_ms", MaxIncreasePct: 1}})
+	if err != nil || !res.Verdict.Regressed {
+		t.Fatalf("queueing from zero should regress: %+v %v", res.Verdict, err)
+	}
+	raw, err := json.Marshal(res.Verdict.Regressions[0])
+	if err != nil || !strings.Contains(string(raw), `"change_percent":null`) {
+		t.Fatalf("got %s, %v", raw, err)
+	}
+}
@@ -0,0 +1,376 @@
+// Package diagnose classifies where a run or a real trace spends its time:
+// GPU-bound, transfer-bound, CPU-bound or queue-bound. It reports the
+// evidence behind the verdict, the share of span time per resource, binned
+// occupancy per category and the windows in which the GPU or the queue
+// stayed saturated.
+package diagnose
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"simulator/pkg/schema"
+)
+
+// Verdicts.
+const (
+	GPUBound      = "GPU-bound"
+	TransferBound = "Transfer-bound"
+	CPUBound      = "CPU-bound"
+	QueueBound    = "Queue-bound"
+	Balanced      = "Balanced"
+)
+
+// Resources spans are attributed to.
+const (
+	ResourceGPU      = "gpu"
+	ResourceTransfer = "transfer"
+	ResourceCPU      = "cpu"
+	ResourceQueue    = "queue"
+	ResourceOther    = "other"
+)
+
+// Defaults for Options.
+const (
+	DefaultBins        = 80
+	DefaultMinWindowMS = 300
+)
+
+// Saturation thresholds on binned occupancy.
+const (
+	gpuSaturated   = 0.85
+	queueSaturated = 0.05
+)
+
+// Span is one interval of work or waiting.
+type Span struct {
+	Name    string
+	Cat     string
+	StartMS float64
+	EndMS   float64
+}
+
+// Options tune the analysis. GPUSlots is the number of GPU spans that can
+// run at once (concurrency slots for a simulated run, 1 for a real trace);
+// GPU occupancy is divided by it. Transfer and queue occupancy count any
+// activity in a bin, as the browser timeline does. Saturation windows
+// shorter than MinWindowMS, capped at a tenth of the trace, are ignored.
+// SampleEvery is set when the spans cover every n-th request only; each
+// span then counts n times towards occupancy and time.
+type Options struct {
+	Bins        int     `json:"bins,omitempty"`
+	GPUSlots    int     `json:"gpu_slots,omitempty"`
+	MinWindowMS float64 `json:"min_window_ms,omitempty"`
+	SampleEvery int     `json:"sample_every,omitempty"`
+}
+
+// Shares holds one value per resource.
+type Shares struct {
+	Queue    float64 `json:"queue"`
+	GPU      float64 `json:"gpu"`
+	Transfer float64 `json:"transfer"`
+	CPU      float64 `json:"cpu"`
+	Other    float64 `json:"other,omitempty"`
+}
+
+// Window is an interval in which a resource stayed saturated.
+type Window struct {
+	Resource string  `json:"resource"`
+	StartMS  float64 `json:"start_ms"`
+	EndMS    float64 `json:"end_ms"`
+}
+
+// Report is the diagnosis. Shares are fractions of the summed span time;
+// Heat maps each span category to its per-bin occupancy in [0, 1].
+type Report struct {
+	Primary         string               `json:"primary"`
+	Evidence        []string             `json:"evidence"`
+	Shares          Shares               `json:"shares"`
+	TimeMS          Shares               `json:"time_ms"`
+	GPUBusyPct      float64              `json:"gpu_busy_percent"`
+	TransferBusyPct float64              `json:"transfer_busy_percent"`
+	Spans           int                  `json:"spans"`
+	StartMS         float64              `json:"start_ms"`
+	EndMS           float64              `json:"end_ms"`
+	BinWidthMS      float64              `json:"bin_width_ms"`
+	Heat            map[string][]float64 `json:"heat"`
+	Saturation      []Window             `json:"saturation"`
+}
+
+// Resource maps a span category to the resource it occupies.
+func Resource(cat string) string {
+	switch strings.ToLower(cat) {
+	case "gpu", "compute", "swap":
+		return ResourceGPU
+	case "h2d", "d2h", "mem", "comm", "network", "storage":
+		return ResourceTransfer
+	case "cpu", "cache":
+		return ResourceCPU
+	case "queue":
+		return ResourceQueue
+	}
+	return ResourceOther
+}
+
+// FromRequests flattens the stage timelines of a run breakdown.
+func FromRequests(reqs []schema.RequestBreakdown) []Span {
+	var spans []Span
+	for _, r := range reqs {
+		for _, st := range r.Stages {
+			spans = append(spans, Span{Name: st.Name, Cat: st.Cat, StartMS: st.Start, EndMS: st.End})
+		}
+	}
+	return spans
+}
+
+// GPUSlots is the GPU capacity of a target as seen by FromRequests: one
+// stage span per concurrency slot and pipeline-parallel group, however wide
+// the tensor-parallel group running it.
+func GPUSlots(g schema.GPUProfile) int {
+	slots := g.Concurrency
+	if g.Parallel != nil {
+		slots *= g.Parallel.PipelineParallel
+	}
+	return slots
+}
+
+type traceEvent struct {
+	Name string  `json:"name"`
+	Cat  string  `json:"cat"`
+	Ph   string  `json:"ph"`
+	Ts   float64 `json:"ts"`
+	Dur  float64 `json:"dur"`
+	Pid  int     `json:"pid"`
+	Tid  int     `json:"tid"`
+}
+
+// FromTrace reads complete ("X") and matched begin/end ("B"/"E") events
+// from Chrome trace JSON, either an object with traceEvents or a bare array.
+// Spans without a category are filed under "tid-<n>".
+func FromTrace(data []byte) ([]Span, error) {
+	var doc struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		if err := json.Unmarshal(data, &doc.TraceEvents); err != nil {
+			return nil, fmt.Errorf("parse trace: %w", err)
+		}
+	}
+	var spans []Span
+	open := map[string][]traceEvent{}
+	cat := func(ev traceEvent) string {
+		if ev.Cat != "" {
+			return strings.ToLower(ev.Cat)
+		}
+		return fmt.Sprintf("tid-%d", ev.Tid)
+	}
+	for _, ev := range doc.TraceEvents {
+		switch ev.Ph {
+		case "X":
+			spans = append(spans, Span{Name: ev.Name, Cat: cat(ev), StartMS: ev.Ts / 1000, EndMS: (ev.Ts + ev.Dur) / 1000})
+		case "B":
+			key := fmt.Sprintf("%d:%d:%s", ev.Pid, ev.Tid, ev.Name)
+			open[key] = append(open[key], ev)
+		case "E":
+			key := fmt.Sprintf("%d:%d:%s", ev.Pid, ev.Tid, ev.Name)
+			stack := open[key]
+			if len(stack) == 0 {
+				continue
+			}
+			b := stack[len(stack)-1]
+			open[key] = stack[:len(stack)-1]
+			spans = append(spans, Span{Name: b.Name, Cat: cat(b), StartMS: b.Ts / 1000, EndMS: ev.Ts / 1000})
+		}
+	}
+	return spans, nil
+}
+
+// Analyze diagnoses the spans.
+func Analyze(spans []Span, opts Options) (Report, error) {
+	if len(spans) == 0 {
+		return Report{}, fmt.Errorf("no spans to diagnose")
+	}
+	if opts.Bins <= 0 {
+		opts.Bins = DefaultBins
+	}
+	if opts.GPUSlots <= 0 {
+		opts.GPUSlots = 1
+	}
+	if opts.MinWindowMS <= 0 {
+		opts.MinWindowMS = DefaultMinWindowMS
+	}
+	weight := 1.0
+	if opts.SampleEvery > 1 {
+		weight = float64(opts.SampleEvery)
+	}
+
+	start, end := math.Inf(1), math.Inf(-1)
+	for _, s := range spans {
+		start = math.Min(start, s.StartMS)
+		end = math.Max(end, s.EndMS)
+	}
+	window := end - start
+	if window <= 0 {
+		window = 1
+	}
+	bins := opts.Bins
+	width := window / float64(bins)
+	rep := Report{Spans: len(spans), StartMS: start, EndMS: end, BinWidthMS: width, Heat: map[string][]float64{}}
+
+	gpu := make([]float64, bins)
+	transfer := make([]float64, bins)
+	queue := make([]float64, bins)
+	var total float64
+	for _, s := range spans {
+		dur := s.EndMS - s.StartMS
+		if dur <= 0 {
+			continue
+		}
+		dur *= weight
+		total += dur
+		res := Resource(s.Cat)
+		*share(&rep.TimeMS, res) += dur
+
+		heat := rep.Heat[s.Cat]
+		if heat == nil {
+			heat = make([]float64, bins)
+			rep.Heat[s.Cat] = heat
+		}
+		first := int((s.StartMS - start) / width)
+		if first < 0 {
+			first = 0
+		}
+		last := int((s.EndMS - start) / width)
+		if last > bins-1 {
+			last = bins - 1
+		}
+		for b := first; b <= last; b++ {
+			lo := start + float64(b)*width
+			overlap := math.Min(s.EndMS, lo+width) - math.Max(s.StartMS, lo)
+			if overlap <= 0 {
+				continue
+			}
+			ratio := overlap / width * weight
+			heat[b] = math.Min(1, heat[b]+ratio)
+			switch res {
+			case ResourceGPU:
+				gpu[b] += ratio / float64(opts.GPUSlots)
+			case ResourceTransfer:
+				transfer[b] = math.Min(1, transfer[b]+ratio)
+			case ResourceQueue:
+				queue[b] = math.Min(1, queue[b]+ratio)
+			}
+		}
+	}
+	for b := range gpu {
+		gpu[b] = math.Min(1, gpu[b])
+	}
+	if total > 0 {
+		rep.Shares = Shares{
+			Queue:    rep.TimeMS.Queue / total,
+			GPU:      rep.TimeMS.GPU / total,
+			Transfer: rep.TimeMS.Transfer / total,
+			CPU:      rep.TimeMS.CPU / total,
+			Other:    rep.TimeMS.Other / total,
+		}
+	}
+	rep.GPUBusyPct = mean(gpu) * 100
+	rep.TransferBusyPct = mean(transfer) * 100
+
+	minMS := math.Min(opts.MinWindowMS, window/10)
+	rep.Saturation = append(windows(gpu, ResourceGPU, gpuSaturated, start, width, minMS),
+		windows(queue, ResourceQueue, queueSaturated, start, width, minMS)...)
+	sort.SliceStable(rep.Saturation, func(i, j int) bool { return rep.Saturation[i].StartMS < rep.Saturation[j].StartMS })
+
+	rep.Primary = classify(rep)
+	rep.Evidence = evidence(rep)
+	return rep, nil
+}
+
+func share(s *Shares, res string) *float64 {
+	switch res {
+	case ResourceGPU:
+		return &s.GPU
+	case ResourceTransfer:
+		return &s.Transfer
+	case ResourceCPU:
+		return &s.CPU
+	case ResourceQueue:
+		return &s.Queue
+	}
+	return &s.Other
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// windows returns the runs of bins at or above threshold lasting minMS or
+// more.
+func windows(occ []float64, res string, threshold, start, width, minMS float64) []Window {
+	out := []Window{}
+	from := -1
+	flush := func(to int) {
+		if from >= 0 && float64(to-from)*width >= minMS {
+			out = append(out, Window{Resource: res, StartMS: start + float64(from)*width, EndMS: start + float64(to)*width})
+		}
+		from = -1
+	}
+	for i, v := range occ {
+		switch {
+		case v >= threshold && from < 0:
+			from = i
+		case v < threshold && from >= 0:
+			flush(i)
+		}
+	}
+	flush(len(occ))
+	return out
+}
+
+func classify(r Report) string {
+	s := r.Shares
+	switch {
+	case s.Queue > math.Max(s.GPU, math.Max(s.Transfer, s.CPU)) && s.Queue > 0.35:
+		return QueueBound
+	case s.GPU > 0.45 || r.GPUBusyPct > 85:
+		return GPUBound
+	case s.Transfer > 0.35 || r.TransferBusyPct > 70:
+		return TransferBound
+	case s.CPU > 0.4:
+		return CPUBound
+	}
+	return Balanced
+}
+
+func evidence(r Report) []string {
+	s := r.Shares
+	e := []string{
+		fmt.Sprintf("GPU busy %.0f%%", r.GPUBusyPct),
+		fmt.Sprintf("Queue share %.0f%%", s.Queue*100),
+		fmt.Sprintf("Transfer share %.0f%%", s.Transfer*100),
+		fmt.Sprintf("CPU share %.0f%%", s.CPU*100),
+		fmt.Sprintf("GPU share %.0f%%", s.GPU*100),
+		fmt.Sprintf("Transfer busy %.0f%%", r.TransferBusyPct),
+	}
+	satMS := map[string]float64{}
+	for _, w := range r.Saturation {
+		satMS[w.Resource] += w.EndMS - w.StartMS
+	}
+	for _, res := range []string{ResourceGPU, ResourceQueue} {
+		if ms := satMS[res]; ms > 0 {
+			e = append(e, fmt.Sprintf("%s saturated for %.0f ms (%.0f%% of the trace)", res, ms, ms/(r.EndMS-r.StartMS)*100))
+		}
+	}
+	return e
+}
@@ -0,0 +1,93 @@
+package diagnose
+
+import (
+	"math"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func TestQueueBoundWithSaturationWindow(t *testing.T) {
+	// Two slots kept busy back to back while a third request always waits.
+	var reqs []schema.RequestBreakdown
+	for i := 0; i < 100; i++ {
+		at := float64(i) * 10
+		reqs = append(reqs,
+			schema.RequestBreakdown{Stages: []schema.StageTiming{{Name: "prefill", Cat: "compute", Start: at, End: at + 10}}},
+			schema.RequestBreakdown{Stages: []schema.StageTiming{{Name: "prefill", Cat: "compute", Start: at, End: at + 10}}},
+			schema.RequestBreakdown{Stages: []schema.StageTiming{{Name: "queue", Cat: "queue", Start: at, End: at + 40}}},
+		)
+	}
+	rep, err := Analyze(FromRequests(reqs), Options{GPUSlots: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Primary != QueueBound {
+		t.Fatalf("primary %s, shares %+v", rep.Primary, rep.Shares)
+	}
+	if math.Abs(rep.Shares.Queue+rep.Shares.GPU-1) > 1e-9 || math.Abs(rep.Shares.Queue-2.0/3) > 1e-9 {
+		t.Fatalf("shares %+v", rep.Shares)
+	}
+	if rep.GPUBusyPct < 95 {
+		t.Fatalf("two of two slots busy should read near 100%%, got %v", rep.GPUBusyPct)
+	}
+	var gpu, queue bool
+	for _, w := range rep.Saturation {
+		gpu = gpu || w.Resource == ResourceGPU && w.EndMS-w.StartMS >= 300
+		queue = queue || w.Resource == ResourceQueue
+	}
+	if !gpu || !queue {
+		t.Fatalf("expected gpu and queue saturation windows: %+v", rep.Saturation)
+	}
+	if len(rep.Heat["compute"]) != DefaultBins || len(rep.Evidence) == 0 {
+		t.Fatalf("heat and evidence should be filled")
+	}
+}
+
+func TestGPUSlotsScaleOccupancy(t *testing.T) {
+	spans := []Span{{Name: "k", Cat: "compute", StartMS: 0, EndMS: 1000}}
+	one, _ := Analyze(spans, Options{})
+	four, _ := Analyze(spans, Options{GPUSlots: 4})
+	if math.Abs(one.GPUBusyPct-100) > 1e-9 || math.Abs(four.GPUBusyPct-25) > 1e-9 {
+		t.Fatalf("busy %v with one slot, %v with four", one.GPUBusyPct, four.GPUBusyPct)
+	}
+	if one.Primary != GPUBound {
+		t.Fatalf("primary %s", one.Primary)
+	}
+	if _, err := Analyze(nil, Options{}); err == nil {
+		t.Fatal("expected an error without spans")
+	}
+}
+
+func TestSampledSpansCountForTheirRequests(t *testing.T) {
+	// every 4th request of four slots' worth of back-to-back work
+	spans := []Span{{Name: "k", Cat: "compute", StartMS: 0, EndMS: 1000}}
+	rep, _ := Analyze(spans, Options{GPUSlots: 4, SampleEvery: 4})
+	if math.Abs(rep.GPUBusyPct-100) > 1e-9 || rep.TimeMS.GPU != 4000 {
+		t.Fatalf("busy %v, gpu time %v", rep.GPUBusyPct, rep.TimeMS.GPU)
+	}
+}
+
+func TestFromTraceReadsCompleteAndBeginEnd(t *testing.T) {
+	data := []byte(`{"traceEvents":[
+		{"name":"gemm","cat":"gpu","ph":"X","ts":0,"dur":4000,"tid":3},
+		{"name":"memcpy","cat":"h2d","ph":"B","ts":4000,"tid":2},
+		{"name":"memcpy","cat":"h2d","ph":"E","ts":10000,"tid":2},
+		{"name":"load","ph":"X","ts":0,"dur":1000,"tid":9},
+		{"name":"depth","ph":"C","ts":0}
+	]}`)
+	spans, err := FromTrace(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 3 || spans[1].Cat != "h2d" || spans[1].EndMS != 10 || spans[2].Cat != "tid-9" {
+		t.Fatalf("spans %+v", spans)
+	}
+	rep, _ := Analyze(spans, Options{})
+	if rep.Primary != TransferBound || rep.Shares.Other == 0 {
+		t.Fatalf("primary %s shares %+v", rep.Primary, rep.Shares)
+	}
+	if _, err := FromTrace([]byte("nope")); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
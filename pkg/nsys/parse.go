@@ -75,6 +75,10 @@ func ParseSQLite(path string) ([]byte, Metrics, error) {
 		rows.Close()
 	}
 
+	tr.SetProcessName("nsys")
+	tr.SetThreadName(2, "memcpy")
+	tr.SetThreadName(3, "kernels")
+	tr.Finalize()
 	traceBytes, err := tr.Marshal()
 	if err != nil {
 		return nil, Metrics{}, err
@@ -0,0 +1,309 @@
+// Package optimize searches candidate GPU profiles and scenario knobs for
+// the Pareto frontier over a set of summary metrics.
+package optimize
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sweep"
+)
+
+// Goal is the direction an objective is optimized in.
+type Goal string
+
+const (
+	Minimize Goal = "min"
+	Maximize Goal = "max"
+)
+
+// DefaultBudget and MaxBudget bound the number of simulation runs, that is
+// configurations times replications.
+const (
+	DefaultBudget = 150
+	MaxBudget     = 1000
+)
+
+// DefaultReplications and MaxReplications bound the seeds run per
+// configuration.
+const (
+	DefaultReplications = 3
+	MaxReplications     = 10
+)
+
+// Objective names a summary metric by its JSON field, e.g. "p99_ms" or
+// "cost_per_1k_requests_usd".
+type Objective struct {
+	Metric string `json:"metric"`
+	Goal   Goal   `json:"goal"`
+}
+
+// Spec describes a search. Candidates replace the scenario's target; Knobs
+// are sweep axes over any other field. Budget is the number of simulation
+// runs, so Budget/Replications configurations are evaluated: the full grid
+// when it fits, otherwise distinct points sampled with Seed. Every point
+// runs on the same Replications seeds, starting at Seed, so points differ
+// only by their configuration.
+type Spec struct {
+	Scenario   schema.Scenario     `json:"scenario"`
+	Candidates []schema.GPUProfile `json:"candidates,omitempty"`
+	Knobs      []sweep.Axis        `json:"knobs,omitempty"`
+	Objectives []Objective         `json:"objectives,omitempty"`
+	Budget     int                 `json:"budget,omitempty"`
+	// Replications is the number of seeds each point's metrics are
+	// averaged over, default 3.
+	Replications int   `json:"replications,omitempty"`
+	Seed         int64 `json:"seed,omitempty"`
+	Workers      int   `json:"workers,omitempty"`
+
+	// Slots is passed to the sweeps; see sweep.Spec.
+	Slots chan struct{} `json:"-"`
+}
+
+// Point is one evaluated configuration; Metrics are means over the
+// replications.
+type Point struct {
+	Index    int                    `json:"index"`
+	Target   string                 `json:"target"`
+	Values   map[string]interface{} `json:"values,omitempty"` // knob settings
+	Metrics  map[string]float64     `json:"metrics"`
+	Scenario *schema.Scenario       `json:"scenario,omitempty"`
+}
+
+// Result holds the frontier, sorted by the first objective, and every
+// evaluated point that is not on it.
+type Result struct {
+	Objectives []Objective `json:"objectives"`
+	Mode       sweep.Mode  `json:"mode"`
+	Evaluated  int         `json:"evaluated"`
+	Runs       int         `json:"runs"`
+	Failed     int         `json:"failed"`
+	Frontier   []Point     `json:"frontier"`
+	Dominated  []Point     `json:"dominated"`
+}
+
+const targetPath = "target"
+
+// DefaultObjectives trades tail latency against cost per request.
+func DefaultObjectives() []Objective {
+	return []Objective{
+		{Metric: "p99_ms", Goal: Minimize},
+		{Metric: "cost_per_1k_requests_usd", Goal: Minimize},
+	}
+}
+
+// Run evaluates the search space within the budget and returns the Pareto
+// frontier. Points with invalid scenarios are counted as failed.
+func Run(spec Spec) (Result, error) {
+	if len(spec.Objectives) == 0 {
+		spec.Objectives = DefaultObjectives()
+	}
+	for i, o := range spec.Objectives {
+		if !sweep.IsMetric(o.Metric) {
+			return Result{}, fmt.Errorf("objectives[%d]: unknown metric %q", i, o.Metric)
+		}
+		if o.Goal != Minimize && o.Goal != Maximize {
+			return Result{}, fmt.Errorf("objectives[%d]: goal must be min or max", i)
+		}
+	}
+	if spec.Budget == 0 {
+		spec.Budget = DefaultBudget
+	}
+	if spec.Budget < 1 || spec.Budget > MaxBudget {
+		return Result{}, fmt.Errorf("budget must be between 1 and %d", MaxBudget)
+	}
+	if spec.Replications == 0 {
+		spec.Replications = DefaultReplications
+	}
+	if spec.Replications < 1 || spec.Replications > MaxReplications {
+		return Result{}, fmt.Errorf("replications must be between 1 and %d", MaxReplications)
+	}
+	configs := spec.Budget / spec.Replications
+	if configs == 0 {
+		return Result{}, fmt.Errorf("budget must allow at least one point of %d replications", spec.Replications)
+	}
+
+	axes := append([]sweep.Axis(nil), spec.Knobs...)
+	if len(spec.Candidates) > 0 {
+		values := make([]interface{}, len(spec.Candidates))
+		for i, c := range spec.Candidates {
+			values[i] = c
+		}
+		axes = append([]sweep.Axis{{Path: targetPath, Values: values}}, axes...)
+	}
+	if len(axes) == 0 {
+		return Result{}, fmt.Errorf("at least one candidate or knob is required")
+	}
+	// A fixed seed keeps seed noise out of the comparison between points.
+	sw := sweep.Spec{Scenario: spec.Scenario, Axes: axes, Mode: sweep.ModeGrid, Seed: spec.Seed, FixedSeed: true, Workers: spec.Workers, Slots: spec.Slots}
+	mode := sweep.ModeGrid
+	if gridSize(axes) > configs {
+		mode = sweep.ModeRandom
+		if discrete(axes) {
+			sw.Mode = sweep.ModeList
+			sw.Axes = sampleGrid(axes, configs, spec.Seed)
+		} else {
+			sw.Mode = sweep.ModeRandom
+			sw.Samples = configs
+		}
+	}
+	points, err := sweep.Points(sw)
+	if err != nil {
+		return Result{}, err
+	}
+
+	rows := make([]sweep.Result, len(points))
+	sums := make([]map[string]float64, len(points))
+	for r := 0; r < spec.Replications; r++ {
+		sweep.Run(sw, points, spec.Seed+int64(r), func(row sweep.Result) {
+			if r == 0 || rows[row.Index].Summary != nil {
+				rows[row.Index] = row
+			}
+			if row.Summary == nil {
+				return
+			}
+			if sums[row.Index] == nil {
+				sums[row.Index] = map[string]float64{}
+			}
+			for k, v := range sweep.Metrics(*row.Summary) {
+				sums[row.Index][k] += v
+			}
+		})
+	}
+
+	res := Result{Objectives: spec.Objectives, Mode: mode, Evaluated: len(rows), Runs: len(rows) * spec.Replications}
+	var evaluated []Point
+	for i, row := range rows {
+		if row.Summary == nil {
+			res.Failed++
+			continue
+		}
+		p := newPoint(spec.Scenario, row)
+		p.Metrics = sums[i]
+		for k := range p.Metrics {
+			p.Metrics[k] /= float64(spec.Replications)
+		}
+		evaluated = append(evaluated, p)
+	}
+	for i, p := range evaluated {
+		onFrontier := true
+		for j, q := range evaluated {
+			if i != j && dominates(q, p, spec.Objectives) {
+				onFrontier = false
+				break
+			}
+		}
+		if !onFrontier {
+			res.Dominated = append(res.Dominated, p)
+			continue
+		}
+		sc, err := sweep.Apply(spec.Scenario, rows[p.Index].Values)
+		if err == nil {
+			p.Scenario = &sc
+		}
+		res.Frontier = append(res.Frontier, p)
+	}
+	first := spec.Objectives[0]
+	sort.SliceStable(res.Frontier, func(i, j int) bool {
+		return better(res.Frontier[i].Metrics[first.Metric], res.Frontier[j].Metrics[first.Metric], first.Goal)
+	})
+	return res, nil
+}
+
+func newPoint(base schema.Scenario, r sweep.Result) Point {
+	p := Point{Index: r.Index, Target: base.Target.Name}
+	for path, v := range r.Values {
+		if path == targetPath {
+			p.Target = v.(schema.GPUProfile).Name
+			continue
+		}
+		if p.Values == nil {
+			p.Values = map[string]interface{}{}
+		}
+		p.Values[path] = v
+	}
+	return p
+}
+
+// dominates reports whether a is at least as good as b on every objective
+// and strictly better on one.
+func dominates(a, b Point, objectives []Objective) bool {
+	strict := false
+	for _, o := range objectives {
+		x, y := a.Metrics[o.Metric], b.Metrics[o.Metric]
+		if better(y, x, o.Goal) {
+			return false
+		}
+		if better(x, y, o.Goal) {
+			strict = true
+		}
+	}
+	return strict
+}
+
+func better(x, y float64, goal Goal) bool {
+	if goal == Maximize {
+		return x > y
+	}
+	return x < y
+}
+
+func gridSize(axes []sweep.Axis) int {
+	n := 1
+	for _, a := range axes {
+		if len(a.Values) == 0 {
+			// continuous axes can only be sampled
+			return MaxBudget + 1
+		}
+		n *= len(a.Values)
+		if n > MaxBudget {
+			return n
+		}
+	}
+	return n
+}
+
+// discrete reports whether every axis lists its values, so the grid is
+// finite and sampled points can repeat.
+func discrete(axes []sweep.Axis) bool {
+	for _, a := range axes {
+		if len(a.Values) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sampleGrid draws n distinct points of the grid over axes, seeded by seed,
+// and returns them as list axes: value j of every axis forms point j. The
+// grid must have more than n points.
+func sampleGrid(axes []sweep.Axis, n int, seed int64) []sweep.Axis {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]sweep.Axis, len(axes))
+	for i, a := range axes {
+		out[i] = sweep.Axis{Path: a.Path, Values: make([]interface{}, 0, n)}
+	}
+	seen := map[string]bool{}
+	idx := make([]int, len(axes))
+	var key strings.Builder
+	for len(seen) < n {
+		key.Reset()
+		for i, a := range axes {
+			idx[i] = rng.Intn(len(a.Values))
+			key.WriteString(strconv.Itoa(idx[i]))
+			key.WriteByte(',')
+		}
+		if seen[key.String()] {
+			continue
+		}
+		seen[key.String()] = true
+		for i, a := range axes {
+			out[i].Values = append(out[i].Values, a.Values[idx[i]])
+		}
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package optimize
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sweep"
+)
+
+func gpu(name string, msPerToken, price float64) schema.GPUProfile {
+	return schema.GPUProfile{
+		Name:         name,
+		TFLOPS:       100,
+		MemGBps:      2000,
+		TokenCost:    msPerToken,
+		H2DBandwGB:   25,
+		D2HBandwGB:   25,
+		Concurrency:  2,
+		PricePerHour: price,
+	}
+}
+
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name:     "opt",
+		Workload: schema.Workload{Name: "wl", RPS: 20, Duration: 5, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		},
+		Target: gpu("base", 0.2, 1),
+	}
+}
+
+func TestFrontierDropsDominatedCandidates(t *testing.T) {
+	res, err := Run(Spec{
+		Scenario: baseScenario(),
+		Candidates: []schema.GPUProfile{
+			gpu("fast", 0.05, 4),
+			gpu("cheap", 0.2, 1),
+			gpu("slow-pricey", 0.3, 5),
+		},
+		Seed: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, p := range res.Frontier {
+		names[p.Target] = true
+		if p.Scenario == nil || p.Scenario.Target.Name != p.Target {
+			t.Fatalf("frontier point should carry its scenario: %+v", p)
+		}
+	}
+	if !names["fast"] || !names["cheap"] || names["slow-pricey"] {
+		t.Fatalf("unexpected frontier %v", names)
+	}
+	if len(res.Dominated) != 1 || res.Frontier[0].Target != "fast" {
+		t.Fatalf("expected frontier sorted by p99 and one dominated point: %+v", res)
+	}
+}
+
+func TestBudgetSamplesDeterministically(t *testing.T) {
+	spec := Spec{
+		Scenario:   baseScenario(),
+		Candidates: []schema.GPUProfile{gpu("a", 0.1, 2), gpu("b", 0.2, 1)},
+		Knobs: []sweep.Axis{
+			{Path: "target.concurrency", Values: []interface{}{1.0, 2.0, 4.0, 8.0}},
+			{Path: "workload.batch_size", Values: []interface{}{1.0, 2.0, 4.0}},
+		},
+		Objectives: []Objective{{Metric: "p99_ms", Goal: Minimize}, {Metric: "throughput_rps", Goal: Maximize}},
+		Budget:     18,
+		Seed:       9,
+	}
+	a, err := Run(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Mode != sweep.ModeRandom || a.Evaluated != 6 || a.Runs != 18 {
+		t.Fatalf("expected 6 sampled points in 18 runs, got %s/%d/%d", a.Mode, a.Evaluated, a.Runs)
+	}
+	b, _ := Run(spec)
+	if !reflect.DeepEqual(a.Frontier, b.Frontier) {
+		t.Fatal("same seed should give the same frontier")
+	}
+}
+
+func TestSampledPointsAreDistinct(t *testing.T) {
+	res, err := Run(Spec{
+		Scenario: baseScenario(),
+		Knobs: []sweep.Axis{
+			{Path: "target.concurrency", Values: []interface{}{1.0, 2.0, 4.0}},
+			{Path: "workload.batch_size", Values: []interface{}{1.0, 2.0, 4.0}},
+		},
+		Budget:       8,
+		Replications: 1,
+		Seed:         2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mode != sweep.ModeRandom || res.Evaluated != 8 {
+		t.Fatalf("expected 8 sampled points, got %s/%d", res.Mode, res.Evaluated)
+	}
+	seen := map[string]bool{}
+	for _, p := range append(res.Frontier, res.Dominated...) {
+		key := fmt.Sprint(p.Values["target.concurrency"], p.Values["workload.batch_size"])
+		if seen[key] {
+			t.Fatalf("point %s sampled twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestBudgetCoversReplications(t *testing.T) {
+	_, err := Run(Spec{
+		Scenario:     baseScenario(),
+		Candidates:   []schema.GPUProfile{gpu("a", 0.1, 2)},
+		Budget:       2,
+		Replications: 3,
+	})
+	if err == nil {
+		t.Fatal("expected a budget below one point's replications to fail")
+	}
+}
+
+func TestRejectsUnknownMetric(t *testing.T) {
+	_, err := Run(Spec{
+		Scenario:   baseScenario(),
+		Candidates: []schema.GPUProfile{gpu("a", 0.1, 2)},
+		Objectives: []Objective{{Metric: "nope", Goal: Minimize}},
+	})
+	if err == nil {
+		t.Fatal("expected unknown metric to fail")
+	}
+}
+
+func TestPointsShareSeeds(t *testing.T) {
+	res, err := Run(Spec{
+		Scenario:     baseScenario(),
+		Candidates:   []schema.GPUProfile{gpu("a", 0.2, 1), gpu("b", 0.2, 1)},
+		Replications: 2,
+		Seed:         3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Frontier) != 2 || !reflect.DeepEqual(res.Frontier[0].Metrics, res.Frontier[1].Metrics) {
+		t.Fatalf("identical candidates should tie on common seeds: %+v", res.Frontier)
+	}
+}
@@ -0,0 +1,125 @@
+package query
+
+import (
+	"sort"
+
+	"simulator/pkg/schema"
+)
+
+// waitCat labels stretches of the critical path where nothing in the
+// request's timeline was running.
+const waitCat = "wait"
+
+// epsMS absorbs rounding between one span's end and the next one's start.
+const epsMS = 1e-6
+
+// Segment is one span on the critical path.
+type Segment struct {
+	Name       string  `json:"name"`
+	Cat        string  `json:"cat"`
+	StartMS    float64 `json:"start_ms"`
+	EndMS      float64 `json:"end_ms"`
+	DurationMS float64 `json:"duration_ms"`
+	SharePct   float64 `json:"share_percent"`
+}
+
+// CategoryShare is the critical-path time spent in one category.
+type CategoryShare struct {
+	Cat      string  `json:"cat"`
+	MS       float64 `json:"ms"`
+	SharePct float64 `json:"share_percent"`
+}
+
+// CriticalPath is the chain of spans that determined when a request
+// finished, from arrival to its last span. Shortening any span off the path
+// would not make the request finish earlier.
+type CriticalPath struct {
+	TotalMS    float64         `json:"total_ms"`
+	Segments   []Segment       `json:"segments"`
+	Categories []CategoryShare `json:"categories"` // largest first
+	Bottleneck string          `json:"bottleneck,omitempty"`
+}
+
+// Detail is a request's full timeline with its critical path.
+type Detail struct {
+	schema.RequestBreakdown
+	CriticalPath CriticalPath `json:"critical_path"`
+}
+
+// Critical walks back from the span that ends last, each time to the span
+// that ended latest before the current one started. Overlapping spans, such
+// as tensor-parallel shards or a stream copy under compute, drop off the
+// path; gaps between spans become "wait" segments.
+func Critical(r schema.RequestBreakdown) CriticalPath {
+	if len(r.Stages) == 0 {
+		return CriticalPath{Segments: []Segment{}, Categories: []CategoryShare{}}
+	}
+	last := 0
+	for i, st := range r.Stages {
+		if st.End > r.Stages[last].End || (st.End == r.Stages[last].End && st.Start < r.Stages[last].Start) {
+			last = i
+		}
+	}
+	used := make([]bool, len(r.Stages))
+	var rev []Segment
+	cur := last
+	for {
+		used[cur] = true
+		st := r.Stages[cur]
+		rev = append(rev, Segment{Name: st.Name, Cat: st.Cat, StartMS: st.Start, EndMS: st.End})
+		prev := -1
+		for i, p := range r.Stages {
+			if used[i] || p.End > st.Start+epsMS {
+				continue
+			}
+			if prev < 0 || p.End > r.Stages[prev].End ||
+				(p.End == r.Stages[prev].End && p.Start < r.Stages[prev].Start) {
+				prev = i
+			}
+		}
+		from := r.ArrivalMS
+		if prev >= 0 {
+			from = r.Stages[prev].End
+		}
+		if st.Start-from > epsMS {
+			rev = append(rev, Segment{Name: waitCat, Cat: waitCat, StartMS: from, EndMS: st.Start})
+		}
+		if prev < 0 {
+			break
+		}
+		cur = prev
+	}
+
+	cp := CriticalPath{Segments: make([]Segment, 0, len(rev))}
+	start := rev[len(rev)-1].StartMS
+	cp.TotalMS = rev[0].EndMS - start
+	byCat := map[string]float64{}
+	var longest Segment
+	for i := len(rev) - 1; i >= 0; i-- {
+		s := rev[i]
+		s.DurationMS = s.EndMS - s.StartMS
+		if cp.TotalMS > 0 {
+			s.SharePct = s.DurationMS / cp.TotalMS * 100
+		}
+		byCat[s.Cat] += s.DurationMS
+		if s.DurationMS > longest.DurationMS {
+			longest = s
+		}
+		cp.Segments = append(cp.Segments, s)
+	}
+	cp.Bottleneck = longest.Name
+	for cat, ms := range byCat {
+		cs := CategoryShare{Cat: cat, MS: ms}
+		if cp.TotalMS > 0 {
+			cs.SharePct = ms / cp.TotalMS * 100
+		}
+		cp.Categories = append(cp.Categories, cs)
+	}
+	sort.Slice(cp.Categories, func(i, j int) bool {
+		if cp.Categories[i].MS != cp.Categories[j].MS {
+			return cp.Categories[i].MS > cp.Categories[j].MS
+		}
+		return cp.Categories[i].Cat < cp.Categories[j].Cat
+	})
+	return cp
+}
@@ -0,0 +1,211 @@
+// Package query filters, sorts and pages the per-request rows of a run
+// breakdown, and extracts the critical path of a single request.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"simulator/pkg/schema"
+)
+
+// Page size limits.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 1000
+)
+
+// Sort keys.
+const (
+	SortArrival = "arrival"
+	SortLatency = "latency"
+	SortQueue   = "queue"
+)
+
+// Spec selects and orders requests. Zero bounds are unset; the arrival
+// window is [FromMS, ToMS).
+type Spec struct {
+	Sort         string
+	Desc         bool
+	Limit        int
+	Offset       int
+	MinLatencyMS float64
+	MaxLatencyMS float64
+	MinQueueMS   float64
+	Class        string
+	FromMS       float64
+	ToMS         float64
+	Dropped      *bool
+}
+
+// Parse reads a Spec from URL query parameters: sort (arrival, latency,
+// queue), order (asc, desc), limit, offset, min_latency_ms, max_latency_ms,
+// min_queue_ms, class, from_ms, to_ms and dropped. Latency and queue sort
+// descending by default, arrival ascending.
+func Parse(v url.Values) (Spec, error) {
+	spec := Spec{Sort: v.Get("sort"), Limit: DefaultLimit}
+	switch spec.Sort {
+	case "":
+		spec.Sort = SortArrival
+	case SortArrival, SortLatency, SortQueue:
+	default:
+		return Spec{}, fmt.Errorf("sort must be arrival, latency or queue")
+	}
+	switch v.Get("order") {
+	case "":
+		spec.Desc = spec.Sort != SortArrival
+	case "asc":
+	case "desc":
+		spec.Desc = true
+	default:
+		return Spec{}, fmt.Errorf("order must be asc or desc")
+	}
+	ints := []struct {
+		name string
+		dst  *int
+	}{{"limit", &spec.Limit}, {"offset", &spec.Offset}}
+	for _, p := range ints {
+		if s := v.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return Spec{}, fmt.Errorf("%s must be a non-negative integer", p.name)
+			}
+			*p.dst = n
+		}
+	}
+	if spec.Limit == 0 || spec.Limit > MaxLimit {
+		return Spec{}, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+	floats := []struct {
+		name string
+		dst  *float64
+	}{
+		{"min_latency_ms", &spec.MinLatencyMS},
+		{"max_latency_ms", &spec.MaxLatencyMS},
+		{"min_queue_ms", &spec.MinQueueMS},
+		{"from_ms", &spec.FromMS},
+		{"to_ms", &spec.ToMS},
+	}
+	for _, p := range floats {
+		if s := v.Get(p.name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f < 0 {
+				return Spec{}, fmt.Errorf("%s must be a non-negative number", p.name)
+			}
+			*p.dst = f
+		}
+	}
+	if s := v.Get("dropped"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return Spec{}, fmt.Errorf("dropped must be true or false")
+		}
+		spec.Dropped = &b
+	}
+	spec.Class = v.Get("class")
+	return spec, nil
+}
+
+// Row is a request without its stage timeline.
+type Row struct {
+	ID        int              `json:"id"`
+	Class     string           `json:"class,omitempty"`
+	ArrivalMS float64          `json:"arrival_ms"`
+	StartMS   float64          `json:"start_ms"`
+	EndMS     float64          `json:"end_ms"`
+	QueueMS   float64          `json:"queue_ms"`
+	TotalMS   float64          `json:"total_ms"`
+	Stages    int              `json:"stages"`
+	Cache     string           `json:"cache,omitempty"`
+	Fault     schema.FaultKind `json:"fault,omitempty"`
+	Dropped   bool             `json:"dropped,omitempty"`
+}
+
+// Page is one page of matching requests. Total counts every match;
+// NextOffset is set when more remain.
+type Page struct {
+	Total      int   `json:"total"`
+	Offset     int   `json:"offset"`
+	Limit      int   `json:"limit"`
+	NextOffset *int  `json:"next_offset,omitempty"`
+	Requests   []Row `json:"requests"`
+}
+
+// Select applies spec to reqs without modifying them.
+func Select(reqs []schema.RequestBreakdown, spec Spec) Page {
+	var match []*schema.RequestBreakdown
+	for i := range reqs {
+		if spec.matches(&reqs[i]) {
+			match = append(match, &reqs[i])
+		}
+	}
+	key := func(r *schema.RequestBreakdown) float64 {
+		switch spec.Sort {
+		case SortLatency:
+			return r.TotalMS
+		case SortQueue:
+			return r.QueueMS
+		}
+		return r.ArrivalMS
+	}
+	sort.SliceStable(match, func(i, j int) bool {
+		a, b := key(match[i]), key(match[j])
+		if a == b {
+			return match[i].ID < match[j].ID
+		}
+		return (a < b) != spec.Desc
+	})
+
+	page := Page{Total: len(match), Offset: spec.Offset, Limit: spec.Limit, Requests: []Row{}}
+	if spec.Offset >= len(match) {
+		return page
+	}
+	end := spec.Offset + spec.Limit
+	if end < len(match) {
+		page.NextOffset = &end
+	} else {
+		end = len(match)
+	}
+	for _, r := range match[spec.Offset:end] {
+		page.Requests = append(page.Requests, Row{
+			ID:        r.ID,
+			Class:     r.Class,
+			ArrivalMS: r.ArrivalMS,
+			StartMS:   r.StartMS,
+			EndMS:     r.EndMS,
+			QueueMS:   r.QueueMS,
+			TotalMS:   r.TotalMS,
+			Stages:    len(r.Stages),
+			Cache:     r.Cache,
+			Fault:     r.Fault,
+			Dropped:   r.Dropped,
+		})
+	}
+	return page
+}
+
+func (s Spec) matches(r *schema.RequestBreakdown) bool {
+	switch {
+	case s.MinLatencyMS > 0 && r.TotalMS < s.MinLatencyMS,
+		s.MaxLatencyMS > 0 && r.TotalMS > s.MaxLatencyMS,
+		s.MinQueueMS > 0 && r.QueueMS < s.MinQueueMS,
+		s.Class != "" && r.Class != s.Class,
+		s.FromMS > 0 && r.ArrivalMS < s.FromMS,
+		s.ToMS > 0 && r.ArrivalMS >= s.ToMS,
+		s.Dropped != nil && r.Dropped != *s.Dropped:
+		return false
+	}
+	return true
+}
+
+// Find returns the request with the given id.
+func Find(reqs []schema.RequestBreakdown, id int) (schema.RequestBreakdown, bool) {
+	for _, r := range reqs {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return schema.RequestBreakdown{}, false
+}
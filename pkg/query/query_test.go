@@ -0,0 +1,102 @@
+package query
+
+import (
+	"math"
+	"net/url"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func rows() []schema.RequestBreakdown {
+	return []schema.RequestBreakdown{
+		{ID: 0, ArrivalMS: 0, QueueMS: 0, TotalMS: 10, Class: "chat"},
+		{ID: 1, ArrivalMS: 5, QueueMS: 4, TotalMS: 30, Class: "scan"},
+		{ID: 2, ArrivalMS: 10, QueueMS: 1, TotalMS: 20, Class: "chat"},
+		{ID: 3, ArrivalMS: 15, QueueMS: 9, TotalMS: 0, Class: "chat", Dropped: true},
+		{ID: 4, ArrivalMS: 20, QueueMS: 2, TotalMS: 30, Class: "scan"},
+	}
+}
+
+func ids(p Page) []int {
+	var out []int
+	for _, r := range p.Requests {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestSelectSortsFiltersAndPages(t *testing.T) {
+	spec, err := Parse(url.Values{"sort": {"latency"}, "limit": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Select(rows(), spec)
+	if got := ids(p); len(got) != 2 || got[0] != 1 || got[1] != 4 || p.Total != 5 || p.NextOffset == nil || *p.NextOffset != 2 {
+		t.Fatalf("first latency page: %v total %d next %v", got, p.Total, p.NextOffset)
+	}
+	spec.Offset = 4
+	if p := Select(rows(), spec); len(p.Requests) != 1 || p.Requests[0].ID != 3 || p.NextOffset != nil {
+		t.Fatalf("last page: %+v", p)
+	}
+
+	spec, _ = Parse(url.Values{"class": {"chat"}, "dropped": {"false"}, "min_latency_ms": {"15"}})
+	if got := ids(Select(rows(), spec)); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("filtered: %v", got)
+	}
+	spec, _ = Parse(url.Values{"from_ms": {"5"}, "to_ms": {"20"}, "sort": {"queue"}, "order": {"asc"}})
+	if got := ids(Select(rows(), spec)); len(got) != 3 || got[0] != 2 || got[2] != 3 {
+		t.Fatalf("window sorted by queue: %v", got)
+	}
+	spec.Offset = 10
+	if p := Select(rows(), spec); p.Requests == nil || len(p.Requests) != 0 {
+		t.Fatalf("offset past the end should return an empty page")
+	}
+
+	for _, bad := range []url.Values{{"sort": {"id"}}, {"limit": {"0"}}, {"limit": {"5000"}}, {"offset": {"-1"}}, {"dropped": {"maybe"}}, {"min_latency_ms": {"x"}}} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestCriticalPathSkipsOverlapAndKeepsGaps(t *testing.T) {
+	r := schema.RequestBreakdown{
+		ArrivalMS: 0,
+		Stages: []schema.StageTiming{
+			{Name: "queue", Cat: "queue", Start: 0, End: 2},
+			{Name: "h2d", Cat: "transfer", Start: 2, End: 4},
+			{Name: "prefill/shard0", Cat: "compute", Start: 4, End: 10},
+			{Name: "prefill/shard1", Cat: "compute", Start: 4, End: 9},
+			{Name: "allreduce", Cat: "comm", Start: 10, End: 11},
+			{Name: "d2h", Cat: "transfer", Start: 12, End: 13},
+		},
+	}
+	cp := Critical(r)
+	var names []string
+	for _, s := range cp.Segments {
+		names = append(names, s.Name)
+	}
+	want := []string{"queue", "h2d", "prefill/shard0", "allreduce", "wait", "d2h"}
+	if len(names) != len(want) {
+		t.Fatalf("path %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("path %v, want %v", names, want)
+		}
+	}
+	if cp.TotalMS != 13 || cp.Bottleneck != "prefill/shard0" {
+		t.Fatalf("total %v bottleneck %q", cp.TotalMS, cp.Bottleneck)
+	}
+	var share float64
+	for _, c := range cp.Categories {
+		share += c.SharePct
+	}
+	if math.Abs(share-100) > 1e-9 || cp.Categories[0].Cat != "compute" {
+		t.Fatalf("categories %+v", cp.Categories)
+	}
+	if cp := Critical(schema.RequestBreakdown{}); cp.Segments == nil || cp.TotalMS != 0 {
+		t.Fatalf("empty request: %+v", cp)
+	}
+}
@@ -7,13 +7,71 @@ const (
 	StageFixedMs StageKind = "fixed_ms"
 	StageBytes   StageKind = "bytes"
 	StageTokens  StageKind = "tokens"
+	StageNetwork StageKind = "network"
+	StageStorage StageKind = "storage"
+	StageCache   StageKind = "cache"
+	StageBranch  StageKind = "branch"
 )
 
 // Stage describes a step in the pipeline.
 type Stage struct {
 	Name  string    `json:"name"`
 	Kind  StageKind `json:"kind"`
-	Value float64   `json:"value"` // ms for fixed_ms, bytes for bytes, tokens for tokens
+	Value float64   `json:"value"`           // ms for fixed_ms, bytes for bytes/network/storage, tokens for tokens
+	Link  string    `json:"link,omitempty"`  // shared link for network and storage stages
+	Model string    `json:"model,omitempty"` // model a GPU stage needs resident, see Scenario.Models
+
+	Cache    *CacheConfig `json:"cache,omitempty"`    // required for cache stages; value is the lookup ms
+	Branches []Branch     `json:"branches,omitempty"` // required for branch stages
+}
+
+// Branch is one sub-pipeline a branch stage can route a request into.
+// Branches with When are matched against the request's class attributes
+// first; otherwise a branch is drawn by Probability. Probabilities may sum to
+// less than 1, in which case the remainder takes no branch.
+type Branch struct {
+	Name        string            `json:"name"`
+	Probability float64           `json:"probability,omitempty"`
+	When        map[string]string `json:"when,omitempty"` // e.g. {"class": "vision"}
+	Pipeline    []Stage           `json:"pipeline"`
+}
+
+// CacheModel selects how a cache stage decides hits.
+type CacheModel string
+
+const (
+	CacheFixed  CacheModel = "fixed"  // hit with probability HitRatio
+	CacheZipf   CacheModel = "zipf"   // Zipf-distributed keys over KeySpace
+	CacheReplay CacheModel = "replay" // keys replayed from Keys, one per request
+)
+
+// CacheAction is what a hit does to the downstream stages.
+type CacheAction string
+
+const (
+	CacheSkip   CacheAction = "skip"   // downstream stages do not run
+	CacheShrink CacheAction = "shrink" // downstream stages scale by ShrinkFactor
+)
+
+// CacheConfig describes a response or prefix cache in front of downstream stages.
+type CacheConfig struct {
+	Model        CacheModel  `json:"model"`
+	HitRatio     float64     `json:"hit_ratio,omitempty"` // fixed
+	KeySpace     int         `json:"key_space,omitempty"` // zipf
+	ZipfS        float64     `json:"zipf_s,omitempty"`    // zipf exponent, >1
+	Keys         []string    `json:"keys,omitempty"`      // replay, cycled by request id
+	Capacity     int         `json:"capacity,omitempty"`  // LRU entries for zipf/replay, 0 is unbounded
+	OnHit        CacheAction `json:"on_hit"`
+	ShrinkFactor float64     `json:"shrink_factor,omitempty"` // remaining fraction of work on a shrink hit, in (0, 1]
+	Affects      []string    `json:"affects,omitempty"`       // downstream stage names, empty means all
+}
+
+// Link is a shared network or storage path that stages contend on.
+type Link struct {
+	Name          string    `json:"name"`
+	Kind          StageKind `json:"kind"` // network or storage
+	BandwidthGBps float64   `json:"bandwidth_gbps"`
+	LatencyMS     float64   `json:"latency_ms"`
 }
 
 // Workload describes incoming request pattern and payload sizes.
@@ -23,6 +81,15 @@ type Workload struct {
 	Duration  float64 `json:"duration_s"`
 	Batch     int     `json:"batch_size"`
 	JitterPct float64 `json:"jitter_pct,omitempty"` // 0-100, default 5
+
+	Classes []RequestClass `json:"classes,omitempty"` // weighted request mix, optional
+}
+
+// RequestClass tags a share of requests with attributes branches can match on.
+type RequestClass struct {
+	Name       string            `json:"name"`
+	Weight     float64           `json:"weight"`
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // GPUProfile captures target hardware capabilities.
@@ -34,14 +101,169 @@ type GPUProfile struct {
 	H2DBandwGB  float64 `json:"h2d_gbps"`     // host-to-device
 	D2HBandwGB  float64 `json:"d2h_gbps"`     // device-to-host
 	Concurrency int     `json:"concurrency"`  // max concurrent compute slots
+
+	Parallel *ParallelConfig `json:"parallel,omitempty"` // multi-GPU sharding; nil means a single device
+
+	IdleWatts    float64 `json:"idle_watts,omitempty"`     // per-GPU power with no slot busy
+	ActiveWatts  float64 `json:"active_watts,omitempty"`   // per-GPU power with every slot busy
+	PricePerHour float64 `json:"price_per_hour,omitempty"` // USD per GPU-hour
+
+	Throttle *ThrottleConfig `json:"throttle,omitempty"` // thermal/clock throttling; requires active_watts
+}
+
+// ThrottleConfig slows compute when recent power exceeds the sustained limit.
+type ThrottleConfig struct {
+	SustainedPowerW     float64 `json:"sustained_power_w"`     // power the cooler can hold indefinitely
+	TimeConstantS       float64 `json:"time_constant_s"`       // window recent utilization is averaged over
+	ThrottledClockRatio float64 `json:"throttled_clock_ratio"` // clock at full active power, 0-1
+}
+
+// Devices returns the number of GPUs the target spans.
+func (g GPUProfile) Devices() int {
+	if g.Parallel == nil {
+		return 1
+	}
+	return g.Parallel.TensorParallel * g.Parallel.PipelineParallel
+}
+
+// InterconnectKind enumerates GPU-to-GPU link types.
+type InterconnectKind string
+
+const (
+	InterconnectNVLink InterconnectKind = "nvlink"
+	InterconnectPCIe   InterconnectKind = "pcie"
+)
+
+// Interconnect describes the link used for collectives and activation transfers.
+type Interconnect struct {
+	Kind          InterconnectKind `json:"kind"`
+	BandwidthGBps float64          `json:"bandwidth_gbps"` // per-direction bandwidth between two GPUs
+	LatencyUS     float64          `json:"latency_us"`     // per-message latency
+}
+
+// ParallelConfig shards compute stages across TensorParallel*PipelineParallel GPUs.
+type ParallelConfig struct {
+	TensorParallel   int          `json:"tensor_parallel"`
+	PipelineParallel int          `json:"pipeline_parallel"`
+	Layers           int          `json:"layers"`           // model layers, split evenly across pipeline stages
+	ActivationBytes  float64      `json:"activation_bytes"` // per-layer activation size per request
+	Interconnect     Interconnect `json:"interconnect"`
 }
 
+// ScenarioType selects the simulation model.
+type ScenarioType string
+
+const (
+	ScenarioServing  ScenarioType = "serving"
+	ScenarioTraining ScenarioType = "training"
+)
+
 // Scenario defines everything needed to simulate a run.
 type Scenario struct {
-	Name     string     `json:"name"`
-	Workload Workload   `json:"workload"`
-	Pipeline []Stage    `json:"pipeline"`
-	Target   GPUProfile `json:"target"`
+	Name     string       `json:"name"`
+	Type     ScenarioType `json:"type,omitempty"` // serving (default) or training
+	Workload Workload     `json:"workload"`
+	Pipeline []Stage      `json:"pipeline"`
+	Target   GPUProfile   `json:"target"`
+	Links    []Link       `json:"links,omitempty"`
+
+	Training *TrainingConfig `json:"training,omitempty"` // required when type is training
+	Streams  *StreamConfig   `json:"streams,omitempty"`  // nil keeps the serialized per-request model
+	Models   *ModelPool      `json:"models,omitempty"`   // models sharing the target's memory
+	Faults   *FaultSchedule  `json:"faults,omitempty"`   // injected device faults
+}
+
+// FaultKind enumerates injected device faults.
+type FaultKind string
+
+const (
+	FaultFailure  FaultKind = "failure"  // device down; in-flight GPU work is aborted
+	FaultSlowdown FaultKind = "slowdown" // GPU work starting in the window runs Factor times slower
+	FaultRestart  FaultKind = "restart"  // like failure, followed by a cold start
+)
+
+// Fault is a fault at a fixed virtual time.
+type Fault struct {
+	Kind       FaultKind `json:"kind"`
+	StartS     float64   `json:"start_s"`
+	DurationS  float64   `json:"duration_s"`
+	Factor     float64   `json:"factor,omitempty"`       // slowdown multiplier, e.g. 2
+	ColdStartS float64   `json:"cold_start_s,omitempty"` // restart warm-up after the outage
+}
+
+// RandomFault injects faults with exponentially distributed gaps.
+type RandomFault struct {
+	Kind       FaultKind `json:"kind"`
+	MTBFS      float64   `json:"mtbf_s"` // mean time between fault starts
+	DurationS  float64   `json:"duration_s"`
+	Factor     float64   `json:"factor,omitempty"`
+	ColdStartS float64   `json:"cold_start_s,omitempty"`
+}
+
+// FaultSchedule lists fixed and random faults applied to the target.
+type FaultSchedule struct {
+	Events []Fault       `json:"events,omitempty"`
+	Random []RandomFault `json:"random,omitempty"`
+}
+
+// EvictionPolicy selects how non-resident models make room on the GPU.
+type EvictionPolicy string
+
+const (
+	EvictLRU    EvictionPolicy = "lru"    // evict least recently used unpinned models
+	EvictPinned EvictionPolicy = "pinned" // every model stays resident; they must all fit
+)
+
+// ModelSpec is one model co-located on the target.
+type ModelSpec struct {
+	Name     string  `json:"name"`
+	MemoryGB float64 `json:"memory_gb"`
+	LoadMS   float64 `json:"load_ms,omitempty"`   // default memory_gb over target.h2d_gbps
+	UnloadMS float64 `json:"unload_ms,omitempty"` // cost of evicting the model
+	Pinned   bool    `json:"pinned,omitempty"`    // resident from the start and never evicted
+}
+
+// ModelPool declares models that share one target and swap weights on demand.
+type ModelPool struct {
+	MemoryGB float64        `json:"memory_gb"`          // device memory available for weights
+	Eviction EvictionPolicy `json:"eviction,omitempty"` // default lru
+	Models   []ModelSpec    `json:"models"`
+}
+
+// StreamConfig enables per-slot stream modeling so copies can overlap compute.
+type StreamConfig struct {
+	PerSlot        int `json:"per_slot"`                  // streams sharing each concurrency slot
+	TransferChunks int `json:"transfer_chunks,omitempty"` // split device stages into chunks, default 1
+}
+
+// IsTraining reports whether the scenario models training steps rather than serving.
+func (s Scenario) IsTraining() bool {
+	return s.Type == ScenarioTraining
+}
+
+// TrainingConfig describes one data-parallel rank of a training job.
+type TrainingConfig struct {
+	Steps          int     `json:"steps"`                    // optimizer steps to simulate
+	MicroBatchSize int     `json:"micro_batch_size"`         // samples per micro-batch
+	GradAccumSteps int     `json:"grad_accum_steps"`         // micro-batches per optimizer step, default 1
+	DataParallel   int     `json:"data_parallel"`            // ranks taking part in the gradient all-reduce
+	Params         float64 `json:"params"`                   // model parameter count
+	FLOPsPerSample float64 `json:"flops_per_sample"`         // forward FLOPs per sample
+	BackwardRatio  float64 `json:"backward_ratio,omitempty"` // backward/forward cost, default 2
+	SampleBytes    float64 `json:"sample_bytes"`             // host bytes copied to the device per sample
+	JitterPct      float64 `json:"jitter_pct,omitempty"`     // 0-100, default 5
+
+	LoaderWorkers   int     `json:"loader_workers"`     // CPU data-loading workers
+	LoadMSPerSample float64 `json:"load_ms_per_sample"` // CPU time per sample per worker
+
+	GradBytesPerParam      float64      `json:"grad_bytes_per_param,omitempty"`      // default 2 (fp16 grads)
+	OptimizerBytesPerParam float64      `json:"optimizer_bytes_per_param,omitempty"` // memory traffic per param, default 16
+	OverlapGradSync        bool         `json:"overlap_grad_sync,omitempty"`         // bucket the all-reduce under backward
+	Interconnect           Interconnect `json:"interconnect"`
+
+	CheckpointEvery int     `json:"checkpoint_every,omitempty"` // steps between checkpoints, 0 disables
+	CheckpointBytes float64 `json:"checkpoint_bytes,omitempty"`
+	StorageGBps     float64 `json:"storage_gbps,omitempty"` // checkpoint write bandwidth
 }
 
 // RunResult summarizes a simulation execution.
@@ -51,7 +273,9 @@ type RunResult struct {
 	Summary     Summary           `json:"summary"`
 	TracePath   string            `json:"trace_path,omitempty"`
 	TraceInline []byte            `json:"trace_inline,omitempty"`
+	Training    *TrainingSummary  `json:"training,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	Aggregate   *AggregateReport  `json:"aggregate,omitempty"`
 }
 
 type StageAggregate struct {
@@ -60,6 +284,27 @@ type StageAggregate struct {
 	AvgMS    float64 `json:"avg_ms"`
 	TotalMS  float64 `json:"total_ms"`
 	Count    int     `json:"count"`
+	P50MS    float64 `json:"p50_ms"`
+	P90MS    float64 `json:"p90_ms"`
+	P99MS    float64 `json:"p99_ms"`
+	MaxMS    float64 `json:"max_ms"`
+
+	Histogram *Histogram `json:"histogram,omitempty"` // span durations
+}
+
+// Histogram counts values in log-linear buckets: each power of two in ms is
+// split into equal-width sub-buckets, so relative resolution is the same at
+// every scale and bucket bounds are identical across runs and stages. Only
+// non-empty buckets are listed, in ascending order.
+type Histogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket holds the values in [LowMS, HighMS).
+type HistogramBucket struct {
+	LowMS  float64 `json:"low_ms"`
+	HighMS float64 `json:"high_ms"`
+	Count  int     `json:"count"`
 }
 
 type RequestBreakdown struct {
@@ -70,6 +315,11 @@ type RequestBreakdown struct {
 	QueueMS   float64       `json:"queue_ms"`
 	TotalMS   float64       `json:"total_ms"`
 	Stages    []StageTiming `json:"stages"`
+	Cache     string        `json:"cache,omitempty"` // hit or miss for pipelines with a cache stage
+	Class     string        `json:"class,omitempty"`
+	Branches  []string      `json:"branches,omitempty"` // "stage/branch" taken, in order
+	Fault     FaultKind     `json:"fault,omitempty"`    // first fault that affected the request
+	Dropped   bool          `json:"dropped,omitempty"`  // aborted before completing
 }
 
 type StageTiming struct {
@@ -80,8 +330,61 @@ type StageTiming struct {
 }
 
 type Breakdown struct {
-	StageAggregates []StageAggregate   `json:"stage_aggregates"`
-	Requests        []RequestBreakdown `json:"requests"`
+	StageAggregates    []StageAggregate   `json:"stage_aggregates"`
+	CategoryAggregates []StageAggregate   `json:"category_aggregates"` // stages pooled by category; Name is empty
+	LatencyHistogram   *Histogram         `json:"latency_histogram,omitempty"`
+	Requests           []RequestBreakdown `json:"requests"`
+	Cache              *CacheSummary      `json:"cache,omitempty"`
+	Branches           []BranchAggregate  `json:"branches,omitempty"`
+}
+
+// BranchAggregate reports how often a branch was taken and the end-to-end
+// latency of the requests that took it.
+type BranchAggregate struct {
+	Stage  string  `json:"stage"`
+	Branch string  `json:"branch"`
+	Count  int     `json:"count"`
+	Share  float64 `json:"share"` // of all requests in the run
+	AvgMS  float64 `json:"avg_ms"`
+	P50MS  float64 `json:"p50_ms"`
+	P90MS  float64 `json:"p90_ms"`
+	P99MS  float64 `json:"p99_ms"`
+}
+
+// LatencyStats describes a latency distribution estimated by a quantile
+// sketch; Count, MeanMS and MaxMS are exact.
+type LatencyStats struct {
+	Name     string  `json:"name,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Count    int     `json:"count"`
+	MeanMS   float64 `json:"mean_ms"`
+	P50MS    float64 `json:"p50_ms"`
+	P90MS    float64 `json:"p90_ms"`
+	P99MS    float64 `json:"p99_ms"`
+	MaxMS    float64 `json:"max_ms"`
+}
+
+// AggregateReport is the streaming view of a run: every request feeds the
+// sketches, while only RetainedRequests of them are kept in full.
+type AggregateReport struct {
+	RelativeAccuracy float64        `json:"relative_accuracy"`
+	Requests         int            `json:"requests"`
+	RetainedRequests int            `json:"retained_requests"`
+	Latency          LatencyStats   `json:"latency"` // completed requests
+	Queue            LatencyStats   `json:"queue"`   // all requests
+	Stages           []LatencyStats `json:"stages"`
+	Classes          []LatencyStats `json:"classes,omitempty"`
+}
+
+// CacheSummary splits latency between cache hits and misses.
+type CacheSummary struct {
+	Hits      int     `json:"hits"`
+	Misses    int     `json:"misses"`
+	HitRatio  float64 `json:"hit_ratio"`
+	HitAvgMS  float64 `json:"hit_avg_ms"`
+	HitP99MS  float64 `json:"hit_p99_ms"`
+	MissAvgMS float64 `json:"miss_avg_ms"`
+	MissP99MS float64 `json:"miss_p99_ms"`
 }
 
 // Summary provides top-line metrics.
@@ -94,4 +397,42 @@ type Summary struct {
 	GPUUtilization float64 `json:"gpu_util_percent"`
 	TotalRequests  int     `json:"total_requests"`
 	DurationS      float64 `json:"duration_s"`
+
+	DroppedRequests int `json:"dropped_requests,omitempty"` // excluded from latency and throughput
+	FaultedRequests int `json:"faulted_requests,omitempty"` // delayed, slowed or dropped by a fault
+
+	Cache      *CacheSummary `json:"cache,omitempty"`
+	ModelSwaps int           `json:"model_swaps,omitempty"`
+	SwapMS     float64       `json:"swap_ms,omitempty"` // total time spent loading and unloading models
+
+	ThrottleMS      float64 `json:"throttle_ms,omitempty"`           // compute time added by clock throttling
+	ThrottleLossPct float64 `json:"throttle_loss_percent,omitempty"` // share of compute time lost to throttling
+
+	// Energy and cost; zero unless the target declares power or price.
+	AvgPowerW         float64 `json:"avg_power_w,omitempty"`
+	EnergyJ           float64 `json:"energy_j,omitempty"`
+	EnergyPerRequestJ float64 `json:"energy_per_request_j,omitempty"`
+	CostUSD           float64 `json:"cost_usd,omitempty"`
+	CostPer1kUSD      float64 `json:"cost_per_1k_requests_usd,omitempty"`
+	TokensPerDollar   float64 `json:"tokens_per_dollar,omitempty"`
+}
+
+// TrainingSummary reports per-step metrics for training scenarios.
+type TrainingSummary struct {
+	Steps          int     `json:"steps"`
+	StepTimeMS     float64 `json:"step_time_ms"` // mean over simulated steps
+	MinStepMS      float64 `json:"min_step_ms"`
+	MaxStepMS      float64 `json:"max_step_ms"`
+	ComputeMS      float64 `json:"compute_ms_per_step"`
+	CommMS         float64 `json:"comm_ms_per_step"`
+	ExposedCommMS  float64 `json:"exposed_comm_ms_per_step"`
+	CommOverlapPct float64 `json:"comm_overlap_percent"`
+	DataStallMS    float64 `json:"data_stall_ms_per_step"`
+	CheckpointMS   float64 `json:"checkpoint_ms_total"`
+	MFU            float64 `json:"mfu_percent"`
+	SamplesPerSec  float64 `json:"samples_per_s"` // global, across data-parallel ranks
+	DurationS      float64 `json:"duration_s"`
+	EnergyJ        float64 `json:"energy_j,omitempty"` // all ranks
+	CostUSD        float64 `json:"cost_usd,omitempty"` // all ranks
+	CostPerStepUSD float64 `json:"cost_per_step_usd,omitempty"`
 }
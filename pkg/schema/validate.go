@@ -9,31 +9,284 @@ func ValidateScenario(s Scenario) error {
 	if s.Name == "" {
 		return fmt.Errorf("name is required")
 	}
+	switch s.Type {
+	case "", ScenarioServing:
+	case ScenarioTraining:
+		return validateTrainingScenario(s)
+	default:
+		return fmt.Errorf("type must be serving or training")
+	}
 	if err := validateWorkload(s.Workload); err != nil {
 		return err
 	}
 	if len(s.Pipeline) == 0 {
 		return fmt.Errorf("pipeline must have at least one stage")
 	}
-	for i, st := range s.Pipeline {
+	links, err := validateLinks(s.Links)
+	if err != nil {
+		return err
+	}
+	models, err := validateModels(s.Models)
+	if err != nil {
+		return err
+	}
+	refs := stageRefs{links: links, models: models}
+	if err := validateStages("pipeline", s.Pipeline, refs); err != nil {
+		return err
+	}
+	if err := validateGPU(s.Target); err != nil {
+		return err
+	}
+	if s.Streams != nil {
+		if s.Streams.PerSlot < 1 {
+			return fmt.Errorf("streams.per_slot must be >=1")
+		}
+		if s.Streams.TransferChunks < 0 {
+			return fmt.Errorf("streams.transfer_chunks must be >=0")
+		}
+		if s.Target.Parallel != nil {
+			return fmt.Errorf("streams cannot be combined with target.parallel")
+		}
+	}
+	if s.Faults != nil {
+		if err := validateFaults(*s.Faults); err != nil {
+			return err
+		}
+	}
+	if s.Models != nil && (s.Streams != nil || s.Target.Parallel != nil) {
+		return fmt.Errorf("models cannot be combined with streams or target.parallel")
+	}
+	return nil
+}
+
+// stageRefs holds the named resources stages may reference.
+type stageRefs struct {
+	links  map[string]Link
+	models map[string]ModelSpec
+}
+
+func validateStages(path string, stages []Stage, refs stageRefs) error {
+	for i, st := range stages {
+		field := fmt.Sprintf("%s[%d]", path, i)
 		if st.Name == "" {
-			return fmt.Errorf("pipeline[%d].name is required", i)
+			return fmt.Errorf("%s.name is required", field)
+		}
+		if st.Model != "" {
+			if _, ok := refs.models[st.Model]; !ok {
+				return fmt.Errorf("%s.model %q is not declared in models", field, st.Model)
+			}
 		}
 		switch st.Kind {
 		case StageFixedMs, StageBytes, StageTokens:
+		case StageNetwork, StageStorage:
+			l, ok := refs.links[st.Link]
+			if !ok {
+				return fmt.Errorf("%s.link %q is not declared in links", field, st.Link)
+			}
+			if l.Kind != st.Kind {
+				return fmt.Errorf("%s.link %q is a %s link", field, st.Link, l.Kind)
+			}
+		case StageCache:
+			if err := validateCache(field, st.Cache); err != nil {
+				return err
+			}
+		case StageBranch:
+			if err := validateBranches(field, st.Branches, refs); err != nil {
+				return err
+			}
+			continue // branch stages carry no value of their own
 		default:
-			return fmt.Errorf("pipeline[%d].kind invalid", i)
+			return fmt.Errorf("%s.kind invalid", field)
 		}
 		if st.Value <= 0 {
-			return fmt.Errorf("pipeline[%d].value must be >0", i)
+			return fmt.Errorf("%s.value must be >0", field)
 		}
 	}
-	if err := validateGPU(s.Target); err != nil {
-		return err
+	return nil
+}
+
+func validateBranches(field string, branches []Branch, refs stageRefs) error {
+	if len(branches) == 0 {
+		return fmt.Errorf("%s.branches must have at least one branch", field)
+	}
+	var total float64
+	names := map[string]bool{}
+	for j, b := range branches {
+		bf := fmt.Sprintf("%s.branches[%d]", field, j)
+		if b.Name == "" {
+			return fmt.Errorf("%s.name is required", bf)
+		}
+		if names[b.Name] {
+			return fmt.Errorf("%s.name %q is duplicated", bf, b.Name)
+		}
+		names[b.Name] = true
+		if b.Probability < 0 || b.Probability > 1 {
+			return fmt.Errorf("%s.probability must be between 0 and 1", bf)
+		}
+		if len(b.When) == 0 {
+			total += b.Probability
+		}
+		if err := validateStages(bf+".pipeline", b.Pipeline, refs); err != nil {
+			return err
+		}
+	}
+	if total > 1+1e-9 {
+		return fmt.Errorf("%s.branches probabilities must sum to <=1", field)
+	}
+	return nil
+}
+
+func validateCache(field string, c *CacheConfig) error {
+	if c == nil {
+		return fmt.Errorf("%s.cache is required for cache stages", field)
+	}
+	switch c.Model {
+	case CacheFixed:
+		if c.HitRatio < 0 || c.HitRatio > 1 {
+			return fmt.Errorf("%s.cache.hit_ratio must be between 0 and 1", field)
+		}
+	case CacheZipf:
+		if c.KeySpace < 1 {
+			return fmt.Errorf("%s.cache.key_space must be >=1", field)
+		}
+		if c.ZipfS <= 1 {
+			return fmt.Errorf("%s.cache.zipf_s must be >1", field)
+		}
+	case CacheReplay:
+		if len(c.Keys) == 0 {
+			return fmt.Errorf("%s.cache.keys is required for replay", field)
+		}
+	default:
+		return fmt.Errorf("%s.cache.model must be fixed, zipf or replay", field)
+	}
+	if c.Capacity < 0 {
+		return fmt.Errorf("%s.cache.capacity must be >=0", field)
+	}
+	switch c.OnHit {
+	case CacheSkip:
+	case CacheShrink:
+		if c.ShrinkFactor <= 0 || c.ShrinkFactor > 1 {
+			return fmt.Errorf("%s.cache.shrink_factor must be >0 and <=1 for shrink (use on_hit skip to drop stages)", field)
+		}
+	default:
+		return fmt.Errorf("%s.cache.on_hit must be skip or shrink", field)
+	}
+	return nil
+}
+
+func validateModels(p *ModelPool) (map[string]ModelSpec, error) {
+	out := map[string]ModelSpec{}
+	if p == nil {
+		return out, nil
+	}
+	if p.MemoryGB <= 0 {
+		return nil, fmt.Errorf("models.memory_gb must be >0")
+	}
+	switch p.Eviction {
+	case "", EvictLRU, EvictPinned:
+	default:
+		return nil, fmt.Errorf("models.eviction must be lru or pinned")
+	}
+	if len(p.Models) == 0 {
+		return nil, fmt.Errorf("models.models must have at least one model")
+	}
+	var total, pinned float64
+	for i, m := range p.Models {
+		if m.Name == "" {
+			return nil, fmt.Errorf("models.models[%d].name is required", i)
+		}
+		if _, dup := out[m.Name]; dup {
+			return nil, fmt.Errorf("models.models[%d].name %q is duplicated", i, m.Name)
+		}
+		if m.MemoryGB <= 0 || m.MemoryGB > p.MemoryGB {
+			return nil, fmt.Errorf("models.models[%d].memory_gb must be >0 and fit in models.memory_gb", i)
+		}
+		if m.LoadMS < 0 || m.UnloadMS < 0 {
+			return nil, fmt.Errorf("models.models[%d].load_ms and unload_ms must be >=0", i)
+		}
+		total += m.MemoryGB
+		if m.Pinned {
+			pinned += m.MemoryGB
+		}
+		out[m.Name] = m
+	}
+	if p.Eviction == EvictPinned && total > p.MemoryGB {
+		return nil, fmt.Errorf("models must all fit in models.memory_gb with pinned eviction")
+	}
+	if pinned > p.MemoryGB {
+		return nil, fmt.Errorf("pinned models exceed models.memory_gb")
+	}
+	for i, m := range p.Models {
+		if !m.Pinned && m.MemoryGB+pinned > p.MemoryGB {
+			return nil, fmt.Errorf("models.models[%d] does not fit next to the pinned models", i)
+		}
+	}
+	return out, nil
+}
+
+func validateFaults(f FaultSchedule) error {
+	check := func(field string, kind FaultKind, dur, factor, cold float64) error {
+		switch kind {
+		case FaultFailure, FaultRestart:
+		case FaultSlowdown:
+			if factor <= 0 {
+				return fmt.Errorf("%s.factor must be >0 for slowdown", field)
+			}
+		default:
+			return fmt.Errorf("%s.kind must be failure, slowdown or restart", field)
+		}
+		if dur <= 0 {
+			return fmt.Errorf("%s.duration_s must be >0", field)
+		}
+		if cold < 0 {
+			return fmt.Errorf("%s.cold_start_s must be >=0", field)
+		}
+		return nil
+	}
+	for i, e := range f.Events {
+		field := fmt.Sprintf("faults.events[%d]", i)
+		if e.StartS < 0 {
+			return fmt.Errorf("%s.start_s must be >=0", field)
+		}
+		if err := check(field, e.Kind, e.DurationS, e.Factor, e.ColdStartS); err != nil {
+			return err
+		}
+	}
+	for i, r := range f.Random {
+		field := fmt.Sprintf("faults.random[%d]", i)
+		if r.MTBFS <= 0 {
+			return fmt.Errorf("%s.mtbf_s must be >0", field)
+		}
+		if err := check(field, r.Kind, r.DurationS, r.Factor, r.ColdStartS); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+func validateLinks(links []Link) (map[string]Link, error) {
+	out := make(map[string]Link, len(links))
+	for i, l := range links {
+		if l.Name == "" {
+			return nil, fmt.Errorf("links[%d].name is required", i)
+		}
+		if _, dup := out[l.Name]; dup {
+			return nil, fmt.Errorf("links[%d].name %q is duplicated", i, l.Name)
+		}
+		if l.Kind != StageNetwork && l.Kind != StageStorage {
+			return nil, fmt.Errorf("links[%d].kind must be network or storage", i)
+		}
+		if l.BandwidthGBps <= 0 {
+			return nil, fmt.Errorf("links[%d].bandwidth_gbps must be >0", i)
+		}
+		if l.LatencyMS < 0 {
+			return nil, fmt.Errorf("links[%d].latency_ms must be >=0", i)
+		}
+		out[l.Name] = l
+	}
+	return out, nil
+}
+
 func validateWorkload(w Workload) error {
 	if w.Name == "" {
 		return fmt.Errorf("workload.name is required")
@@ -50,6 +303,19 @@ func validateWorkload(w Workload) error {
 	if w.JitterPct < 0 || w.JitterPct > 100 {
 		return fmt.Errorf("workload.jitter_pct must be between 0 and 100")
 	}
+	names := map[string]bool{}
+	for i, c := range w.Classes {
+		if c.Name == "" {
+			return fmt.Errorf("workload.classes[%d].name is required", i)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("workload.classes[%d].name %q is duplicated", i, c.Name)
+		}
+		names[c.Name] = true
+		if c.Weight <= 0 {
+			return fmt.Errorf("workload.classes[%d].weight must be >0", i)
+		}
+	}
 	return nil
 }
 
@@ -75,5 +341,115 @@ func validateGPU(g GPUProfile) error {
 	if g.Concurrency < 1 {
 		return fmt.Errorf("target.concurrency must be >=1")
 	}
+	if g.IdleWatts < 0 || g.ActiveWatts < 0 || g.PricePerHour < 0 {
+		return fmt.Errorf("target.idle_watts, active_watts and price_per_hour must be >=0")
+	}
+	if g.ActiveWatts > 0 && g.ActiveWatts < g.IdleWatts {
+		return fmt.Errorf("target.active_watts must be >= idle_watts")
+	}
+	if t := g.Throttle; t != nil {
+		if g.ActiveWatts <= 0 {
+			return fmt.Errorf("target.throttle requires target.active_watts")
+		}
+		if t.SustainedPowerW <= 0 || t.SustainedPowerW >= g.ActiveWatts {
+			return fmt.Errorf("target.throttle.sustained_power_w must be >0 and below active_watts")
+		}
+		if t.TimeConstantS <= 0 {
+			return fmt.Errorf("target.throttle.time_constant_s must be >0")
+		}
+		if t.ThrottledClockRatio <= 0 || t.ThrottledClockRatio > 1 {
+			return fmt.Errorf("target.throttle.throttled_clock_ratio must be in (0,1]")
+		}
+	}
+	if g.Parallel != nil {
+		if err := validateParallel(*g.Parallel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateParallel(p ParallelConfig) error {
+	if p.TensorParallel < 1 {
+		return fmt.Errorf("target.parallel.tensor_parallel must be >=1")
+	}
+	if p.PipelineParallel < 1 {
+		return fmt.Errorf("target.parallel.pipeline_parallel must be >=1")
+	}
+	if p.Layers < p.PipelineParallel {
+		return fmt.Errorf("target.parallel.layers must be >= pipeline_parallel")
+	}
+	if p.ActivationBytes < 0 {
+		return fmt.Errorf("target.parallel.activation_bytes must be >=0")
+	}
+	return validateInterconnect("target.parallel.interconnect", p.Interconnect)
+}
+
+func validateInterconnect(field string, ic Interconnect) error {
+	switch ic.Kind {
+	case InterconnectNVLink, InterconnectPCIe:
+	default:
+		return fmt.Errorf("%s.kind must be nvlink or pcie", field)
+	}
+	if ic.BandwidthGBps <= 0 {
+		return fmt.Errorf("%s.bandwidth_gbps must be >0", field)
+	}
+	if ic.LatencyUS < 0 {
+		return fmt.Errorf("%s.latency_us must be >=0", field)
+	}
+	return nil
+}
+
+func validateTrainingScenario(s Scenario) error {
+	if err := validateGPU(s.Target); err != nil {
+		return err
+	}
+	if s.Training == nil {
+		return fmt.Errorf("training is required for training scenarios")
+	}
+	t := *s.Training
+	if t.Steps < 1 {
+		return fmt.Errorf("training.steps must be >=1")
+	}
+	if t.MicroBatchSize < 1 {
+		return fmt.Errorf("training.micro_batch_size must be >=1")
+	}
+	if t.GradAccumSteps < 0 {
+		return fmt.Errorf("training.grad_accum_steps must be >=0")
+	}
+	if t.DataParallel < 1 {
+		return fmt.Errorf("training.data_parallel must be >=1")
+	}
+	if t.Params <= 0 {
+		return fmt.Errorf("training.params must be >0")
+	}
+	if t.FLOPsPerSample <= 0 {
+		return fmt.Errorf("training.flops_per_sample must be >0")
+	}
+	if t.BackwardRatio < 0 || t.SampleBytes < 0 || t.LoadMSPerSample < 0 {
+		return fmt.Errorf("training.backward_ratio, sample_bytes and load_ms_per_sample must be >=0")
+	}
+	if t.JitterPct < 0 || t.JitterPct > 100 {
+		return fmt.Errorf("training.jitter_pct must be between 0 and 100")
+	}
+	if t.LoadMSPerSample > 0 && t.LoaderWorkers < 1 {
+		return fmt.Errorf("training.loader_workers must be >=1 when load_ms_per_sample is set")
+	}
+	if t.DataParallel > 1 {
+		if err := validateInterconnect("training.interconnect", t.Interconnect); err != nil {
+			return err
+		}
+	}
+	if t.CheckpointEvery < 0 {
+		return fmt.Errorf("training.checkpoint_every must be >=0")
+	}
+	if t.CheckpointEvery > 0 {
+		if t.CheckpointBytes <= 0 {
+			return fmt.Errorf("training.checkpoint_bytes must be >0 when checkpointing")
+		}
+		if t.StorageGBps <= 0 {
+			return fmt.Errorf("training.storage_gbps must be >0 when checkpointing")
+		}
+	}
 	return nil
 }
@@ -42,3 +42,108 @@ func TestValidateScenarioFailures(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateParallel(t *testing.T) {
+	g := GPUProfile{
+		Name: "H100", TFLOPS: 400, MemGBps: 3000, H2DBandwGB: 50, D2HBandwGB: 50, Concurrency: 1,
+		Parallel: &ParallelConfig{
+			TensorParallel:   2,
+			PipelineParallel: 2,
+			Layers:           8,
+			Interconnect:     Interconnect{Kind: InterconnectNVLink, BandwidthGBps: 300},
+		},
+	}
+	if err := validateGPU(g); err != nil {
+		t.Fatalf("expected valid parallel target: %v", err)
+	}
+	g.Parallel.Interconnect.Kind = "infiniband"
+	if err := validateGPU(g); err == nil {
+		t.Fatalf("expected error for unknown interconnect kind")
+	}
+	g.Parallel.Interconnect.Kind = InterconnectPCIe
+	g.Parallel.Layers = 1
+	if err := validateGPU(g); err == nil {
+		t.Fatalf("expected error when layers < pipeline_parallel")
+	}
+}
+
+func TestValidateTrainingScenario(t *testing.T) {
+	s := Scenario{
+		Name:   "train",
+		Type:   ScenarioTraining,
+		Target: GPUProfile{Name: "A100", TFLOPS: 150, MemGBps: 2000, H2DBandwGB: 25, D2HBandwGB: 25, Concurrency: 1},
+	}
+	if err := ValidateScenario(s); err == nil {
+		t.Fatalf("expected error when training config is missing")
+	}
+	s.Training = &TrainingConfig{Steps: 1, MicroBatchSize: 4, DataParallel: 1, Params: 1e9, FLOPsPerSample: 1e12}
+	if err := ValidateScenario(s); err != nil {
+		t.Fatalf("expected valid training scenario: %v", err)
+	}
+	s.Training.DataParallel = 4
+	if err := ValidateScenario(s); err == nil {
+		t.Fatalf("expected interconnect to be required for data_parallel > 1")
+	}
+}
+
+func TestValidateLinkBinding(t *testing.T) {
+	s := Scenario{
+		Name:     "remote",
+		Workload: Workload{Name: "wl", RPS: 1, Duration: 1, Batch: 1},
+		Links: []Link{
+			{Name: "s3", Kind: StageStorage, BandwidthGBps: 1},
+			{Name: "nic", Kind: StageNetwork, BandwidthGBps: 10},
+		},
+		Pipeline: []Stage{{Name: "fetch", Kind: StageStorage, Value: 1e6, Link: "s3"}},
+		Target:   GPUProfile{Name: "GPU", TFLOPS: 1, MemGBps: 1, H2DBandwGB: 1, D2HBandwGB: 1, Concurrency: 1},
+	}
+	if err := ValidateScenario(s); err != nil {
+		t.Fatalf("expected valid scenario: %v", err)
+	}
+	s.Pipeline[0].Link = "nic"
+	if err := ValidateScenario(s); err == nil {
+		t.Fatalf("expected error binding a storage stage to a network link")
+	}
+	s.Pipeline[0].Link = "missing"
+	if err := ValidateScenario(s); err == nil {
+		t.Fatalf("expected error for undeclared link")
+	}
+}
+
+func TestValidateBranchProbabilities(t *testing.T) {
+	s := Scenario{
+		Name:     "branches",
+		Workload: Workload{Name: "wl", RPS: 1, Duration: 1, Batch: 1},
+		Pipeline: []Stage{{Name: "route", Kind: StageBranch, Branches: []Branch{
+			{Name: "a", Probability: 0.7, Pipeline: []Stage{{Name: "x", Kind: StageFixedMs, Value: 1}}},
+			{Name: "b", Probability: 0.6},
+		}}},
+		Target: GPUProfile{Name: "GPU", TFLOPS: 1, MemGBps: 1, H2DBandwGB: 1, D2HBandwGB: 1, Concurrency: 1},
+	}
+	if err := ValidateScenario(s); err == nil {
+		t.Fatalf("expected error when probabilities exceed 1")
+	}
+	s.Pipeline[0].Branches[1].Probability = 0.3
+	if err := ValidateScenario(s); err != nil {
+		t.Fatalf("expected valid branches: %v", err)
+	}
+	s.Pipeline[0].Branches[0].Pipeline[0].Value = 0
+	if err := ValidateScenario(s); err == nil {
+		t.Fatalf("expected nested stage errors to surface")
+	}
+}
+
+func TestValidateCacheShrinkFactor(t *testing.T) {
+	c := &CacheConfig{Model: CacheFixed, HitRatio: 0.5, OnHit: CacheShrink}
+	if err := validateCache("pipeline[0]", c); err == nil {
+		t.Fatalf("expected error when shrink_factor is omitted")
+	}
+	c.ShrinkFactor = 0.25
+	if err := validateCache("pipeline[0]", c); err != nil {
+		t.Fatalf("expected valid shrink cache: %v", err)
+	}
+	c.OnHit, c.ShrinkFactor = CacheSkip, 0
+	if err := validateCache("pipeline[0]", c); err != nil {
+		t.Fatalf("skip should not need a shrink_factor: %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+// Package sensitivity ranks numeric scenario fields by how much they move
+// summary metrics, with one-at-a-time perturbation or Morris elementary
+// effects.
+package sensitivity
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sweep"
+)
+
+// Method selects the screening design.
+type Method string
+
+const (
+	// MethodOAT moves each parameter to its low and high bound with the
+	// others at their base values: 2k+1 runs, tornado-chart ready.
+	MethodOAT Method = "oat"
+	// MethodMorris samples r random trajectories through a p-level grid
+	// and reports elementary-effect statistics: r(k+1) runs, which also
+	// catches interactions and non-linearity.
+	MethodMorris Method = "morris"
+)
+
+// MaxRuns bounds the number of simulator runs per analysis.
+const MaxRuns = 2000
+
+// Param is a numeric field addressed by JSON path. The range is [Low, High]
+// when either is set, otherwise ±RelPct (default 20) of the base value.
+// Integer fields such as target.concurrency need Integer so perturbed values
+// are rounded.
+type Param struct {
+	Path    string  `json:"path"`
+	Low     float64 `json:"low,omitempty"`
+	High    float64 `json:"high,omitempty"`
+	RelPct  float64 `json:"rel_pct,omitempty"`
+	Integer bool    `json:"integer,omitempty"`
+}
+
+// defaultParams names the leaf fields analyzed when a spec lists no
+// params, and whether each one is an integer.
+var defaultParams = map[string]bool{
+	"rps":            false,
+	"batch_size":     true,
+	"value":          false, // stage ms, bytes or tokens
+	"tflops":         false,
+	"mem_gbps":       false,
+	"ms_per_token":   false,
+	"h2d_gbps":       false,
+	"d2h_gbps":       false,
+	"bandwidth_gbps": false,
+	"latency_ms":     false,
+	"latency_us":     false,
+	"concurrency":    true,
+}
+
+// Spec describes an analysis. With no Params, every non-zero stage value,
+// bandwidth, latency, compute rate, concurrency and load field of the
+// scenario is analyzed.
+type Spec struct {
+	Scenario     schema.Scenario `json:"scenario"`
+	Params       []Param         `json:"params,omitempty"`
+	Method       Method          `json:"method,omitempty"`       // default oat
+	Metrics      []string        `json:"metrics,omitempty"`      // default p99_ms
+	Trajectories int             `json:"trajectories,omitempty"` // morris, default 10
+	Levels       int             `json:"levels,omitempty"`       // morris, default 4
+	Seed         int64           `json:"seed,omitempty"`
+	Workers      int             `json:"workers,omitempty"`
+
+	// Slots is passed to the sweeps; see sweep.Spec.
+	Slots chan struct{} `json:"-"`
+}
+
+// Row is one parameter's line in the ranked table. OAT fills the metric at
+// each bound and the swing between them; Morris fills mu, mu* and sigma of
+// the elementary effects, normalized to the full parameter range.
+type Row struct {
+	Param string  `json:"param"`
+	Rank  int     `json:"rank"`
+	Base  float64 `json:"base"`
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+
+	MetricLow  float64 `json:"metric_low,omitempty"`
+	MetricHigh float64 `json:"metric_high,omitempty"`
+	Swing      float64 `json:"swing,omitempty"`
+
+	Mu     float64 `json:"mu,omitempty"`
+	MuStar float64 `json:"mu_star,omitempty"`
+	Sigma  float64 `json:"sigma,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Table ranks the parameters for one metric, most influential first.
+type Table struct {
+	Metric     string  `json:"metric"`
+	BaseMetric float64 `json:"base_metric"`
+	Rows       []Row   `json:"rows"`
+}
+
+// Result holds one table per metric, in the order the metrics were given.
+// The tables share the same runs.
+type Result struct {
+	Method Method  `json:"method"`
+	Runs   int     `json:"runs"`
+	Failed int     `json:"failed"`
+	Tables []Table `json:"tables"`
+}
+
+// Analyze runs the design. Every run uses the same seed, so differences come
+// from the perturbation rather than from the random streams.
+func Analyze(spec Spec) (Result, error) {
+	if spec.Method == "" {
+		spec.Method = MethodOAT
+	}
+	if len(spec.Metrics) == 0 {
+		spec.Metrics = []string{"p99_ms"}
+	}
+	for _, m := range spec.Metrics {
+		if !sweep.IsMetric(m) {
+			return Result{}, fmt.Errorf("unknown metric %q", m)
+		}
+	}
+	if len(spec.Params) == 0 {
+		params, err := numericParams(spec.Scenario)
+		if err != nil {
+			return Result{}, err
+		}
+		spec.Params = params
+	}
+	if len(spec.Params) == 0 {
+		return Result{}, fmt.Errorf("at least one parameter is required")
+	}
+	rows := make([]Row, len(spec.Params))
+	for i, p := range spec.Params {
+		row, err := bounds(spec.Scenario, p)
+		if err != nil {
+			return Result{}, fmt.Errorf("params[%d]: %w", i, err)
+		}
+		rows[i] = row
+	}
+	a := &analysis{spec: spec, rows: rows}
+	switch spec.Method {
+	case MethodOAT:
+		return a.oat()
+	case MethodMorris:
+		return a.morris()
+	default:
+		return Result{}, fmt.Errorf("unknown method %q", spec.Method)
+	}
+}
+
+type analysis struct {
+	spec Spec
+	rows []Row // bounds, shared by every table
+}
+
+// numericParams lists the scenario's non-zero default parameter fields,
+// sorted by path.
+func numericParams(s schema.Scenario) ([]Param, error) {
+	leaves, err := sweep.Leaves(s)
+	if err != nil {
+		return nil, err
+	}
+	var params []Param
+	for path, v := range leaves {
+		name := path[strings.LastIndex(path, ".")+1:]
+		integer, ok := defaultParams[name]
+		if f, numeric := v.(float64); !ok || !numeric || f == 0 {
+			continue
+		}
+		params = append(params, Param{Path: path, Integer: integer})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Path < params[j].Path })
+	return params, nil
+}
+
+// bounds resolves a parameter's base value and range.
+func bounds(s schema.Scenario, p Param) (Row, error) {
+	v, err := sweep.Lookup(s, p.Path)
+	if err != nil {
+		return Row{}, err
+	}
+	base, ok := v.(float64)
+	if !ok {
+		return Row{}, fmt.Errorf("%s is not numeric", p.Path)
+	}
+	row := Row{Param: p.Path, Base: base, Low: p.Low, High: p.High}
+	if p.Low == 0 && p.High == 0 {
+		rel := p.RelPct
+		if rel == 0 {
+			rel = 20
+		}
+		row.Low = base * (1 - rel/100)
+		row.High = base * (1 + rel/100)
+	}
+	if row.High < row.Low {
+		return Row{}, fmt.Errorf("high must be >= low")
+	}
+	return row, nil
+}
+
+// value maps u in [0, 1] onto parameter i's range.
+func (a *analysis) value(i int, u float64) float64 {
+	r := a.rows[i]
+	v := r.Low + u*(r.High-r.Low)
+	if a.spec.Params[i].Integer {
+		v = math.Round(v)
+	}
+	return v
+}
+
+// run simulates the points and returns the metrics per point index; failed
+// points are nil.
+func (a *analysis) run(points []sweep.Point) ([]map[string]float64, int) {
+	out := make([]map[string]float64, len(points))
+	failed := 0
+	sw := sweep.Spec{Scenario: a.spec.Scenario, Workers: a.spec.Workers, FixedSeed: true, Slots: a.spec.Slots}
+	sweep.Run(sw, points, a.spec.Seed, func(r sweep.Result) {
+		if r.Summary == nil {
+			failed++
+			return
+		}
+		out[r.Index] = sweep.Metrics(*r.Summary)
+	})
+	return out, failed
+}
+
+// metric picks one metric out of run's output; failed points are NaN.
+func metric(runs []map[string]float64, name string) []float64 {
+	y := make([]float64, len(runs))
+	for i, m := range runs {
+		if m == nil {
+			y[i] = math.NaN()
+			continue
+		}
+		y[i] = m[name]
+	}
+	return y
+}
+
+// table copies the shared rows for one metric's ranking.
+func (a *analysis) table(name string, base float64) Table {
+	return Table{Metric: name, BaseMetric: base, Rows: append([]Row(nil), a.rows...)}
+}
+
+func (a *analysis) oat() (Result, error) {
+	points := []sweep.Point{{Index: 0, Values: map[string]interface{}{}}}
+	for i, p := range a.spec.Params {
+		for _, u := range []float64{0, 1} {
+			points = append(points, sweep.Point{
+				Index:  len(points),
+				Values: map[string]interface{}{p.Path: a.value(i, u)},
+			})
+		}
+	}
+	if len(points) > MaxRuns {
+		return Result{}, fmt.Errorf("design needs %d runs, more than %d", len(points), MaxRuns)
+	}
+	runs, failed := a.run(points)
+	if runs[0] == nil {
+		return Result{}, fmt.Errorf("base scenario failed to run")
+	}
+	for i := range a.rows {
+		a.rows[i].Low, a.rows[i].High = a.value(i, 0), a.value(i, 1)
+	}
+	res := Result{Method: MethodOAT, Runs: len(points), Failed: failed}
+	for _, name := range a.spec.Metrics {
+		y := metric(runs, name)
+		t := a.table(name, y[0])
+		for i := range t.Rows {
+			r := &t.Rows[i]
+			r.MetricLow, r.MetricHigh = y[1+2*i], y[2+2*i]
+			if math.IsNaN(r.MetricLow) || math.IsNaN(r.MetricHigh) {
+				r.Error = "perturbed scenario is invalid"
+				r.MetricLow, r.MetricHigh = 0, 0
+				continue
+			}
+			r.Swing = math.Abs(r.MetricHigh - r.MetricLow)
+		}
+		rank(t.Rows, func(r Row) float64 { return r.Swing })
+		res.Tables = append(res.Tables, t)
+	}
+	return res, nil
+}
+
+// morris follows Morris (1991): each trajectory starts at a random grid
+// point and moves one parameter at a time by delta = p/(2(p-1)), in random
+// order, giving one elementary effect per parameter.
+func (a *analysis) morris() (Result, error) {
+	k := len(a.rows)
+	r := a.spec.Trajectories
+	if r == 0 {
+		r = 10
+	}
+	p := a.spec.Levels
+	if p == 0 {
+		p = 4
+	}
+	if p < 2 || p%2 != 0 {
+		return Result{}, fmt.Errorf("levels must be an even number >= 2")
+	}
+	if r < 2 || r*(k+1) > MaxRuns {
+		return Result{}, fmt.Errorf("trajectories must be >= 2 and need at most %d runs", MaxRuns)
+	}
+	delta := float64(p) / (2 * float64(p-1))
+	rng := rand.New(rand.NewSource(a.spec.Seed))
+
+	var points []sweep.Point
+	moved := make([][]int, r) // moved[t][j] is the parameter changed at step j+1
+	for t := 0; t < r; t++ {
+		x := make([]float64, k)
+		for i := range x {
+			// start levels leave room for one +delta step
+			x[i] = float64(rng.Intn(p/2)) / float64(p-1)
+		}
+		order := rng.Perm(k)
+		moved[t] = order
+		points = append(points, a.morrisPoint(len(points), x))
+		for _, i := range order {
+			x[i] += delta
+			points = append(points, a.morrisPoint(len(points), x))
+		}
+	}
+	// the base run goes last so trajectory indices stay t*(k+1)+j
+	points = append(points, sweep.Point{Index: len(points), Values: map[string]interface{}{}})
+	runs, failed := a.run(points)
+
+	res := Result{Method: MethodMorris, Runs: len(points), Failed: failed}
+	for _, name := range a.spec.Metrics {
+		y := metric(runs, name)
+		t := a.table(name, y[len(y)-1])
+		effects := make([][]float64, k)
+		for tr := 0; tr < r; tr++ {
+			start := tr * (k + 1)
+			for j, i := range moved[tr] {
+				y0, y1 := y[start+j], y[start+j+1]
+				if math.IsNaN(y0) || math.IsNaN(y1) {
+					continue
+				}
+				effects[i] = append(effects[i], (y1-y0)/delta)
+			}
+		}
+		for i := range t.Rows {
+			row := &t.Rows[i]
+			ee := effects[i]
+			if len(ee) == 0 {
+				row.Error = "no valid elementary effects"
+				continue
+			}
+			var sum, abs float64
+			for _, e := range ee {
+				sum += e
+				abs += math.Abs(e)
+			}
+			n := float64(len(ee))
+			row.Mu = sum / n
+			row.MuStar = abs / n
+			if len(ee) > 1 {
+				var ss float64
+				for _, e := range ee {
+					ss += (e - row.Mu) * (e - row.Mu)
+				}
+				row.Sigma = math.Sqrt(ss / (n - 1))
+			}
+		}
+		rank(t.Rows, func(r Row) float64 { return r.MuStar })
+		res.Tables = append(res.Tables, t)
+	}
+	return res, nil
+}
+
+func (a *analysis) morrisPoint(index int, x []float64) sweep.Point {
+	values := map[string]interface{}{}
+	for i, u := range x {
+		values[a.spec.Params[i].Path] = a.value(i, u)
+	}
+	return sweep.Point{Index: index, Values: values}
+}
+
+// rank sorts rows by score, highest first, and numbers them from 1.
+func rank(rows []Row, score func(Row) float64) {
+	sort.SliceStable(rows, func(i, j int) bool { return score(rows[i]) > score(rows[j]) })
+	for i := range rows {
+		rows[i].Rank = i + 1
+	}
+}
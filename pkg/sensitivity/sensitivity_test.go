@@ -0,0 +1,118 @@
+package sensitivity
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name:     "sens",
+		Workload: schema.Workload{Name: "wl", RPS: 20, Duration: 4, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "pre", Kind: schema.StageFixedMs, Value: 1},
+			{Name: "compute", Kind: schema.StageTokens, Value: 200},
+		},
+		Target: schema.GPUProfile{
+			Name:        "GPU",
+			TFLOPS:      100,
+			MemGBps:     2000,
+			TokenCost:   0.2,
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 2,
+		},
+	}
+}
+
+var sensitivityParams = []Param{
+	{Path: "pipeline[0].value"},
+	{Path: "pipeline[1].value"},
+	{Path: "target.h2d_gbps"}, // no h2d stage, so no effect
+}
+
+func TestOATRanksDominantStage(t *testing.T) {
+	res, err := Analyze(Spec{Scenario: baseScenario(), Params: sensitivityParams, Metrics: []string{"p50_ms"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Runs != 7 || res.Failed != 0 {
+		t.Fatalf("expected 7 runs, got %d (%d failed)", res.Runs, res.Failed)
+	}
+	if len(res.Tables) != 1 || res.Tables[0].Metric != "p50_ms" {
+		t.Fatalf("expected one p50_ms table: %+v", res.Tables)
+	}
+	rows := res.Tables[0].Rows
+	if rows[0].Param != "pipeline[1].value" || rows[0].Rank != 1 {
+		t.Fatalf("compute tokens should rank first: %+v", rows)
+	}
+	last := rows[len(rows)-1]
+	if last.Param != "target.h2d_gbps" || last.Swing != 0 {
+		t.Fatalf("unused bandwidth should have no swing: %+v", last)
+	}
+	if top := rows[0]; top.Low != 160 || top.High != 240 || top.MetricHigh <= top.MetricLow {
+		t.Fatalf("unexpected tornado row %+v", top)
+	}
+}
+
+func TestMorrisRanksDominantStage(t *testing.T) {
+	res, err := Analyze(Spec{
+		Scenario:     baseScenario(),
+		Params:       sensitivityParams,
+		Method:       MethodMorris,
+		Metrics:      []string{"p50_ms"},
+		Trajectories: 6,
+		Seed:         2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Runs != 6*4+1 {
+		t.Fatalf("expected r(k+1)+1 runs, got %d", res.Runs)
+	}
+	rows := res.Tables[0].Rows
+	if rows[0].Param != "pipeline[1].value" || rows[0].MuStar <= rows[1].MuStar {
+		t.Fatalf("compute tokens should have the largest mu*: %+v", rows)
+	}
+}
+
+func TestIntegerParamAndBadPath(t *testing.T) {
+	res, err := Analyze(Spec{Scenario: baseScenario(), Params: []Param{
+		{Path: "target.concurrency", Low: 1, High: 4, Integer: true},
+	}})
+	if err != nil || res.Tables[0].Rows[0].Error != "" {
+		t.Fatalf("integer parameter should run: %v %+v", err, res.Tables)
+	}
+	if _, err := Analyze(Spec{Scenario: baseScenario(), Params: []Param{{Path: "target.name"}}}); err == nil {
+		t.Fatal("expected non-numeric field to be rejected")
+	}
+}
+
+func TestDefaultParamsAndMetricTables(t *testing.T) {
+	res, err := Analyze(Spec{Scenario: baseScenario(), Metrics: []string{"p50_ms", "throughput_rps"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"pipeline[0].value": true, "pipeline[1].value": true, "workload.rps": true, "workload.batch_size": true,
+		"target.tflops": true, "target.mem_gbps": true, "target.ms_per_token": true,
+		"target.h2d_gbps": true, "target.d2h_gbps": true, "target.concurrency": true,
+	}
+	if len(res.Tables) != 2 || res.Tables[1].Metric != "throughput_rps" || res.Runs != 2*len(want)+1 {
+		t.Fatalf("expected two tables over %d params: %d runs, %+v", len(want), res.Runs, res.Tables)
+	}
+	for _, table := range res.Tables {
+		if len(table.Rows) != len(want) {
+			t.Fatalf("%s: expected %d rows, got %+v", table.Metric, len(want), table.Rows)
+		}
+		for _, r := range table.Rows {
+			if !want[r.Param] || r.Error != "" {
+				t.Fatalf("%s: unexpected row %+v", table.Metric, r)
+			}
+		}
+	}
+	if top := res.Tables[1].Rows[0]; top.Param != "workload.rps" {
+		t.Fatalf("throughput should be driven by rps: %+v", res.Tables[1].Rows)
+	}
+}
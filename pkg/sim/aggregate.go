@@ -0,0 +1,225 @@
+package sim
+
+import (
+	"math"
+	"sort"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sketch"
+	"simulator/pkg/trace"
+)
+
+// Options selects what a serving run keeps. The zero value keeps every
+// request and every trace span, which is what Run does. Long, high-RPS runs
+// set Aggregate and sample the rest so memory stays bounded.
+type Options struct {
+	// Aggregate feeds every request into quantile sketches.
+	Aggregate bool `json:"aggregate,omitempty"`
+	// Accuracy is the sketches' relative error, default sketch.DefaultAccuracy.
+	Accuracy float64 `json:"accuracy,omitempty"`
+	// RetainEvery keeps the result of every n-th request by id: 0 keeps
+	// all, a negative value keeps none.
+	RetainEvery int `json:"retain_every,omitempty"`
+	// TraceEvery emits spans for every n-th request by id: 0 traces all, a
+	// negative value emits no trace.
+	TraceEvery int `json:"trace_every,omitempty"`
+}
+
+// Output is what RunWithOptions returns. Results holds the retained requests
+// only; Aggregate is nil unless Options.Aggregate is set.
+type Output struct {
+	Results   []RequestResult
+	Trace     trace.Trace
+	Aggregate *Aggregate
+}
+
+func sampled(every, id int) bool {
+	return every == 0 || (every > 0 && id%every == 0)
+}
+
+// Aggregate accumulates run statistics one request at a time, in memory
+// proportional to the number of distinct stages and classes rather than to
+// the number of requests.
+type Aggregate struct {
+	gpu      schema.GPUProfile
+	requests int
+	retained int
+	dropped  int
+	faulted  int
+
+	queueMS    float64
+	throttleMS float64
+	computeMS  float64
+	swaps      int
+	swapMS     float64
+	tokens     float64
+	busy       float64 // device-seconds before per-slot normalization
+	endMS      float64
+
+	latency   *sketch.Sketch
+	queue     *sketch.Sketch
+	cacheHit  *sketch.Sketch
+	cacheMiss *sketch.Sketch
+	stages    map[stageKey]*sketch.Sketch
+	classes   map[string]*sketch.Sketch
+	accuracy  float64
+}
+
+type stageKey struct {
+	cat, name string
+}
+
+func newAggregate(gpu schema.GPUProfile, accuracy float64) *Aggregate {
+	if accuracy <= 0 {
+		accuracy = sketch.DefaultAccuracy
+	}
+	return &Aggregate{
+		gpu:       gpu,
+		accuracy:  accuracy,
+		latency:   sketch.New(accuracy),
+		queue:     sketch.New(accuracy),
+		cacheHit:  sketch.New(accuracy),
+		cacheMiss: sketch.New(accuracy),
+		stages:    map[stageKey]*sketch.Sketch{},
+		classes:   map[string]*sketch.Sketch{},
+	}
+}
+
+// observe adds one request, with the same accounting as Summarize.
+func (a *Aggregate) observe(r RequestResult) {
+	a.requests++
+	if r.Fault != "" {
+		a.faulted++
+	}
+	if r.Dropped {
+		a.dropped++
+	} else {
+		a.latency.Add(r.LatencyMS)
+		if r.Class != "" {
+			cs := a.classes[r.Class]
+			if cs == nil {
+				cs = sketch.New(a.accuracy)
+				a.classes[r.Class] = cs
+			}
+			cs.Add(r.LatencyMS)
+		}
+	}
+	switch r.Cache {
+	case cacheHit:
+		a.cacheHit.Add(r.LatencyMS)
+	case cacheMiss:
+		a.cacheMiss.Add(r.LatencyMS)
+	}
+	a.queue.Add(r.QueueMS)
+	a.queueMS += r.QueueMS
+	a.throttleMS += r.ThrottleMS
+	a.tokens += r.Tokens
+	a.busy += requestBusySeconds(r, a.gpu)
+	if r.EndMS > a.endMS {
+		a.endMS = r.EndMS
+	}
+	for _, st := range r.Stages {
+		d := st.End - st.Start
+		switch st.Cat {
+		case "swap":
+			a.swaps++
+			a.swapMS += d
+		case "compute":
+			a.computeMS += d
+		}
+		key := stageKey{st.Cat, st.Name}
+		ss := a.stages[key]
+		if ss == nil {
+			ss = sketch.New(a.accuracy)
+			a.stages[key] = ss
+		}
+		ss.Add(d)
+	}
+}
+
+// Requests returns the number of requests observed.
+func (a *Aggregate) Requests() int { return a.requests }
+
+// Summary mirrors Summarize over every observed request, with percentiles
+// read from the sketches.
+func (a *Aggregate) Summary(durationS float64) schema.Summary {
+	if a.requests == 0 {
+		return schema.Summary{}
+	}
+	duration := durationS
+	if duration == 0 {
+		duration = 1
+	}
+	throughput := float64(a.latency.Count()) / duration
+	sum := schema.Summary{
+		Throughput:      throughput,
+		P50LatencyMS:    a.latency.Quantile(50),
+		P90LatencyMS:    a.latency.Quantile(90),
+		P99LatencyMS:    a.latency.Quantile(99),
+		AvgQueueMS:      a.queueMS / float64(a.requests),
+		GPUUtilization:  math.Min(100, throughput*100/float64(a.gpu.Concurrency)),
+		TotalRequests:   a.requests,
+		DurationS:       duration,
+		DroppedRequests: a.dropped,
+		FaultedRequests: a.faulted,
+		ModelSwaps:      a.swaps,
+		SwapMS:          a.swapMS,
+	}
+	if a.throttleMS > 0 && a.computeMS > 0 {
+		sum.ThrottleMS = a.throttleMS
+		sum.ThrottleLossPct = a.throttleMS / a.computeMS * 100
+	}
+	if hits, misses := a.cacheHit.Count(), a.cacheMiss.Count(); hits+misses > 0 {
+		sum.Cache = &schema.CacheSummary{
+			Hits:      hits,
+			Misses:    misses,
+			HitRatio:  float64(hits) / float64(hits+misses),
+			HitAvgMS:  a.cacheHit.Mean(),
+			HitP99MS:  a.cacheHit.Quantile(99),
+			MissAvgMS: a.cacheMiss.Mean(),
+			MissP99MS: a.cacheMiss.Quantile(99),
+		}
+	}
+	applyCostTotals(&sum, a.gpu, a.endMS/1000, a.busy/float64(a.gpu.Concurrency), a.requests, a.tokens)
+	return sum
+}
+
+// Report returns the sketch quantiles for end-to-end latency, queue time,
+// every stage and every class, stages and classes sorted by name.
+func (a *Aggregate) Report() schema.AggregateReport {
+	rep := schema.AggregateReport{
+		RelativeAccuracy: a.accuracy,
+		Requests:         a.requests,
+		RetainedRequests: a.retained,
+		Latency:          latencyStats("", "", a.latency),
+		Queue:            latencyStats("", "", a.queue),
+		Stages:           make([]schema.LatencyStats, 0, len(a.stages)),
+	}
+	for key, ss := range a.stages {
+		rep.Stages = append(rep.Stages, latencyStats(key.name, key.cat, ss))
+	}
+	sort.Slice(rep.Stages, func(i, j int) bool {
+		if rep.Stages[i].Category != rep.Stages[j].Category {
+			return rep.Stages[i].Category < rep.Stages[j].Category
+		}
+		return rep.Stages[i].Name < rep.Stages[j].Name
+	})
+	for name, cs := range a.classes {
+		rep.Classes = append(rep.Classes, latencyStats(name, "", cs))
+	}
+	sort.Slice(rep.Classes, func(i, j int) bool { return rep.Classes[i].Name < rep.Classes[j].Name })
+	return rep
+}
+
+func latencyStats(name, cat string, s *sketch.Sketch) schema.LatencyStats {
+	return schema.LatencyStats{
+		Name:     name,
+		Category: cat,
+		Count:    s.Count(),
+		MeanMS:   s.Mean(),
+		P50MS:    s.Quantile(50),
+		P90MS:    s.Quantile(90),
+		P99MS:    s.Quantile(99),
+		MaxMS:    s.Max(),
+	}
+}
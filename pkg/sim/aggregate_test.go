@@ -0,0 +1,62 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAggregateMatchesExactSummary(t *testing.T) {
+	s := branchScenario()
+	results, _ := Run(s, 5)
+	exact := Summarize(results, s.Workload.Duration, s.Target)
+	out := RunWithOptions(s, 5, Options{Aggregate: true})
+	got := out.Aggregate.Summary(s.Workload.Duration)
+
+	if got.TotalRequests != exact.TotalRequests || got.Throughput != exact.Throughput || got.AvgQueueMS != exact.AvgQueueMS {
+		t.Fatalf("counts and means should be exact: %+v vs %+v", got, exact)
+	}
+	for _, pair := range [][2]float64{{got.P50LatencyMS, exact.P50LatencyMS}, {got.P99LatencyMS, exact.P99LatencyMS}} {
+		if math.Abs(pair[0]-pair[1])/pair[1] > 0.01 {
+			t.Fatalf("sketch percentile %v too far from exact %v", pair[0], pair[1])
+		}
+	}
+	rep := out.Aggregate.Report()
+	if len(rep.Classes) != 2 || len(rep.Stages) == 0 || rep.RetainedRequests != len(results) {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestSampledRetentionAndTrace(t *testing.T) {
+	s := branchScenario()
+	full := RunWithOptions(s, 5, Options{})
+	out := RunWithOptions(s, 5, Options{Aggregate: true, RetainEvery: 10, TraceEvery: -1})
+	if len(out.Results) != len(full.Results)/10 || out.Results[1].ID != 10 {
+		t.Fatalf("expected every 10th request, got %d", len(out.Results))
+	}
+	if out.Results[1].LatencyMS != full.Results[10].LatencyMS {
+		t.Fatal("sampling must not change the simulation")
+	}
+	if len(out.Trace.Events) != 0 {
+		t.Fatalf("tracing disabled but got %d events", len(out.Trace.Events))
+	}
+	if out.Aggregate.Requests() != len(full.Results) {
+		t.Fatalf("aggregate should see every request")
+	}
+}
+
+func TestAggregateOnlyRunKeepsNothing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("long run")
+	}
+	s := branchScenario()
+	s.Workload.RPS = 10000
+	s.Workload.Duration = 50
+	s.Target.Concurrency = 64
+	out := RunWithOptions(s, 1, Options{Aggregate: true, RetainEvery: -1, TraceEvery: -1})
+	if len(out.Results) != 0 || len(out.Trace.Events) != 0 {
+		t.Fatalf("expected nothing retained, got %d results and %d events", len(out.Results), len(out.Trace.Events))
+	}
+	if out.Aggregate.Requests() != 500000 {
+		t.Fatalf("expected 500000 requests, got %d", out.Aggregate.Requests())
+	}
+}
@@ -0,0 +1,192 @@
+package sim
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"simulator/pkg/schema"
+)
+
+// plannedStage is a pipeline stage after branches have been resolved. key is
+// a stable path ("2/fallback/0") used to look up per-stage models.
+type plannedStage struct {
+	schema.Stage
+	key string
+}
+
+// router resolves a request's pipeline: it assigns the request class and
+// expands branch stages into the chosen sub-pipeline.
+type router struct {
+	pipeline  []schema.Stage
+	static    []plannedStage // resolved once when the pipeline has no branches
+	buf       []plannedStage // reused by plan for branching pipelines
+	classes   []schema.RequestClass
+	weightSum float64
+	seed      int64
+}
+
+func newRouter(s schema.Scenario, seed int64) *router {
+	r := &router{
+		pipeline: s.Pipeline,
+		classes:  s.Workload.Classes,
+		seed:     seed,
+	}
+	for _, c := range r.classes {
+		r.weightSum += c.Weight
+	}
+	if !hasBranches(s.Pipeline) {
+		r.static = make([]plannedStage, len(s.Pipeline))
+		for i, st := range s.Pipeline {
+			r.static[i] = plannedStage{Stage: st, key: strconv.Itoa(i)}
+		}
+	}
+	return r
+}
+
+// class draws request id's class by weight; empty when no classes are
+// declared.
+func (r *router) class(id int) (string, map[string]string) {
+	if len(r.classes) == 0 {
+		return "", nil
+	}
+	x := requestUniform(r.seed, id, classStreamSalt) * r.weightSum
+	c := r.classes[len(r.classes)-1]
+	for _, cand := range r.classes {
+		if x < cand.Weight {
+			c = cand
+			break
+		}
+		x -= cand.Weight
+	}
+	return c.Name, classAttrs(c)
+}
+
+// classAttrs are the attributes When clauses see for class c: its own plus
+// "class" set to its name.
+func classAttrs(c schema.RequestClass) map[string]string {
+	attrs := map[string]string{"class": c.Name}
+	for k, v := range c.Attributes {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// plan returns the stages request id runs and the "stage/branch" labels of
+// the branches it took. The stages are only valid until the next call.
+func (r *router) plan(id int, attrs map[string]string) ([]plannedStage, []string) {
+	if r.static != nil {
+		return r.static, nil
+	}
+	out := r.buf[:0]
+	var taken []string
+	rng := newRequestStream(r.seed, id, branchStreamSalt)
+	r.expand(r.pipeline, "", attrs, &rng, &out, &taken)
+	r.buf = out
+	return out, taken
+}
+
+func (r *router) expand(stages []schema.Stage, prefix string, attrs map[string]string, rng uniformSource, out *[]plannedStage, taken *[]string) {
+	for i, st := range stages {
+		key := prefix + strconv.Itoa(i)
+		if st.Kind != schema.StageBranch {
+			*out = append(*out, plannedStage{Stage: st, key: key})
+			continue
+		}
+		b := r.choose(st.Branches, attrs, rng)
+		if b == nil {
+			continue
+		}
+		*taken = append(*taken, st.Name+"/"+b.Name)
+		r.expand(b.Pipeline, key+"/"+b.Name+"/", attrs, rng, out, taken)
+	}
+}
+
+// choose picks the first branch whose When matches, otherwise draws one by
+// probability. It returns nil when the draw lands in the unassigned remainder.
+func (r *router) choose(branches []schema.Branch, attrs map[string]string, rng uniformSource) *schema.Branch {
+	for i := range branches {
+		if len(branches[i].When) > 0 && matches(branches[i].When, attrs) {
+			return &branches[i]
+		}
+	}
+	x := rng.Float64()
+	for i := range branches {
+		if len(branches[i].When) > 0 {
+			continue
+		}
+		if x < branches[i].Probability {
+			return &branches[i]
+		}
+		x -= branches[i].Probability
+	}
+	return nil
+}
+
+func matches(when, attrs map[string]string) bool {
+	for k, v := range when {
+		if attrs[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func hasBranches(stages []schema.Stage) bool {
+	for _, st := range stages {
+		if st.Kind == schema.StageBranch {
+			return true
+		}
+	}
+	return false
+}
+
+// walkStages visits every stage, including those nested in branches, with
+// the same keys the router assigns.
+func walkStages(stages []schema.Stage, prefix string, fn func(key string, st schema.Stage)) {
+	for i, st := range stages {
+		key := prefix + strconv.Itoa(i)
+		fn(key, st)
+		for _, b := range st.Branches {
+			walkStages(b.Pipeline, key+"/"+b.Name+"/", fn)
+		}
+	}
+}
+
+// branchAggregates reports per-branch counts and latency percentiles.
+func branchAggregates(results []RequestResult) []schema.BranchAggregate {
+	byBranch := map[string][]float64{}
+	for _, r := range results {
+		for _, b := range r.Branches {
+			byBranch[b] = append(byBranch[b], r.LatencyMS)
+		}
+	}
+	if len(byBranch) == 0 {
+		return nil
+	}
+	out := make([]schema.BranchAggregate, 0, len(byBranch))
+	for label, lat := range byBranch {
+		sort.Float64s(lat)
+		stage, branch := label, ""
+		if i := strings.LastIndex(label, "/"); i >= 0 {
+			stage, branch = label[:i], label[i+1:]
+		}
+		out = append(out, schema.BranchAggregate{
+			Stage:  stage,
+			Branch: branch,
+			Count:  len(lat),
+			Share:  float64(len(lat)) / float64(len(results)),
+			AvgMS:  mean(lat),
+			P50MS:  percentile(lat, 50),
+			P90MS:  percentile(lat, 90),
+			P99MS:  percentile(lat, 99),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Stage != out[j].Stage {
+			return out[i].Stage < out[j].Stage
+		}
+		return out[i].Branch < out[j].Branch
+	})
+	return out
+}
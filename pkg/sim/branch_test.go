@@ -0,0 +1,109 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func branchScenario() schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = 100
+	s.Workload.Duration = 10
+	s.Workload.Classes = []schema.RequestClass{
+		{Name: "text", Weight: 3},
+		{Name: "scan", Weight: 1, Attributes: map[string]string{"needs_ocr": "yes"}},
+	}
+	s.Pipeline = []schema.Stage{
+		{Name: "ingest", Kind: schema.StageBranch, Branches: []schema.Branch{
+			{Name: "ocr", When: map[string]string{"needs_ocr": "yes"}, Pipeline: []schema.Stage{
+				{Name: "ocr", Kind: schema.StageFixedMs, Value: 20},
+			}},
+		}},
+		{Name: "compute", Kind: schema.StageTokens, Value: 50},
+		{Name: "safety", Kind: schema.StageBranch, Branches: []schema.Branch{
+			{Name: "fallback", Probability: 0.05, Pipeline: []schema.Stage{
+				{Name: "second-model", Kind: schema.StageTokens, Value: 200},
+			}},
+			{Name: "pass", Probability: 0.95},
+		}},
+	}
+	s.Target.Concurrency = 16
+	return s
+}
+
+func TestBranchesRouteByProbabilityAndClass(t *testing.T) {
+	s := branchScenario()
+	if err := schema.ValidateScenario(s); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	results, _ := Run(s, 11)
+	for _, r := range results {
+		ocr := false
+		for _, st := range r.Stages {
+			if st.Name == "ocr" {
+				ocr = true
+			}
+		}
+		if ocr != (r.Class == "scan") {
+			t.Fatalf("request %d class %s ran ocr=%v", r.ID, r.Class, ocr)
+		}
+	}
+
+	b := Breakdown(results)
+	got := map[string]schema.BranchAggregate{}
+	for _, a := range b.Branches {
+		got[a.Stage+"/"+a.Branch] = a
+	}
+	fallback, pass := got["safety/fallback"], got["safety/pass"]
+	if fallback.Count+pass.Count != len(results) {
+		t.Fatalf("every request should take a safety branch: %+v", b.Branches)
+	}
+	if fallback.Share < 0.02 || fallback.Share > 0.08 {
+		t.Fatalf("expected ~5%% fallback, got %f", fallback.Share)
+	}
+	if fallback.P50MS <= pass.P50MS {
+		t.Fatalf("fallback requests should be slower: fallback=%f pass=%f", fallback.P50MS, pass.P50MS)
+	}
+	if got["ingest/ocr"].Count == 0 {
+		t.Fatalf("expected ocr branch counts")
+	}
+}
+
+func TestBranchesDeterministicPerSeed(t *testing.T) {
+	s := branchScenario()
+	a, _ := Run(s, 5)
+	b, _ := Run(s, 5)
+	for i := range a {
+		if len(a[i].Branches) != len(b[i].Branches) || a[i].LatencyMS != b[i].LatencyMS {
+			t.Fatalf("request %d differs between identical runs", i)
+		}
+	}
+}
+
+func TestRequestDrawsSurviveExtraStage(t *testing.T) {
+	a := branchScenario()
+	b := branchScenario()
+	b.Pipeline = append(b.Pipeline, schema.Stage{Name: "postprocess", Kind: schema.StageFixedMs, Value: 1})
+	ra, _ := Run(a, 3)
+	rb, _ := Run(b, 3)
+	for i := range ra {
+		if ra[i].ArrivalMS != rb[i].ArrivalMS || ra[i].Class != rb[i].Class ||
+			strings.Join(ra[i].Branches, ",") != strings.Join(rb[i].Branches, ",") {
+			t.Fatalf("request %d draws differ between variants: %+v vs %+v", i, ra[i], rb[i])
+		}
+		if da, db := stageDuration(ra[i], "compute"), stageDuration(rb[i], "compute"); da != db {
+			t.Fatalf("request %d compute jitter differs: %v vs %v", i, da, db)
+		}
+	}
+}
+
+func stageDuration(r RequestResult, name string) float64 {
+	for _, st := range r.Stages {
+		if st.Name == name {
+			return st.End - st.Start
+		}
+	}
+	return 0
+}
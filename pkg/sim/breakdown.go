@@ -1,21 +1,42 @@
 package sim
 
-import "simulator/pkg/schema"
+import (
+	"sort"
+
+	"simulator/pkg/schema"
+)
 
 // Breakdown computes stage aggregates and per-request tables for API output.
+// Stage and category aggregates carry exact percentiles and a histogram of
+// span durations. Queue waits are per request instead: the engine only
+// records a queue span when a request waits, so each queue stage and the
+// queue category count every request, with zero for requests that did not
+// wait, and agree with the run's queue time.
 func Breakdown(results []RequestResult) schema.Breakdown {
-	aggMap := map[string]schema.StageAggregate{}
+	byStage := map[stageKey][]float64{}
+	byCat := map[string][]float64{}
+	latencies := make([]float64, 0, len(results))
 	reqs := make([]schema.RequestBreakdown, 0, len(results))
+	var waits []queueWait // per-request scratch
 
 	for _, r := range results {
+		waits = waits[:0]
 		for _, st := range r.Stages {
-			key := st.Cat + ":" + st.Name
-			a := aggMap[key]
-			a.Name = st.Name
-			a.Category = st.Cat
-			a.Count++
-			a.TotalMS += st.End - st.Start
-			aggMap[key] = a
+			d := st.End - st.Start
+			key := stageKey{st.Cat, st.Name}
+			if st.Cat == "queue" {
+				waits = addWait(waits, key, d)
+				continue
+			}
+			byStage[key] = append(byStage[key], d)
+			byCat[st.Cat] = append(byCat[st.Cat], d)
+		}
+		for _, w := range waits {
+			byStage[w.key] = append(byStage[w.key], w.ms)
+		}
+		byCat["queue"] = append(byCat["queue"], r.QueueMS)
+		if !r.Dropped {
+			latencies = append(latencies, r.LatencyMS)
 		}
 		reqs = append(reqs, schema.RequestBreakdown{
 			ID:        r.ID,
@@ -25,21 +46,77 @@ func Breakdown(results []RequestResult) schema.Breakdown {
 			QueueMS:   r.QueueMS,
 			TotalMS:   r.LatencyMS,
 			Stages:    toSchemaStages(r.Stages),
+			Cache:     r.Cache,
+			Class:     r.Class,
+			Branches:  r.Branches,
+			Fault:     r.Fault,
+			Dropped:   r.Dropped,
 		})
 	}
 
-	aggs := make([]schema.StageAggregate, 0, len(aggMap))
-	for _, a := range aggMap {
-		if a.Count > 0 {
-			a.AvgMS = a.TotalMS / float64(a.Count)
+	aggs := make([]schema.StageAggregate, 0, len(byStage))
+	for key, durs := range byStage {
+		if key.cat == "queue" {
+			durs = append(durs, make([]float64, len(results)-len(durs))...)
+		}
+		aggs = append(aggs, stageAggregate(key.name, key.cat, durs))
+	}
+	sort.Slice(aggs, func(i, j int) bool {
+		if aggs[i].Category != aggs[j].Category {
+			return aggs[i].Category < aggs[j].Category
 		}
-		aggs = append(aggs, a)
+		return aggs[i].Name < aggs[j].Name
+	})
+	cats := make([]schema.StageAggregate, 0, len(byCat))
+	for cat, durs := range byCat {
+		cats = append(cats, stageAggregate("", cat, durs))
 	}
+	sort.Slice(cats, func(i, j int) bool { return cats[i].Category < cats[j].Category })
 
 	return schema.Breakdown{
-		StageAggregates: aggs,
-		Requests:        reqs,
+		StageAggregates:    aggs,
+		CategoryAggregates: cats,
+		LatencyHistogram:   newHistogram(latencies),
+		Requests:           reqs,
+		Cache:              summarizeCache(results),
+		Branches:           branchAggregates(results),
+	}
+}
+
+type queueWait struct {
+	key stageKey
+	ms  float64
+}
+
+// addWait adds d to the request's total wait at key.
+func addWait(waits []queueWait, key stageKey, d float64) []queueWait {
+	for i := range waits {
+		if waits[i].key == key {
+			waits[i].ms += d
+			return waits
+		}
+	}
+	return append(waits, queueWait{key, d})
+}
+
+// stageAggregate summarizes span durations; it sorts durs in place.
+func stageAggregate(name, cat string, durs []float64) schema.StageAggregate {
+	sort.Float64s(durs)
+	a := schema.StageAggregate{
+		Name:      name,
+		Category:  cat,
+		Count:     len(durs),
+		P50MS:     percentile(durs, 50),
+		P90MS:     percentile(durs, 90),
+		P99MS:     percentile(durs, 99),
+		MaxMS:     durs[len(durs)-1],
+		Histogram: newHistogram(durs),
+	}
+	for _, d := range durs {
+		a.TotalMS += d
 	}
+	a.AvgMS = a.TotalMS / float64(a.Count)
+	return a
 }
 
 func toSchemaStages(sts []StageTiming) []schema.StageTiming {
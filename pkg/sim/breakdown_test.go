@@ -0,0 +1,70 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func TestBreakdownPercentilesAndHistograms(t *testing.T) {
+	s := branchScenario()
+	results, _ := Run(s, 2)
+	b := Breakdown(results)
+
+	var queue *schema.StageAggregate
+	for i, c := range b.CategoryAggregates {
+		if c.Category == "queue" {
+			queue = &b.CategoryAggregates[i]
+		}
+	}
+	if queue == nil || queue.Count != len(results) {
+		t.Fatalf("expected a queue category aggregate over every request: %+v", b.CategoryAggregates)
+	}
+	var queueMS float64
+	for _, r := range results {
+		queueMS += r.QueueMS
+	}
+	if math.Abs(queue.TotalMS-queueMS) > 1e-6 {
+		t.Fatalf("queue category total %f, want the summed queue time %f", queue.TotalMS, queueMS)
+	}
+	for _, a := range b.StageAggregates {
+		if a.Category == "queue" && a.Count != len(results) {
+			t.Fatalf("queue stage %s counts %d of %d requests", a.Name, a.Count, len(results))
+		}
+	}
+	for _, a := range append(b.StageAggregates, b.CategoryAggregates...) {
+		if !(a.P50MS <= a.P90MS && a.P90MS <= a.P99MS && a.P99MS <= a.MaxMS) {
+			t.Fatalf("%s/%s: percentiles out of order: %+v", a.Category, a.Name, a)
+		}
+		if n := histogramCount(a.Histogram); n != a.Count {
+			t.Fatalf("%s/%s: histogram holds %d of %d spans", a.Category, a.Name, n, a.Count)
+		}
+	}
+	if histogramCount(b.LatencyHistogram) != len(results) {
+		t.Fatalf("latency histogram should count every completed request")
+	}
+}
+
+func TestHistogramBucketsContainValues(t *testing.T) {
+	for _, v := range []float64{0, 0.0005, 0.001, 0.75, 1, 1.1, 3.9, 100, 12345.6} {
+		lo, hi := bucketBounds(bucketKey(v))
+		if v < lo || v >= hi {
+			t.Fatalf("%v not in [%v, %v)", v, lo, hi)
+		}
+		if lo >= histogramMinMS && (hi-lo)/lo > 1.0/histogramSubBuckets+1e-12 {
+			t.Fatalf("bucket [%v, %v) wider than 1/%d", lo, hi, histogramSubBuckets)
+		}
+	}
+}
+
+func histogramCount(h *schema.Histogram) int {
+	if h == nil {
+		return 0
+	}
+	n := 0
+	for _, b := range h.Buckets {
+		n += b.Count
+	}
+	return n
+}
@@ -0,0 +1,217 @@
+package sim
+
+import (
+	"container/list"
+	"math"
+	"sort"
+	"strconv"
+
+	"simulator/pkg/schema"
+)
+
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// cacheSeedSalt keeps cache draws on their own stream so adding a cache stage
+// does not shift the arrival and jitter draws of the rest of the run.
+const cacheSeedSalt = 0x63616368
+
+// cacheModel decides hits for one cache stage.
+type cacheModel struct {
+	cfg  schema.CacheConfig
+	seed int64
+	salt uint64
+	zipf *zipfKeys
+	lru  *lruKeys
+}
+
+// newCacheModels builds a model per cache stage, keyed like plannedStage.key.
+func newCacheModels(pipeline []schema.Stage, seed int64) map[string]*cacheModel {
+	models := map[string]*cacheModel{}
+	walkStages(pipeline, "", func(key string, st schema.Stage) {
+		if st.Kind != schema.StageCache || st.Cache == nil {
+			return
+		}
+		m := &cacheModel{cfg: *st.Cache, seed: seed, salt: stageSalt(key, cacheSeedSalt), lru: newLRUKeys(st.Cache.Capacity)}
+		if st.Cache.Model == schema.CacheZipf {
+			m.zipf = newZipfKeys(st.Cache.KeySpace, st.Cache.ZipfS)
+		}
+		models[key] = m
+	})
+	return models
+}
+
+// lookup reports whether request id hits the cache. Fixed-ratio and Zipf
+// draws are per request, so the same request asks for the same key in two
+// variants of a scenario.
+func (m *cacheModel) lookup(id int) bool {
+	switch m.cfg.Model {
+	case schema.CacheZipf:
+		k := m.zipf.key(requestUniform(m.seed, id, m.salt))
+		return m.lru.touch(strconv.Itoa(k))
+	case schema.CacheReplay:
+		return m.lru.touch(m.cfg.Keys[id%len(m.cfg.Keys)])
+	default:
+		return requestUniform(m.seed, id, m.salt) < m.cfg.HitRatio
+	}
+}
+
+// zipfTableSize bounds the keys whose cumulative weights are tabulated.
+const zipfTableSize = 1 << 16
+
+// zipfKeys maps a uniform draw to a key in [0, n) with probability
+// proportional to (1+k)^-s, as rand.Zipf with v = 1, by inverting the CDF.
+// The first zipfTableSize keys are looked up in a table of cumulative
+// weights; the tail, where the weights change slowly, inverts their
+// integral instead.
+type zipfKeys struct {
+	n     int
+	s     float64
+	cum   []float64
+	total float64
+}
+
+func newZipfKeys(n int, s float64) *zipfKeys {
+	h := n
+	if h > zipfTableSize {
+		h = zipfTableSize
+	}
+	z := &zipfKeys{n: n, s: s, cum: make([]float64, h)}
+	sum := 0.0
+	for k := range z.cum {
+		sum += math.Pow(float64(1+k), -s)
+		z.cum[k] = sum
+	}
+	z.total = sum
+	if n > h {
+		// sum of (1+k)^-s over [h, n) ~ integral over [h-0.5, n-0.5)
+		z.total += (z.tailCDF(float64(n)-0.5) - z.tailCDF(float64(h)-0.5))
+	}
+	return z
+}
+
+// tailCDF is an antiderivative of (1+x)^-s.
+func (z *zipfKeys) tailCDF(x float64) float64 {
+	return math.Pow(1+x, 1-z.s) / (1 - z.s)
+}
+
+// key returns the key drawn by u in [0, 1).
+func (z *zipfKeys) key(u float64) int {
+	x := u * z.total
+	h := len(z.cum)
+	if x < z.cum[h-1] || h == z.n {
+		k := sort.SearchFloat64s(z.cum, x)
+		if k < h && z.cum[k] == x {
+			k++ // cum[k] is the upper bound of key k's interval
+		}
+		if k >= h {
+			k = h - 1
+		}
+		return k
+	}
+	// solve tailCDF(y) - tailCDF(h-0.5) = x - cum[h-1] for y
+	c := z.tailCDF(float64(h)-0.5) + x - z.cum[h-1]
+	y := math.Pow(c*(1-z.s), 1/(1-z.s)) - 1
+	k := int(math.Floor(y + 0.5))
+	if k < h {
+		k = h
+	}
+	if k >= z.n {
+		k = z.n - 1
+	}
+	return k
+}
+
+// effect returns how a hit changes downstream stages.
+func (m *cacheModel) effect() cacheEffect {
+	e := cacheEffect{active: true, skip: m.cfg.OnHit == schema.CacheSkip, factor: m.cfg.ShrinkFactor}
+	if len(m.cfg.Affects) > 0 {
+		e.only = map[string]bool{}
+		for _, name := range m.cfg.Affects {
+			e.only[name] = true
+		}
+	}
+	return e
+}
+
+// cacheEffect is applied to every stage after a cache hit.
+type cacheEffect struct {
+	active bool
+	skip   bool
+	factor float64
+	only   map[string]bool
+}
+
+func (e cacheEffect) applies(st schema.Stage) bool {
+	if !e.active {
+		return false
+	}
+	return e.only == nil || e.only[st.Name]
+}
+
+// lruKeys is a bounded set of recently seen keys; capacity 0 is unbounded.
+type lruKeys struct {
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+func newLRUKeys(capacity int) *lruKeys {
+	return &lruKeys{capacity: capacity, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// touch records key and reports whether it was already cached.
+func (l *lruKeys) touch(key string) bool {
+	if el, ok := l.items[key]; ok {
+		l.order.MoveToFront(el)
+		return true
+	}
+	l.items[key] = l.order.PushFront(key)
+	if l.capacity > 0 && l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(string))
+	}
+	return false
+}
+
+// summarizeCache splits latency by cache outcome; nil when no request looked
+// up a cache.
+func summarizeCache(results []RequestResult) *schema.CacheSummary {
+	var hits, misses []float64
+	for _, r := range results {
+		switch r.Cache {
+		case cacheHit:
+			hits = append(hits, r.LatencyMS)
+		case cacheMiss:
+			misses = append(misses, r.LatencyMS)
+		}
+	}
+	if len(hits)+len(misses) == 0 {
+		return nil
+	}
+	sort.Float64s(hits)
+	sort.Float64s(misses)
+	return &schema.CacheSummary{
+		Hits:      len(hits),
+		Misses:    len(misses),
+		HitRatio:  float64(len(hits)) / float64(len(hits)+len(misses)),
+		HitAvgMS:  mean(hits),
+		HitP99MS:  percentile(hits, 99),
+		MissAvgMS: mean(misses),
+		MissP99MS: percentile(misses, 99),
+	}
+}
+
+func mean(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
@@ -0,0 +1,134 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func cacheScenario(cfg schema.CacheConfig) schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = 50
+	s.Workload.Duration = 4
+	s.Pipeline = []schema.Stage{
+		{Name: "lookup", Kind: schema.StageCache, Value: 0.5, Cache: &cfg},
+		{Name: "prefill", Kind: schema.StageTokens, Value: 100},
+		{Name: "post", Kind: schema.StageFixedMs, Value: 1},
+	}
+	s.Target.TokenCost = 0.2
+	s.Target.Concurrency = 4
+	return s
+}
+
+func TestFixedHitRatioSkipsCompute(t *testing.T) {
+	s := cacheScenario(schema.CacheConfig{Model: schema.CacheFixed, HitRatio: 0.3, OnHit: schema.CacheSkip})
+	if err := schema.ValidateScenario(s); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	results, _ := Run(s, 7)
+	sum := Summarize(results, s.Workload.Duration, s.Target)
+	if sum.Cache == nil {
+		t.Fatalf("expected cache summary")
+	}
+	if sum.Cache.HitRatio < 0.2 || sum.Cache.HitRatio > 0.4 {
+		t.Fatalf("expected ~30%% hits, got %f", sum.Cache.HitRatio)
+	}
+	if sum.Cache.HitAvgMS >= sum.Cache.MissAvgMS {
+		t.Fatalf("hits should be faster: hit=%f miss=%f", sum.Cache.HitAvgMS, sum.Cache.MissAvgMS)
+	}
+	for _, r := range results {
+		if r.Cache != cacheHit {
+			continue
+		}
+		for _, st := range r.Stages {
+			if st.Name == "prefill" || st.Name == "post" {
+				t.Fatalf("request %d hit but ran %s", r.ID, st.Name)
+			}
+		}
+	}
+	if b := Breakdown(results); b.Cache == nil || b.Cache.Hits != sum.Cache.Hits {
+		t.Fatalf("breakdown cache summary mismatch: %+v", b.Cache)
+	}
+}
+
+func TestPrefixCacheShrinksAffectedStages(t *testing.T) {
+	s := cacheScenario(schema.CacheConfig{
+		Model:        schema.CacheReplay,
+		Keys:         []string{"a", "b"},
+		OnHit:        schema.CacheShrink,
+		ShrinkFactor: 0.25,
+		Affects:      []string{"prefill"},
+	})
+	results, _ := Run(s, 1)
+	// two distinct keys: only the first two requests miss
+	if results[0].Cache != cacheMiss || results[1].Cache != cacheMiss || results[2].Cache != cacheHit {
+		t.Fatalf("unexpected replay outcomes: %s %s %s", results[0].Cache, results[1].Cache, results[2].Cache)
+	}
+	var post bool
+	for _, st := range results[2].Stages {
+		if st.Name == "prefill" && st.End-st.Start > 6 {
+			t.Fatalf("prefill should shrink to ~5ms, got %f", st.End-st.Start)
+		}
+		if st.Name == "post" {
+			post = true
+		}
+	}
+	if !post {
+		t.Fatalf("stages outside affects should still run")
+	}
+}
+
+func TestZipfCacheWarmsUp(t *testing.T) {
+	s := cacheScenario(schema.CacheConfig{Model: schema.CacheZipf, KeySpace: 1000, ZipfS: 1.2, Capacity: 100, OnHit: schema.CacheSkip})
+	results, _ := Run(s, 1)
+	firstHalf, secondHalf := 0, 0
+	for i, r := range results {
+		if r.Cache != cacheHit {
+			continue
+		}
+		if i < len(results)/2 {
+			firstHalf++
+		} else {
+			secondHalf++
+		}
+	}
+	if secondHalf == 0 || secondHalf < firstHalf {
+		t.Fatalf("expected warm cache to hit more: first=%d second=%d", firstHalf, secondHalf)
+	}
+}
+
+func TestZipfKeysArePerRequest(t *testing.T) {
+	cfg := schema.CacheConfig{Model: schema.CacheZipf, KeySpace: 1000, ZipfS: 1.2, OnHit: schema.CacheSkip}
+	pipeline := []schema.Stage{{Name: "lookup", Kind: schema.StageCache, Value: 0.5, Cache: &cfg}}
+	cold := newCacheModels(pipeline, 5)["0"]
+	warm := newCacheModels(pipeline, 5)["0"]
+	for id := 0; id < 20; id++ {
+		warm.lookup(id)
+	}
+	cold.lookup(42)
+	warm.lookup(42)
+	if a, b := cold.lru.order.Front().Value, warm.lru.order.Front().Value; a != b {
+		t.Fatalf("request 42 should ask for the same key after other lookups: %v vs %v", a, b)
+	}
+}
+
+func TestZipfKeysFollowTheDistribution(t *testing.T) {
+	z := newZipfKeys(1<<20, 1.1)
+	counts := map[int]int{}
+	const n = 200000
+	tail := 0
+	for i := 0; i < n; i++ {
+		k := z.key(requestUniform(1, i, 0))
+		counts[k]++
+		if k >= zipfTableSize {
+			tail++
+		}
+	}
+	// P(0)/P(1) = 2^1.1
+	if ratio := float64(counts[0]) / float64(counts[1]); ratio < 2.0 || ratio > 2.3 {
+		t.Fatalf("key 0 should be ~2.14x as likely as key 1, got %.2f", ratio)
+	}
+	if tail == 0 {
+		t.Fatal("keys beyond the table should be drawn")
+	}
+}
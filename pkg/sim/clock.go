@@ -0,0 +1,81 @@
+package sim
+
+import "math"
+
+// vtime is a point or span on the virtual clock in integer nanoseconds.
+// Resource availability and request timelines are kept in vtime so repeated
+// additions and comparisons are exact; durations from the cost models are
+// rounded once, when they enter the clock.
+type vtime int64
+
+func fromSeconds(s float64) vtime {
+	return vtime(math.Round(s * 1e9))
+}
+
+func (t vtime) seconds() float64 {
+	return float64(t) / 1e9
+}
+
+func (t vtime) ms() float64 {
+	return float64(t) / 1e6
+}
+
+func maxTime(a, b vtime) vtime {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// slotHeap is a min-heap of slot availability, ordered by free time and
+// then by slot index so ties go to the lowest slot.
+type slotHeap struct {
+	free []vtime
+	idx  []int
+}
+
+func newSlotHeap(n int) *slotHeap {
+	h := &slotHeap{free: make([]vtime, n), idx: make([]int, n)}
+	for i := range h.idx {
+		h.idx[i] = i
+	}
+	return h
+}
+
+// earliest returns the slot that frees first and when.
+func (h *slotHeap) earliest() (int, vtime) {
+	return h.idx[0], h.free[0]
+}
+
+// occupy marks the earliest slot busy until t.
+func (h *slotHeap) occupy(t vtime) {
+	h.free[0] = t
+	h.down(0)
+}
+
+func (h *slotHeap) less(i, j int) bool {
+	if h.free[i] != h.free[j] {
+		return h.free[i] < h.free[j]
+	}
+	return h.idx[i] < h.idx[j]
+}
+
+func (h *slotHeap) down(i int) {
+	n := len(h.free)
+	for {
+		l := 2*i + 1
+		if l >= n {
+			return
+		}
+		m := l
+		if r := l + 1; r < n && h.less(r, l) {
+			m = r
+		}
+		if !h.less(m, i) {
+			return
+		}
+		h.free[i], h.free[m] = h.free[m], h.free[i]
+		h.idx[i], h.idx[m] = h.idx[m], h.idx[i]
+		i = m
+	}
+}
@@ -0,0 +1,141 @@
+package sim
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden request timings in testdata")
+
+func TestSlotHeapMatchesLinearScan(t *testing.T) {
+	h := newSlotHeap(5)
+	free := make([]vtime, 5)
+	durs := []vtime{7, 3, 3, 9, 1, 4, 4, 2, 8, 5, 5, 5, 6, 0, 3}
+	now := vtime(0)
+	for step, d := range durs {
+		// the old engine's scan: earliest free time, lowest index on ties
+		want := 0
+		for j := 1; j < len(free); j++ {
+			if free[j] < free[want] {
+				want = j
+			}
+		}
+		got, at := h.earliest()
+		if got != want || at != free[want] {
+			t.Fatalf("step %d: heap picked slot %d at %d, scan picked %d at %d", step, got, at, want, free[want])
+		}
+		start := maxTime(now, at)
+		free[want] = start + d
+		h.occupy(start + d)
+		now += 2
+	}
+}
+
+func TestVirtualClockRoundTrips(t *testing.T) {
+	if got := fromSeconds(0.0015).ms(); got != 1.5 {
+		t.Fatalf("1.5ms round trip gave %v", got)
+	}
+	// a million 0.1ms steps land exactly on 100s, where float seconds drift
+	var clock vtime
+	step := fromSeconds(1e-4)
+	for i := 0; i < 1000000; i++ {
+		clock += step
+	}
+	if clock.seconds() != 100 {
+		t.Fatalf("expected exactly 100s, got %v", clock.seconds())
+	}
+}
+
+// goldenRequests is how many requests, spread over the run, each golden
+// file pins stage by stage. A totals line covers every request.
+const goldenRequests = 25
+
+// TestRequestTimingsMatchGolden pins per-request arrival, start, end, queue
+// and stage timings for one scenario of each engine feature, so a change
+// to the clock, the slot heaps or the random streams shows up as a diff.
+// Run with -update to accept intended changes.
+func TestRequestTimingsMatchGolden(t *testing.T) {
+	bench := benchScenario()
+	bench.Workload.Duration = 1
+	throttle := throttleScenario(80)
+	throttle.Workload.Duration = 20
+	cases := []struct {
+		name string
+		s    schema.Scenario
+	}{
+		{"bench", bench},
+		{"branch", branchScenario()},
+		{"cache", cacheScenario(schema.CacheConfig{Model: schema.CacheZipf, KeySpace: 1000, ZipfS: 1.2, Capacity: 100, OnHit: schema.CacheSkip})},
+		{"faults", faultScenario(&schema.FaultSchedule{Random: []schema.RandomFault{
+			{Kind: schema.FaultRestart, MTBFS: 5, DurationS: 1, ColdStartS: 2},
+		}})},
+		{"links", linkScenario(40)},
+		{"models", multiModelScenario(20, schema.EvictLRU)},
+		{"parallel", parallelScenario(2, 4)},
+		{"streams", streamScenario(2, 2)},
+		{"throttle", throttle},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := timingDump(RunWithOptions(c.s, 7, Options{TraceEvery: -1}).Results)
+			path := filepath.Join("testdata", c.name+".golden")
+			if *updateGolden {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+			for i := range wantLines {
+				if i >= len(gotLines) || gotLines[i] != wantLines[i] {
+					g := "<missing>"
+					if i < len(gotLines) {
+						g = gotLines[i]
+					}
+					t.Fatalf("%s:%d\n got %s\nwant %s", path, i+1, g, wantLines[i])
+				}
+			}
+			if len(gotLines) != len(wantLines) {
+				t.Fatalf("%s: got %d lines, want %d", path, len(gotLines), len(wantLines))
+			}
+		})
+	}
+}
+
+// timingDump writes sampled requests one per line, times in ms to the
+// nanosecond, followed by totals over all requests.
+func timingDump(results []RequestResult) string {
+	var b strings.Builder
+	step := len(results)/goldenRequests + 1
+	var latency, queue float64
+	for i, r := range results {
+		latency += r.LatencyMS
+		queue += r.QueueMS
+		if i%step != 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%d arrival=%.6f start=%.6f end=%.6f queue=%.6f", r.ID, r.ArrivalMS, r.StartMS, r.EndMS, r.QueueMS)
+		if r.Dropped {
+			b.WriteString(" dropped")
+		}
+		for _, st := range r.Stages {
+			fmt.Fprintf(&b, " %s=%.6f-%.6f", st.Name, st.Start, st.End)
+		}
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "total requests=%d latency=%.6f queue=%.6f\n", len(results), latency, queue)
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package sim
+
+import (
+	"math"
+	"sort"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/trace"
+)
+
+// counterBins is the number of counter samples aimed for over the workload
+// duration; runs that overrun it get more samples at the same spacing.
+const counterBins = 1000
+
+// counterEpsilon suppresses counter samples that barely differ from the
+// previous one on the same track.
+const counterEpsilon = 0.01
+
+// occupancy is the time-weighted amount of something active per bin. Full
+// bins go into a difference array so an interval costs O(1) however long
+// it is.
+type occupancy struct {
+	part []float64 // coverage of partially covered bins
+	diff []float64 // difference array of fully covered bins
+}
+
+// add covers [from, to), given in bins, with weight w.
+func (o *occupancy) add(from, to, w float64) {
+	if from < 0 {
+		from = 0
+	}
+	if to <= from {
+		return
+	}
+	a, b := int(from), int(to)
+	for len(o.part) < b+2 {
+		o.part = append(o.part, 0)
+		o.diff = append(o.diff, 0)
+	}
+	if a == b {
+		o.part[a] += (to - from) * w
+		return
+	}
+	o.part[a] += (float64(a+1) - from) * w
+	o.part[b] += (to - float64(b)) * w
+	o.diff[a+1] += w
+	o.diff[b] -= w
+}
+
+func (o *occupancy) bins() int {
+	return len(o.part)
+}
+
+// values returns the average occupancy of the first n bins.
+func (o *occupancy) values(n int) []float64 {
+	out := make([]float64, n)
+	var run float64
+	for i := 0; i < n && i < len(o.part); i++ {
+		run += o.diff[i]
+		out[i] = math.Max(0, run+o.part[i]) // clamp rounding below zero
+	}
+	return out
+}
+
+// counterTracks samples queue depth, requests in flight and resource
+// utilization over every request of a run, traced or not. GPU utilization
+// is the share of concurrency slots busy across all devices; other
+// resources count as busy while any span runs on them.
+type counterTracks struct {
+	width    float64 // ms per bin
+	gpuSlots float64
+	queue    occupancy
+	inFlight occupancy
+	busy     map[string]*occupancy
+}
+
+func newCounterTracks(s schema.Scenario) *counterTracks {
+	width := s.Workload.Duration * 1000 / counterBins
+	if width < 1 {
+		width = 1
+	}
+	return &counterTracks{
+		width:    width,
+		gpuSlots: float64(s.Target.Devices() * s.Target.Concurrency),
+		busy:     map[string]*occupancy{},
+	}
+}
+
+func (c *counterTracks) observe(arrival, end float64, stages []StageTiming) {
+	c.inFlight.add(arrival/c.width, end/c.width, 1)
+	for _, st := range stages {
+		from, to := st.Start/c.width, st.End/c.width
+		switch {
+		case st.Cat == "queue":
+			c.queue.add(from, to, 1)
+		case st.Slot > 0:
+			devices := len(st.Devices)
+			if devices == 0 {
+				devices = 1
+			}
+			c.resource("gpu").add(from, to, float64(devices)/c.gpuSlots)
+		default:
+			c.resource(st.Cat).add(from, to, 1)
+		}
+	}
+}
+
+func (c *counterTracks) resource(name string) *occupancy {
+	o := c.busy[name]
+	if o == nil {
+		o = &occupancy{}
+		c.busy[name] = o
+	}
+	return o
+}
+
+// emit writes the tracks as counters: queue_depth and in_flight in
+// requests, and <resource>_utilization as a ratio in [0, 1]. Every track
+// returns to zero after the last bin.
+func (c *counterTracks) emit(tr *trace.Trace) {
+	n := c.inFlight.bins()
+	names := make([]string, 0, len(c.busy))
+	for name := range c.busy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	emitTrack(tr, "queue_depth", "requests", c.queue.values(n), c.width, false)
+	emitTrack(tr, "in_flight", "requests", c.inFlight.values(n), c.width, false)
+	for _, name := range names {
+		emitTrack(tr, name+"_utilization", "ratio", c.busy[name].values(n), c.width, true)
+	}
+}
+
+func emitTrack(tr *trace.Trace, name, series string, vals []float64, width float64, ratio bool) {
+	last := math.NaN()
+	for i, v := range vals {
+		if ratio && v > 1 {
+			v = 1
+		}
+		if math.Abs(v-last) < counterEpsilon {
+			continue
+		}
+		tr.AddCounter(name, float64(i)*width, map[string]float64{series: v})
+		last = v
+	}
+	if last != 0 && !math.IsNaN(last) {
+		tr.AddCounter(name, float64(len(vals))*width, map[string]float64{series: 0})
+	}
+}
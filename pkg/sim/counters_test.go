@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func TestOccupancySplitsPartialBins(t *testing.T) {
+	var o occupancy
+	o.add(0.5, 3.25, 2)
+	o.add(1.25, 1.75, 1)
+	got := o.values(5)
+	want := []float64{1, 2.5, 2, 0.5, 0}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Fatalf("bins %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRunEmitsRequestSpansAndCounters(t *testing.T) {
+	s := baseScenario()
+	s.Workload.RPS = 200
+	s.Workload.Duration = 1
+	s.Pipeline = []schema.Stage{
+		{Name: "h2d", Kind: schema.StageBytes, Value: 1024 * 1024},
+		{Name: "compute", Kind: schema.StageTokens, Value: 2000},
+	}
+	s.Target.H2DBandwGB = 30
+	results, tr := Run(s, 1)
+	open := map[int32]float64{}
+	spans := 0
+	peak := map[string]float64{}
+	for _, ev := range tr.Events {
+		switch ev.Ph {
+		case 'b':
+			open[ev.ID] = ev.Ts
+		case 'e':
+			if start, ok := open[ev.ID]; !ok || ev.Ts < start {
+				t.Fatalf("async end %+v without an earlier begin", ev)
+			}
+			spans++
+		case 'C':
+			for _, v := range ev.ArgMap() {
+				peak[ev.Name] = math.Max(peak[ev.Name], v.(float64))
+			}
+		}
+	}
+	if spans != len(results) {
+		t.Fatalf("expected one async span per request, got %d for %d", spans, len(results))
+	}
+	// 200 rps on two slots saturates the GPU and builds a queue
+	if peak["gpu_utilization"] < 0.99 || peak["gpu_utilization"] > 1 {
+		t.Fatalf("gpu utilization peak %v", peak["gpu_utilization"])
+	}
+	if peak["queue_depth"] < 1 || peak["in_flight"] < peak["queue_depth"] || peak["h2d_utilization"] <= 0 {
+		t.Fatalf("counter peaks %v", peak)
+	}
+
+	out := RunWithOptions(s, 1, Options{TraceEvery: -1})
+	if len(out.Trace.Events) != 0 {
+		t.Fatalf("untraced runs should emit no counters, got %d events", len(out.Trace.Events))
+	}
+}
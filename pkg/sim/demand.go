@@ -0,0 +1,162 @@
+package sim
+
+import (
+	"strconv"
+
+	"simulator/pkg/schema"
+)
+
+// ResourceDemand is the mean busy time one request puts on a contended
+// resource with Servers identical servers.
+type ResourceDemand struct {
+	Name     string  `json:"name"`
+	Servers  int     `json:"servers"`
+	ServiceS float64 `json:"service_s"`
+}
+
+// Demands walks the scenario the way Run schedules it and returns the mean
+// per-request demand on each contended resource, plus the unloaded
+// end-to-end latency. Branches are weighted by the share of requests the
+// router sends down them and fixed-ratio caches by their hit ratio; other
+// cache models are treated as misses. Faults, throttling and model swaps
+// are not included.
+func Demands(s schema.Scenario) ([]ResourceDemand, float64) {
+	d := demandWalker{
+		s:     s,
+		plan:  newParallelPlan(s.Target),
+		links: newLinkPool(s.Links),
+		index: map[string]int{},
+	}
+	d.walk(s.Pipeline, 1, cacheEffect{}, 0)
+	return d.out, d.latency
+}
+
+type demandWalker struct {
+	s       schema.Scenario
+	plan    *parallelPlan
+	links   linkPool
+	index   map[string]int
+	out     []ResourceDemand
+	latency float64
+}
+
+func (d *demandWalker) add(name string, servers int, seconds float64) {
+	i, ok := d.index[name]
+	if !ok {
+		i = len(d.out)
+		d.index[name] = i
+		d.out = append(d.out, ResourceDemand{Name: name, Servers: servers})
+	}
+	d.out[i].ServiceS += seconds
+}
+
+// walk books stages reached with probability weight. effect is the last
+// cache's hit effect and hit its hit ratio.
+func (d *demandWalker) walk(stages []schema.Stage, weight float64, effect cacheEffect, hit float64) {
+	for _, st := range stages {
+		if st.Kind == schema.StageBranch {
+			for i, p := range d.branchShares(st.Branches) {
+				if p > 0 {
+					d.walk(st.Branches[i].Pipeline, weight*p, effect, hit)
+				}
+			}
+			continue
+		}
+		w := weight
+		scale := 1.0
+		if effect.applies(st) {
+			if effect.skip {
+				w *= 1 - hit
+			} else {
+				scale = 1 - hit + hit*effect.factor
+			}
+		}
+		dur := stageDurationSeconds(st, d.s.Target) * scale
+		if l := d.links.get(st); l != nil {
+			dur = l.seconds(st.Value) * scale
+			d.add("link:"+l.cfg.Name, 1, w*(dur-l.latency()))
+		} else if isGPUStage(st) && d.plan.sharded() {
+			dur = d.sharded(w, dur)
+		} else if d.s.Streams != nil && isSlotBound(st) {
+			// The stream is held across device stages; each engine is
+			// shared by the slot's streams.
+			d.add("streams", d.s.Target.Concurrency*d.s.Streams.PerSlot, w*dur)
+			d.add("engine:"+engineName(engineFor(st)), d.s.Target.Concurrency, w*dur)
+		} else if isGPUStage(st) {
+			d.add("gpu", d.s.Target.Concurrency, w*dur)
+		}
+		d.latency += w * dur
+
+		if st.Kind == schema.StageCache && st.Cache != nil && st.Cache.Model == schema.CacheFixed {
+			m := cacheModel{cfg: *st.Cache}
+			effect, hit = m.effect(), st.Cache.HitRatio
+		}
+	}
+}
+
+// sharded books a GPU stage on each pipeline group and returns its
+// unloaded latency including collectives and activation transfers.
+func (d *demandWalker) sharded(w, dur float64) float64 {
+	p := d.plan
+	perLayer := dur / float64(p.total) / float64(p.tp)
+	allReduce := allReduceSeconds(p.actBytes, p.tp, p.link)
+	var total float64
+	for g := 0; g < p.pp; g++ {
+		seg := (perLayer + allReduce) * float64(p.layers[g])
+		d.add("gpu:pp"+strconv.Itoa(g), d.s.Target.Concurrency, w*seg)
+		total += seg
+		if g < p.pp-1 {
+			total += p2pSeconds(p.actBytes, p.link)
+		}
+	}
+	return total
+}
+
+// branchShares returns the probability of taking each branch the way the
+// router chooses: a class takes the first attribute-matched branch it
+// matches, and only classes that match none draw among the probability
+// branches. Without classes every request draws.
+func (d *demandWalker) branchShares(branches []schema.Branch) []float64 {
+	shares := make([]float64, len(branches))
+	classes := d.s.Workload.Classes
+	if len(classes) == 0 {
+		classes = []schema.RequestClass{{Weight: 1}}
+	}
+	var total, unmatched float64
+	for _, c := range classes {
+		total += c.Weight
+		attrs := classAttrs(c)
+		matched := false
+		for i, b := range branches {
+			if len(b.When) > 0 && matches(b.When, attrs) {
+				shares[i] += c.Weight
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched += c.Weight
+		}
+	}
+	if total == 0 {
+		return shares
+	}
+	for i, b := range branches {
+		if len(b.When) == 0 {
+			shares[i] = b.Probability * unmatched
+		}
+		shares[i] /= total
+	}
+	return shares
+}
+
+func engineName(engine int) string {
+	switch engine {
+	case engineCopyIn:
+		return "copy_in"
+	case engineCopyOut:
+		return "copy_out"
+	default:
+		return "compute"
+	}
+}
@@ -0,0 +1,62 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func TestDemandsFindLinkBottleneck(t *testing.T) {
+	s := linkScenario(10)
+	demands, unloaded := Demands(s)
+	got := map[string]ResourceDemand{}
+	for _, d := range demands {
+		got[d.Name] = d
+	}
+	// fetch holds the s3 link for 50ms of data phase; latency is not contended
+	if d := got["link:s3"]; d.Servers != 1 || math.Abs(d.ServiceS-0.05) > 1e-9 {
+		t.Fatalf("unexpected s3 demand %+v", d)
+	}
+	if d := got["gpu"]; d.Servers != 4 || math.Abs(d.ServiceS-0.001) > 1e-9 {
+		t.Fatalf("unexpected gpu demand %+v", d)
+	}
+	// 70ms fetch + 1ms compute + 1.1ms respond
+	if math.Abs(unloaded-0.0721) > 1e-9 {
+		t.Fatalf("unexpected unloaded latency %f", unloaded)
+	}
+}
+
+func TestDemandsWeightCacheHits(t *testing.T) {
+	s := cacheScenario(schema.CacheConfig{Model: schema.CacheFixed, HitRatio: 0.3, OnHit: schema.CacheSkip})
+	demands, unloaded := Demands(s)
+	// 30% of requests skip the 20ms prefill and the 1ms post stage
+	if len(demands) != 1 || math.Abs(demands[0].ServiceS-0.014) > 1e-9 {
+		t.Fatalf("unexpected demands %+v", demands)
+	}
+	if math.Abs(unloaded-0.0152) > 1e-9 {
+		t.Fatalf("unexpected unloaded latency %f", unloaded)
+	}
+}
+
+func TestDemandsFollowBranchPrecedence(t *testing.T) {
+	s := branchScenario()
+	s.Pipeline = []schema.Stage{
+		{Name: "route", Kind: schema.StageBranch, Branches: []schema.Branch{
+			{Name: "ocr", When: map[string]string{"needs_ocr": "yes"}, Pipeline: []schema.Stage{
+				{Name: "ocr", Kind: schema.StageFixedMs, Value: 10},
+			}},
+			{Name: "text", Probability: 1, Pipeline: []schema.Stage{
+				{Name: "read", Kind: schema.StageFixedMs, Value: 100},
+			}},
+		}},
+	}
+	// scans (1/4) take the ocr branch; only text requests draw the fallback
+	if _, unloaded := Demands(s); math.Abs(unloaded-(0.25*0.01+0.75*0.1)) > 1e-9 {
+		t.Fatalf("unexpected unloaded latency %f", unloaded)
+	}
+	s.Workload.Classes = s.Workload.Classes[1:]
+	if _, unloaded := Demands(s); math.Abs(unloaded-0.01) > 1e-9 {
+		t.Fatalf("a class matching a When branch should never draw, got %f", unloaded)
+	}
+}
@@ -1,8 +1,8 @@
 package sim
 
 import (
+	"fmt"
 	"math"
-	"math/rand"
 	"sort"
 	"strings"
 
@@ -11,132 +11,494 @@ import (
 )
 
 type StageTiming struct {
-	Start float64
-	End   float64
-	Name  string
-	Cat   string
+	Start   float64
+	End     float64
+	Name    string
+	Cat     string
+	Devices []int   // GPU indices the stage ran on; empty for single-device targets
+	Slot    int     // 1 + the GPU concurrency slot the stage held; 0 off the GPU
+	Bytes   float64 // bytes moved by bytes, network and storage stages
+	Tokens  float64 // tokens processed by token stages
 }
 
 type RequestResult struct {
-	LatencyMS float64
-	QueueMS   float64
-	ArrivalMS float64
-	StartMS   float64
-	EndMS     float64
-	Stages    []StageTiming
-	ID        int
+	LatencyMS  float64
+	QueueMS    float64
+	ArrivalMS  float64
+	StartMS    float64
+	EndMS      float64
+	Stages     []StageTiming
+	ID         int
+	Cache      string   // cacheHit or cacheMiss when the pipeline has a cache stage
+	Class      string   // workload class, empty when none are declared
+	Branches   []string // "stage/branch" labels of the branches taken
+	Tokens     float64  // tokens processed by the request's token stages
+	ThrottleMS float64  // compute time added by clock throttling
+	Fault      schema.FaultKind
+	Dropped    bool // aborted by a fault before completing
 }
 
 // Run executes a deterministic simulation for the scenario.
 func Run(s schema.Scenario, seed int64) ([]RequestResult, trace.Trace) {
-	reqCount := int(math.Round(s.Workload.Duration * s.Workload.RPS))
-	if reqCount < 1 {
-		reqCount = 1
-	}
+	out := RunWithOptions(s, seed, Options{})
+	return out.Results, out.Trace
+}
 
-	interval := 1.0 / s.Workload.RPS
-	concurrency := s.Target.Concurrency
-	slotFree := make([]float64, concurrency) // seconds
-	jitter := s.Workload.JitterPct
-	if jitter == 0 {
-		jitter = 5
-	}
-	rng := rand.New(rand.NewSource(seed))
+// RunWithOptions is Run with control over retention, tracing and streaming
+// aggregation; see Options.
+func RunWithOptions(s schema.Scenario, seed int64, opts Options) Output {
+	reqCount := requestCount(s)
+	slots := newSlotHeap(s.Target.Concurrency)
+	plan := newParallelPlan(s.Target)
+	streams := newStreamPool(s)
+	rt := newRouter(s, seed)
+	links := newLinkPool(s.Links)
+	caches := newCacheModels(s.Pipeline, seed)
+	models := newModelPool(s)
+	thermal := newThermalModel(s.Target)
+	faults := newFaultTimeline(s, seed)
+	jitter := jitterPct(s)
 
-	results := make([]RequestResult, 0, reqCount)
+	var results []RequestResult
+	switch {
+	case opts.RetainEvery == 0:
+		results = make([]RequestResult, 0, reqCount)
+	case opts.RetainEvery > 0:
+		results = make([]RequestResult, 0, reqCount/opts.RetainEvery+1)
+	}
+	var agg *Aggregate
+	if opts.Aggregate {
+		agg = newAggregate(s.Target, opts.Accuracy)
+	}
 	tr := trace.New()
+	switch {
+	case opts.TraceEvery == 0:
+		tr = trace.NewSized(reqCount * spansPerRequest(s))
+	case opts.TraceEvery > 0:
+		tr = trace.NewSized((reqCount/opts.TraceEvery + 1) * spansPerRequest(s))
+	}
+	var counters *counterTracks
+	if opts.TraceEvery >= 0 {
+		counters = newCounterTracks(s)
+	}
 
-	var totalDuration float64
-	var totalComputeBusy float64
+	// per-request scratch, reused across requests; retained results get
+	// their own exact-size copy of the stages
+	var stageBuf []StageTiming
+	var seen []string
+	var flow []int // indices of the traced request's flow slices
+	var slab argSlab
 
 	for i := 0; i < reqCount; i++ {
-		arrival := jittered(interval*float64(i), jitter, rng)
+		arrival := arrivalAt(s, seed, i)
+		var spans *trace.Trace // nil when this request is not traced
+		if sampled(opts.TraceEvery, i) {
+			spans = &tr
+		}
 		current := arrival
-		var stages []StageTiming
-		var queueWait float64
-		var startFirst float64
+		stages := stageBuf[:0]
+		seen = seen[:0]
+		var queueWait vtime
+		var startFirst vtime
+		var bind *streamBinding
+		var effect cacheEffect
+		var cacheOutcome string
+		var tokens float64
+		var throttled float64
+		var fault schema.FaultKind
+		var dropped bool
+		markFault := func(kind schema.FaultKind) {
+			if fault == "" {
+				fault = kind
+			}
+		}
+		// wait records a queue span from t to until and returns until.
+		wait := func(t, until vtime, name string) vtime {
+			queueWait += until - t
+			stages = append(stages, StageTiming{Start: t.ms(), End: until.ms(), Name: name, Cat: "queue"})
+			return until
+		}
+		class, attrs := rt.class(i)
+		planned, taken := rt.plan(i, attrs)
+		lastBound := lastSlotBoundStage(planned)
 
-		for _, st := range s.Pipeline {
-			dur := stageDurationSeconds(st, s.Target)
-			dur = jittered(dur, jitter, rng)
+		for idx, ps := range planned {
+			st := ps.Stage
+			secs := stageDurationSeconds(st, s.Target)
+			link := links.get(st)
+			if link != nil {
+				secs = link.seconds(st.Value)
+			}
+			secs = jitterBy(secs, jitter, requestUniform(seed, i, stageSalt(st.Name, count(seen, st.Name))))
+			seen = append(seen, st.Name)
+			scale := 1.0
+			if effect.applies(st) {
+				if effect.skip {
+					if bind != nil && idx == lastBound {
+						bind.release(current)
+						bind = nil
+					}
+					continue
+				}
+				secs *= effect.factor
+				scale = effect.factor
+			}
+			var stTokens, stBytes float64
+			switch st.Kind {
+			case schema.StageTokens:
+				stTokens = st.Value * scale
+				tokens += stTokens
+			case schema.StageBytes, schema.StageNetwork, schema.StageStorage:
+				stBytes = st.Value * scale
+			}
 
 			usesGPU := isGPUStage(st)
+			if usesGPU && faults != nil && (plan.sharded() || streams != nil) {
+				if up, kind := faults.availableAt(current.seconds()); fromSeconds(up) > current {
+					current = wait(current, fromSeconds(up), "fault_wait")
+					markFault(kind)
+				}
+				if f := faults.slowdown(current.seconds()); f != 1 {
+					secs *= f
+					markFault(schema.FaultSlowdown)
+				}
+			}
+			if usesGPU && thermal != nil && (plan.sharded() || streams != nil) {
+				// clock sampled when the stage becomes ready; the slot
+				// path below samples at the actual start instead. Sharded
+				// compute spans are 1/tp of dur, so the loss is too.
+				scaled := thermal.scale(current.seconds(), secs, spans)
+				throttled += (scaled - secs) / float64(plan.tp)
+				secs = scaled
+			}
+			if usesGPU && plan.sharded() {
+				n := len(stages)
+				segStart, segEnd, out, segWait := plan.schedule(st, fromSeconds(secs), current, stages)
+				for j := n; j < len(out); j++ {
+					if out[j].Slot > 0 {
+						out[j].Tokens = stTokens
+					}
+				}
+				stages = out
+				queueWait += segWait
+				if faults != nil {
+					if at, kind, hit := faults.interrupt(segStart.seconds(), segEnd.seconds()); hit {
+						seg := truncateStages(stages[n:], at*1000)
+						stages = stages[:n+len(seg)]
+						segEnd = fromSeconds(at)
+						dropped = true
+						markFault(kind)
+					}
+				}
+				current = segEnd
+				if startFirst == 0 {
+					startFirst = segStart
+				}
+				if dropped {
+					break
+				}
+				continue
+			}
+			if streams != nil && isSlotBound(st) {
+				if bind == nil {
+					bind = streams.acquire(current)
+					if bind.start > current {
+						wait(current, bind.start, "queue")
+					}
+				}
+				start, end, engineWait := bind.run(engineFor(st), fromSeconds(secs))
+				if faults != nil && usesGPU {
+					if at, kind, hit := faults.interrupt(start.seconds(), end.seconds()); hit {
+						end = fromSeconds(at)
+						dropped = true
+						markFault(kind)
+					}
+				}
+				if engineWait > 0 {
+					wait(start-engineWait, start, "queue")
+				}
+				timing := StageTiming{
+					Start:  start.ms(),
+					End:    end.ms(),
+					Name:   st.Name,
+					Cat:    stageCategory(st),
+					Bytes:  stBytes,
+					Tokens: stTokens,
+				}
+				if usesGPU {
+					timing.Slot = bind.slot + 1
+				}
+				stages = append(stages, timing)
+				current = end
+				if idx == lastBound || dropped {
+					bind.release(end)
+					bind = nil
+				}
+				if startFirst == 0 {
+					startFirst = start
+				}
+				if dropped {
+					break
+				}
+				continue
+			}
+			dur := fromSeconds(secs)
 			start := current
+			slot := 0
 			if usesGPU {
-				// find earliest slot
-				slotIdx := 0
-				for j := 1; j < concurrency; j++ {
-					if slotFree[j] < slotFree[slotIdx] {
-						slotIdx = j
+				// earliest slot
+				idx, free := slots.earliest()
+				if free > start {
+					start = wait(start, free, "queue")
+				}
+				slot = idx + 1
+				if models != nil && st.Model != "" {
+					swap, ready := models.acquire(st.Model, start.seconds())
+					if swap > 0 {
+						end := start + fromSeconds(swap)
+						stages = append(stages, StageTiming{
+							Start: start.ms(),
+							End:   end.ms(),
+							Name:  "swap:" + st.Model,
+							Cat:   "swap",
+							Slot:  slot,
+						})
+						start = end
+					} else if r := fromSeconds(ready); r > start {
+						start = wait(start, r, "queue")
+					}
+				}
+				if faults != nil {
+					if up, kind := faults.availableAt(start.seconds()); fromSeconds(up) > start {
+						start = wait(start, fromSeconds(up), "fault_wait")
+						markFault(kind)
+					}
+					if f := faults.slowdown(start.seconds()); f != 1 {
+						dur = fromSeconds(dur.seconds() * f)
+						markFault(schema.FaultSlowdown)
 					}
 				}
-				if slotFree[slotIdx] > start {
-					waitStart := start
-					waitEnd := slotFree[slotIdx]
-					queueWait += (waitEnd - waitStart) * 1000.0
-					stages = append(stages, StageTiming{
-						Start: waitStart * 1000,
-						End:   waitEnd * 1000,
-						Name:  "queue",
-						Cat:   "queue",
-					})
-					start = waitEnd
-				}
-				slotFree[slotIdx] = start + dur
-				totalComputeBusy += dur
+				if thermal != nil {
+					scaled := fromSeconds(thermal.scale(start.seconds(), dur.seconds(), spans))
+					throttled += (scaled - dur).seconds()
+					dur = scaled
+				}
+				if faults != nil {
+					if at, kind, hit := faults.interrupt(start.seconds(), (start + dur).seconds()); hit {
+						dur = fromSeconds(at) - start
+						dropped = true
+						markFault(kind)
+					}
+				}
+				slots.occupy(start + dur)
+			} else if link != nil {
+				start = link.reserve(current, dur)
+				if start > current {
+					wait(current, start, "queue")
+				}
 			}
 			end := start + dur
 			stages = append(stages, StageTiming{
-				Start: start * 1000,
-				End:   end * 1000,
-				Name:  st.Name,
-				Cat:   stageCategory(st),
+				Start:  start.ms(),
+				End:    end.ms(),
+				Name:   st.Name,
+				Cat:    stageCategory(st),
+				Slot:   slot,
+				Bytes:  stBytes,
+				Tokens: stTokens,
 			})
 			current = end
+			if dropped {
+				break
+			}
+			if bind != nil {
+				bind.sync(end)
+			}
+			if m := caches[ps.key]; m != nil {
+				if m.lookup(i) {
+					effect = m.effect()
+					cacheOutcome = cacheHit
+				} else if cacheOutcome == "" {
+					cacheOutcome = cacheMiss
+				}
+			}
 			if startFirst == 0 {
-				startFirst = start * 1000
+				startFirst = start
 			}
 		}
+		stageBuf = stages
 
-		latency := (current - arrival) * 1000
-		results = append(results, RequestResult{
-			ID:        i,
-			LatencyMS: latency,
-			QueueMS:   queueWait,
-			ArrivalMS: arrival * 1000,
-			StartMS:   startFirst,
-			EndMS:     current * 1000,
-			Stages:    stages,
-		})
+		res := RequestResult{
+			ID:         i,
+			LatencyMS:  (current - arrival).ms(),
+			QueueMS:    queueWait.ms(),
+			ArrivalMS:  arrival.ms(),
+			StartMS:    startFirst.ms(),
+			EndMS:      current.ms(),
+			Stages:     stages,
+			Cache:      cacheOutcome,
+			Class:      class,
+			Branches:   taken,
+			Tokens:     tokens,
+			ThrottleMS: throttled * 1000,
+			Fault:      fault,
+			Dropped:    dropped,
+		}
+		if agg != nil {
+			agg.observe(res)
+		}
+		if sampled(opts.RetainEvery, i) {
+			res.Stages = append([]StageTiming(nil), stages...)
+			results = append(results, res)
+		}
 
-		if current > totalDuration {
-			totalDuration = current
+		if counters != nil {
+			counters.observe(res.ArrivalMS, res.EndMS, stages)
 		}
 
-		// emit trace spans
-		for _, st := range stages {
-			tr.AddComplete(st.Name, st.Cat, laneForCat(st.Cat), st.Start, st.End)
+		if spans == nil {
+			continue
 		}
-		// queue span if any
-		if queueWait > 0 && len(stages) > 0 {
-			qs := stages[0].Start - queueWait
-			tr.AddComplete("queue", "queue", laneForCat("queue"), qs, stages[0].Start)
+		// emit trace spans: the request's lifetime as an async span, GPU
+		// stages on the lane of the slot they held and the rest on their
+		// category's lane, linked by a flow in order
+		reqArgs := slab.request(i, class)
+		tr.AddAsyncBegin("request", "request", int32(i), res.ArrivalMS, reqArgs)
+		tr.AddAsyncEnd("request", "request", int32(i), res.EndMS)
+		flow = flow[:0]
+		for _, st := range stages {
+			var args interface{} = reqArgs
+			if st.Bytes > 0 || st.Tokens > 0 {
+				args = slab.span(reqArgs, st)
+			}
+			// the flow runs through the first device's slice
+			first := len(tr.Events)
+			switch {
+			case len(st.Devices) > 0:
+				for _, d := range st.Devices {
+					tr.AddCompleteArgs(st.Name, st.Cat, laneForSlot(d, st.Slot-1, s.Target.Concurrency), st.Start, st.End, args)
+				}
+			case st.Slot > 0:
+				tr.AddCompleteArgs(st.Name, st.Cat, laneForSlot(0, st.Slot-1, s.Target.Concurrency), st.Start, st.End, args)
+			default:
+				tr.AddCompleteArgs(st.Name, st.Cat, laneForCat(st.Cat), st.Start, st.End, args)
+			}
+			if st.End > st.Start {
+				flow = append(flow, first)
+			}
 		}
+		tr.LinkFlow(int32(i), flow)
+	}
+
+	if faults != nil && opts.TraceEvery >= 0 {
+		faults.emit(&tr)
+	}
+	if counters != nil {
+		counters.emit(&tr)
 	}
 
 	// add metadata events for timeline readability
+	nameLanes(&tr, s)
 	tr.Finalize()
-	return results, tr
+	if agg != nil {
+		agg.retained = len(results)
+	}
+	return Output{Results: results, Trace: tr, Aggregate: agg}
+}
+
+// spansPerRequest estimates trace events per request for sizing the trace:
+// the async begin and end, and a span per top-level stage and per queue
+// span of a typical request. Flows bind to the spans and add no events.
+func spansPerRequest(s schema.Scenario) int {
+	return len(s.Pipeline) + 3
+}
+
+// argSlab hands out span args from shared chunks, so tracing a request
+// costs no allocation per span. Spans keep pointers into the chunks.
+type argSlab struct {
+	reqs  []trace.RequestArgs
+	spans []trace.SpanArgs
+}
+
+const argSlabSize = 4096
+
+// request returns the args shared by all spans of request id.
+func (a *argSlab) request(id int, class string) *trace.RequestArgs {
+	if len(a.reqs) == cap(a.reqs) {
+		a.reqs = make([]trace.RequestArgs, 0, argSlabSize)
+	}
+	a.reqs = append(a.reqs, trace.RequestArgs{RequestID: id, Class: class})
+	return &a.reqs[len(a.reqs)-1]
+}
+
+// span returns the args of a stage that moved bytes or processed tokens.
+func (a *argSlab) span(req *trace.RequestArgs, st StageTiming) *trace.SpanArgs {
+	if len(a.spans) == cap(a.spans) {
+		a.spans = make([]trace.SpanArgs, 0, argSlabSize)
+	}
+	a.spans = append(a.spans, trace.SpanArgs{RequestArgs: req, Bytes: st.Bytes, Tokens: st.Tokens})
+	return &a.spans[len(a.spans)-1]
+}
+
+// requestCount is the number of requests the workload generates.
+func requestCount(s schema.Scenario) int {
+	n := int(math.Round(s.Workload.Duration * s.Workload.RPS))
+	if n < 1 {
+		n = 1
+	}
+	return n
 }
 
-func jittered(val float64, pct float64, rng *rand.Rand) float64 {
+// jitterPct is the workload jitter with its default applied.
+func jitterPct(s schema.Scenario) float64 {
+	if s.Workload.JitterPct == 0 {
+		return 5
+	}
+	return s.Workload.JitterPct
+}
+
+// arrivalAt is the arrival time of request i, drawn from the seed and the
+// request id alone.
+func arrivalAt(s schema.Scenario, seed int64, i int) vtime {
+	u := requestUniform(seed, i, arrivalStreamSalt)
+	return fromSeconds(arrivalSeconds(i, 1/s.Workload.RPS, jitterPct(s), u))
+}
+
+// arrivalSeconds places request i at i*interval, shifted by up to ±pct
+// percent of the interval using the uniform draw u. Scaling by the interval
+// rather than by the arrival time keeps arrivals in order and their spacing
+// stationary over the run.
+func arrivalSeconds(i int, interval, pct, u float64) float64 {
+	t := interval * (float64(i) + (u*2-1)*pct/100)
+	if t < 0 {
+		return 0
+	}
+	return t
+}
+
+func jittered(val float64, pct float64, rng uniformSource) float64 {
+	return jitterBy(val, pct, rng.Float64())
+}
+
+// jitterBy scales val by up to ±pct percent using the uniform draw u.
+func jitterBy(val, pct, u float64) float64 {
 	if pct <= 0 {
 		return val
 	}
-	scale := 1 + ((rng.Float64()*2 - 1) * pct / 100.0)
+	scale := 1 + ((u*2 - 1) * pct / 100.0)
 	return val * scale
 }
 
+func count(names []string, name string) int {
+	n := 0
+	for _, s := range names {
+		if s == name {
+			n++
+		}
+	}
+	return n
+}
+
 func stageDurationSeconds(st schema.Stage, gpu schema.GPUProfile) float64 {
 	switch st.Kind {
 	case schema.StageFixedMs:
@@ -183,11 +545,37 @@ func stageCategory(st schema.Stage) string {
 		return "mem"
 	case schema.StageTokens:
 		return "compute"
+	case schema.StageNetwork:
+		return "network"
+	case schema.StageStorage:
+		return "storage"
+	case schema.StageCache:
+		return "cache"
 	default:
 		return "cpu"
 	}
 }
 
+// nameLanes names the trace's process after the scenario, and its lanes
+// after their category, fault window or GPU slot.
+func nameLanes(tr *trace.Trace, s schema.Scenario) {
+	name := s.Name
+	if name == "" {
+		name = "simulator"
+	}
+	tr.SetProcessName(name)
+	for _, cat := range []string{"cpu", "h2d", "compute", "d2h", "queue", "comm", "storage", "network"} {
+		tr.SetThreadName(laneForCat(cat), cat)
+	}
+	tr.SetThreadName(laneForCat("mem"), "h2d / mem")
+	tr.SetThreadName(laneFaults, "faults")
+	for d := 0; d < s.Target.Devices(); d++ {
+		for k := 0; k < s.Target.Concurrency; k++ {
+			tr.SetThreadName(laneForSlot(d, k, s.Target.Concurrency), fmt.Sprintf("gpu%d slot %d", d, k))
+		}
+	}
+}
+
 func laneForCat(cat string) int {
 	switch cat {
 	case "queue":
@@ -200,8 +588,14 @@ func laneForCat(cat string) int {
 		return 4
 	case "mem":
 		return 2
-	case "compute":
+	case "compute", "swap":
 		return 3
+	case "comm":
+		return 6
+	case "storage":
+		return 7
+	case "network":
+		return 8
 	default:
 		return 1
 	}
@@ -212,44 +606,84 @@ func Summarize(results []RequestResult, durationS float64, gpu schema.GPUProfile
 	if len(results) == 0 {
 		return schema.Summary{}
 	}
-	latencies := make([]float64, len(results))
+	latencies := make([]float64, 0, len(results))
+	var dropped, faulted int
 	var totalQueue float64
-	for i, r := range results {
-		latencies[i] = r.LatencyMS
+	var swaps int
+	var swapMS float64
+	var throttleMS, computeMS float64
+	for _, r := range results {
+		if r.Fault != "" {
+			faulted++
+		}
+		if r.Dropped {
+			dropped++
+		} else {
+			latencies = append(latencies, r.LatencyMS)
+		}
 		totalQueue += r.QueueMS
+		throttleMS += r.ThrottleMS
+		for _, st := range r.Stages {
+			if st.Cat == "swap" {
+				swaps++
+				swapMS += st.End - st.Start
+			}
+			if st.Cat == "compute" {
+				computeMS += st.End - st.Start
+			}
+		}
 	}
 	sort.Float64s(latencies)
 
 	p := func(q float64) float64 {
-		idx := int(math.Ceil(q/100*float64(len(latencies)))) - 1
-		if idx < 0 {
-			idx = 0
-		}
-		if idx >= len(latencies) {
-			idx = len(latencies) - 1
-		}
-		return latencies[idx]
+		return percentile(latencies, q)
 	}
 
 	duration := durationS
 	if duration == 0 {
 		duration = 1
 	}
-	throughput := float64(len(results)) / duration
+	throughput := float64(len(latencies)) / duration
 
 	avgQueue := totalQueue / float64(len(results))
 
 	// crude GPU util: ratio of compute time to duration * concurrency not tracked per-stage here.
 	util := math.Min(100, throughput*100/float64(gpu.Concurrency))
 
-	return schema.Summary{
-		Throughput:     throughput,
-		P50LatencyMS:   p(50),
-		P90LatencyMS:   p(90),
-		P99LatencyMS:   p(99),
-		AvgQueueMS:     avgQueue,
-		GPUUtilization: util,
-		TotalRequests:  len(results),
-		DurationS:      duration,
+	sum := schema.Summary{
+		Throughput:      throughput,
+		P50LatencyMS:    p(50),
+		P90LatencyMS:    p(90),
+		P99LatencyMS:    p(99),
+		AvgQueueMS:      avgQueue,
+		GPUUtilization:  util,
+		TotalRequests:   len(results),
+		DurationS:       duration,
+		DroppedRequests: dropped,
+		FaultedRequests: faulted,
+		ModelSwaps:      swaps,
+		SwapMS:          swapMS,
+	}
+	if throttleMS > 0 && computeMS > 0 {
+		sum.ThrottleMS = throttleMS
+		sum.ThrottleLossPct = throttleMS / computeMS * 100
+	}
+	sum.Cache = summarizeCache(results)
+	applyCost(&sum, results, gpu)
+	return sum
+}
+
+// percentile returns the nearest-rank q-th percentile of sorted values.
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(q/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
 	}
+	return sorted[idx]
 }
@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+// benchScenario offers 1M requests to a 128-slot GPU at about 80% load.
+func benchScenario() schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = 10000
+	s.Workload.Duration = 100
+	s.Pipeline = []schema.Stage{
+		{Name: "pre", Kind: schema.StageFixedMs, Value: 1},
+		{Name: "h2d", Kind: schema.StageBytes, Value: 1024 * 1024},
+		{Name: "prefill", Kind: schema.StageTokens, Value: 20},
+		{Name: "decode", Kind: schema.StageTokens, Value: 80},
+		{Name: "d2h", Kind: schema.StageBytes, Value: 256 * 1024},
+	}
+	s.Target.Concurrency = 128
+	return s
+}
+
+func BenchmarkRun1M(b *testing.B) {
+	s := benchScenario()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Run(s, int64(i))
+	}
+}
+
+func BenchmarkRunAggregate1M(b *testing.B) {
+	s := benchScenario()
+	opts := Options{Aggregate: true, RetainEvery: -1, TraceEvery: -1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RunWithOptions(s, int64(i), opts)
+	}
+}
+
+func BenchmarkRunNoTrace1M(b *testing.B) {
+	s := benchScenario()
+	opts := Options{TraceEvery: -1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RunWithOptions(s, int64(i), opts)
+	}
+}
+
+// throttleBenchScenario offers 100k requests to a 64-slot GPU whose clock
+// follows its power draw over a 5s window. The load keeps the draw above
+// the sustained limit, so the clock stays throttled.
+func throttleBenchScenario() schema.Scenario {
+	s := benchScenario()
+	s.Workload.RPS = 2000
+	s.Workload.Duration = 50
+	s.Target.Concurrency = 64
+	s.Target.IdleWatts = 60
+	s.Target.ActiveWatts = 300
+	s.Target.Throttle = &schema.ThrottleConfig{
+		SustainedPowerW:     120,
+		TimeConstantS:       5,
+		ThrottledClockRatio: 0.7,
+	}
+	return s
+}
+
+func BenchmarkRunThrottled100k(b *testing.B) {
+	s := throttleBenchScenario()
+	opts := Options{TraceEvery: -1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RunWithOptions(s, int64(i), opts)
+	}
+}
+
+// featureBenchScenario offers 200k requests through class-routed and
+// probabilistic branches and a Zipf cache to a throttled 64-slot GPU, so
+// the features that keep per-request state are exercised together.
+func featureBenchScenario() schema.Scenario {
+	s := benchScenario()
+	s.Workload.RPS = 2000
+	s.Workload.Classes = []schema.RequestClass{
+		{Name: "text", Weight: 3},
+		{Name: "scan", Weight: 1, Attributes: map[string]string{"needs_ocr": "yes"}},
+	}
+	s.Pipeline = []schema.Stage{
+		{Name: "ingest", Kind: schema.StageBranch, Branches: []schema.Branch{
+			{Name: "ocr", When: map[string]string{"needs_ocr": "yes"}, Pipeline: []schema.Stage{
+				{Name: "ocr", Kind: schema.StageFixedMs, Value: 2},
+			}},
+		}},
+		{Name: "lookup", Kind: schema.StageCache, Value: 0.1, Cache: &schema.CacheConfig{
+			Model: schema.CacheZipf, KeySpace: 100000, ZipfS: 1.1, Capacity: 5000, OnHit: schema.CacheSkip,
+		}},
+		{Name: "h2d", Kind: schema.StageBytes, Value: 1024 * 1024},
+		{Name: "prefill", Kind: schema.StageTokens, Value: 20},
+		{Name: "decode", Kind: schema.StageTokens, Value: 80},
+		{Name: "safety", Kind: schema.StageBranch, Branches: []schema.Branch{
+			{Name: "fallback", Probability: 0.05, Pipeline: []schema.Stage{
+				{Name: "second-model", Kind: schema.StageTokens, Value: 100},
+			}},
+			{Name: "pass", Probability: 0.95},
+		}},
+		{Name: "d2h", Kind: schema.StageBytes, Value: 256 * 1024},
+	}
+	s.Target.Concurrency = 64
+	s.Target.IdleWatts = 60
+	s.Target.ActiveWatts = 300
+	s.Target.Throttle = &schema.ThrottleConfig{
+		SustainedPowerW:     70,
+		TimeConstantS:       1,
+		ThrottledClockRatio: 0.7,
+	}
+	return s
+}
+
+func BenchmarkRunFeatures200k(b *testing.B) {
+	s := featureBenchScenario()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Run(s, int64(i))
+	}
+}
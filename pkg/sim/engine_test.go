@@ -4,8 +4,30 @@ import (
 	"testing"
 
 	"simulator/pkg/schema"
+	"simulator/pkg/trace"
 )
 
+// baseScenario runs one compute stage on a two-slot GPU. Feature fixtures
+// and tests start from it and override only the fields they exercise.
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name:     "base",
+		Workload: schema.Workload{Name: "wl", RPS: 20, Duration: 5, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		},
+		Target: schema.GPUProfile{
+			Name:        "GPU",
+			TFLOPS:      100,
+			MemGBps:     2000,
+			TokenCost:   0.1,
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 2,
+		},
+	}
+}
+
 func TestRunDeterministic(t *testing.T) {
 	s := schema.Scenario{
 		Name: "simple",
@@ -47,3 +69,90 @@ func TestRunDeterministic(t *testing.T) {
 		t.Fatalf("latency should be >0")
 	}
 }
+
+func TestArrivalJitterStaysWithinInterval(t *testing.T) {
+	s := schema.Scenario{
+		Name:     "arrivals",
+		Workload: schema.Workload{Name: "wl", RPS: 10, Duration: 60, Batch: 1, JitterPct: 5},
+		Pipeline: []schema.Stage{{Name: "pre", Kind: schema.StageFixedMs, Value: 1}},
+		Target:   schema.GPUProfile{Name: "g", TFLOPS: 10, MemGBps: 100, TokenCost: 0.1, Concurrency: 1},
+	}
+	results, _ := Run(s, 1)
+	prev := -1.0
+	for _, r := range results {
+		// ±5% of the 100ms interval, however late in the run
+		if nominal := float64(r.ID) * 100; r.ArrivalMS < nominal-5 || r.ArrivalMS > nominal+5 {
+			t.Fatalf("request %d arrived at %fms, nominal %fms", r.ID, r.ArrivalMS, nominal)
+		}
+		if r.ArrivalMS < prev {
+			t.Fatalf("request %d arrived before its predecessor", r.ID)
+		}
+		prev = r.ArrivalMS
+	}
+}
+
+func TestTraceSpansCarryArgsSlotsAndFlows(t *testing.T) {
+	s := schema.Scenario{
+		Name: "lanes",
+		Workload: schema.Workload{
+			Name:     "wl",
+			RPS:      20,
+			Duration: 1,
+			Batch:    1,
+		},
+		Pipeline: []schema.Stage{
+			{Name: "h2d", Kind: schema.StageBytes, Value: 1024 * 1024},
+			{Name: "compute", Kind: schema.StageTokens, Value: 2000},
+		},
+		Target: schema.GPUProfile{
+			Name:        "TestGPU",
+			TFLOPS:      50,
+			MemGBps:     900,
+			TokenCost:   0.1,
+			H2DBandwGB:  30,
+			D2HBandwGB:  30,
+			Concurrency: 2,
+		},
+	}
+	_, tr := Run(s, 1)
+	gpuLanes := map[int]bool{}
+	flows := map[int32]int{} // bound slices per flow
+	ends := map[int32]int{}  // flow starts and ends per flow
+	named := map[int]string{}
+	for _, ev := range tr.Events {
+		if ev.Flow != 0 {
+			flows[ev.ID]++
+			if ev.Flow != trace.FlowIn|trace.FlowOut {
+				ends[ev.ID]++
+			}
+		}
+		switch {
+		case ev.Ph == 'X' && ev.Cat == "compute":
+			gpuLanes[ev.Tid] = true
+			tokens, _ := ev.ArgFloat("tokens")
+			if _, ok := ev.Arg("request_id"); tokens != 2000 || !ok {
+				t.Fatalf("compute span args %v", ev.Args)
+			}
+		case ev.Ph == 'X' && ev.Cat == "h2d":
+			if bytes, _ := ev.ArgFloat("bytes"); bytes != 1024*1024 {
+				t.Fatalf("h2d span args %v", ev.Args)
+			}
+		case ev.Ph == 'M' && ev.Name == "thread_name":
+			named[ev.Tid], _ = ev.ArgString("name")
+		}
+	}
+	if len(gpuLanes) != 2 || !gpuLanes[laneForSlot(0, 0, 2)] || !gpuLanes[laneForSlot(0, 1, 2)] {
+		t.Fatalf("compute spans should fill both slot lanes, got %v", gpuLanes)
+	}
+	if named[laneForSlot(0, 1, 2)] != "gpu0 slot 1" || named[laneForCat("h2d")] == "" {
+		t.Fatalf("lane names %v", named)
+	}
+	if len(flows) != 20 || flows[0] < 2 {
+		t.Fatalf("expected one flow per request, got %v", flows)
+	}
+	for id, n := range ends {
+		if n != 2 {
+			t.Fatalf("flow %d should have one start and one end, got %d", id, n)
+		}
+	}
+}
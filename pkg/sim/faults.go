@@ -0,0 +1,132 @@
+package sim
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/trace"
+)
+
+const (
+	faultSeedSalt = 0x6661756c
+	laneFaults    = 9
+)
+
+// faultWindow is one resolved fault. For outages the device is unavailable
+// over [start, end), where end includes any cold start beginning at warm.
+type faultWindow struct {
+	kind   schema.FaultKind
+	start  float64
+	warm   float64
+	end    float64
+	factor float64
+}
+
+func (w faultWindow) down() bool {
+	return w.kind != schema.FaultSlowdown
+}
+
+// faultTimeline resolves a scenario's fault schedule into windows on the
+// virtual clock. Random faults are drawn from their own seeded stream over
+// the workload duration.
+type faultTimeline struct {
+	windows []faultWindow
+}
+
+func newFaultTimeline(s schema.Scenario, seed int64) *faultTimeline {
+	if s.Faults == nil {
+		return nil
+	}
+	f := &faultTimeline{}
+	add := func(kind schema.FaultKind, start, dur, factor, cold float64) {
+		w := faultWindow{kind: kind, start: start, warm: start + dur, end: start + dur, factor: factor}
+		if kind == schema.FaultRestart {
+			w.end += cold
+		}
+		f.windows = append(f.windows, w)
+	}
+	for _, e := range s.Faults.Events {
+		add(e.Kind, e.StartS, e.DurationS, e.Factor, e.ColdStartS)
+	}
+	rng := rand.New(rand.NewSource(seed ^ faultSeedSalt))
+	for _, r := range s.Faults.Random {
+		for t := rng.ExpFloat64() * r.MTBFS; t < s.Workload.Duration; t += rng.ExpFloat64() * r.MTBFS {
+			add(r.Kind, t, r.DurationS, r.Factor, r.ColdStartS)
+		}
+	}
+	sort.Slice(f.windows, func(i, j int) bool { return f.windows[i].start < f.windows[j].start })
+	return f
+}
+
+// availableAt returns the earliest time at or after t when the device is up,
+// and the kind of the outage that delayed it, if any.
+func (f *faultTimeline) availableAt(t float64) (float64, schema.FaultKind) {
+	var kind schema.FaultKind
+	for moved := true; moved; {
+		moved = false
+		for _, w := range f.windows {
+			if w.down() && t >= w.start && t < w.end {
+				t = w.end
+				if kind == "" {
+					kind = w.kind
+				}
+				moved = true
+			}
+		}
+	}
+	return t, kind
+}
+
+// slowdown returns the duration multiplier for GPU work starting at t.
+func (f *faultTimeline) slowdown(t float64) float64 {
+	factor := 1.0
+	for _, w := range f.windows {
+		if w.kind == schema.FaultSlowdown && t >= w.start && t < w.end {
+			factor *= w.factor
+		}
+	}
+	return factor
+}
+
+// interrupt reports the first outage that begins while work runs over
+// (start, end), which aborts that work.
+func (f *faultTimeline) interrupt(start, end float64) (float64, schema.FaultKind, bool) {
+	for _, w := range f.windows {
+		if w.down() && w.start > start && w.start < end {
+			return w.start, w.kind, true
+		}
+	}
+	return 0, "", false
+}
+
+// emit draws every fault window on the faults lane.
+func (f *faultTimeline) emit(tr *trace.Trace) {
+	for _, w := range f.windows {
+		tr.AddInstant(string(w.kind), "fault", laneFaults, w.start*1000)
+		name := string(w.kind)
+		if w.kind == schema.FaultSlowdown {
+			name += " x" + strconv.FormatFloat(w.factor, 'g', 3, 64)
+		}
+		tr.AddComplete(name, "fault", laneFaults, w.start*1000, w.warm*1000)
+		if w.end > w.warm {
+			tr.AddComplete("cold_start", "fault", laneFaults, w.warm*1000, w.end*1000)
+		}
+	}
+}
+
+// truncateStages clips timings at atMs, dropping those that start after it.
+func truncateStages(stages []StageTiming, atMs float64) []StageTiming {
+	out := stages[:0]
+	for _, st := range stages {
+		if st.Start >= atMs {
+			continue
+		}
+		if st.End > atMs {
+			st.End = atMs
+		}
+		out = append(out, st)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func faultScenario(faults *schema.FaultSchedule) schema.Scenario {
+	s := baseScenario()
+	s.Workload.Duration = 20
+	s.Pipeline = []schema.Stage{
+		{Name: "pre", Kind: schema.StageFixedMs, Value: 1},
+		{Name: "compute", Kind: schema.StageTokens, Value: 200},
+		{Name: "post", Kind: schema.StageFixedMs, Value: 1},
+	}
+	s.Target.TokenCost = 0.2
+	s.Faults = faults
+	return s
+}
+
+func TestFailureAbortsInFlightAndDelaysQueue(t *testing.T) {
+	s := faultScenario(&schema.FaultSchedule{Events: []schema.Fault{
+		// mid-compute for the request arriving at 5s
+		{Kind: schema.FaultFailure, StartS: 5.02, DurationS: 3},
+	}})
+	if err := schema.ValidateScenario(s); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	results, tr := Run(s, 1)
+	sum := Summarize(results, s.Workload.Duration, s.Target)
+	if sum.DroppedRequests == 0 {
+		t.Fatalf("expected in-flight requests to be aborted")
+	}
+	for _, r := range results {
+		if r.Dropped && (r.EndMS < 5020 || r.EndMS > 5020.001) {
+			t.Fatalf("request %d should abort at the failure, ended at %f", r.ID, r.EndMS)
+		}
+		for _, st := range r.Stages {
+			if st.Cat == "compute" && st.Start >= 5020 && st.Start < 8020 {
+				t.Fatalf("request %d computed during the outage: %+v", r.ID, st)
+			}
+		}
+	}
+	if sum.FaultedRequests <= sum.DroppedRequests {
+		t.Fatalf("requests arriving during the outage should be marked as delayed: %+v", sum)
+	}
+
+	var instant, window bool
+	for _, ev := range tr.Events {
+		if ev.Cat == "fault" && ev.Ph == 'i' {
+			instant = true
+		}
+		if ev.Cat == "fault" && ev.Ph == 'X' && ev.Dur == 3e6 {
+			window = true
+		}
+	}
+	if !instant || !window {
+		t.Fatalf("expected fault instant and duration events")
+	}
+}
+
+func TestSlowdownRaisesTailLatency(t *testing.T) {
+	base := faultScenario(nil)
+	slow := faultScenario(&schema.FaultSchedule{Events: []schema.Fault{
+		{Kind: schema.FaultSlowdown, StartS: 0, DurationS: 20, Factor: 2},
+	}})
+	a, _ := Run(base, 1)
+	b, _ := Run(slow, 1)
+	sa := Summarize(a, base.Workload.Duration, base.Target)
+	sb := Summarize(b, slow.Workload.Duration, slow.Target)
+	if sb.P99LatencyMS <= sa.P99LatencyMS {
+		t.Fatalf("expected slowdown to raise p99: base=%f slow=%f", sa.P99LatencyMS, sb.P99LatencyMS)
+	}
+	if sb.DroppedRequests != 0 {
+		t.Fatalf("slowdowns should not drop requests")
+	}
+}
+
+func TestRandomRestartsWithColdStart(t *testing.T) {
+	s := faultScenario(&schema.FaultSchedule{Random: []schema.RandomFault{
+		{Kind: schema.FaultRestart, MTBFS: 5, DurationS: 1, ColdStartS: 2},
+	}})
+	a, tr := Run(s, 9)
+	b, _ := Run(s, 9)
+	if len(a) != len(b) || Summarize(a, 20, s.Target) != Summarize(b, 20, s.Target) {
+		t.Fatalf("random faults should be deterministic per seed")
+	}
+	var cold bool
+	for _, ev := range tr.Events {
+		if ev.Name == "cold_start" {
+			cold = true
+		}
+	}
+	if !cold {
+		t.Fatalf("expected cold_start spans after restarts")
+	}
+}
@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"math"
+	"sort"
+
+	"simulator/pkg/schema"
+)
+
+// histogramSubBuckets splits each power of two, so buckets are at most
+// 12.5% wide relative to their lower bound.
+const histogramSubBuckets = 8
+
+// histogramMinMS is the lower bound of the first log-linear bucket; smaller
+// values, including zero-length spans, share a [0, histogramMinMS) bucket.
+const histogramMinMS = 0.001
+
+// bucketKey identifies the log-linear bucket holding v; math.MinInt32 is
+// the bucket below histogramMinMS.
+func bucketKey(v float64) int {
+	if v < histogramMinMS {
+		return math.MinInt32
+	}
+	frac, exp := math.Frexp(v) // v = frac × 2^exp, frac in [0.5, 1)
+	sub := int((2*frac - 1) * histogramSubBuckets)
+	return exp*histogramSubBuckets + sub
+}
+
+func bucketBounds(key int) (float64, float64) {
+	if key == math.MinInt32 {
+		return 0, histogramMinMS
+	}
+	exp := floorDiv(key, histogramSubBuckets)
+	sub := key - exp*histogramSubBuckets
+	base := math.Ldexp(0.5, exp)
+	width := base / histogramSubBuckets
+	return base + float64(sub)*width, base + float64(sub+1)*width
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// newHistogram buckets values in ms; nil when there are none.
+func newHistogram(values []float64) *schema.Histogram {
+	if len(values) == 0 {
+		return nil
+	}
+	counts := map[int]int{}
+	for _, v := range values {
+		counts[bucketKey(v)]++
+	}
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	h := &schema.Histogram{Buckets: make([]schema.HistogramBucket, len(keys))}
+	for i, k := range keys {
+		lo, hi := bucketBounds(k)
+		h.Buckets[i] = schema.HistogramBucket{LowMS: lo, HighMS: hi, Count: counts[k]}
+	}
+	return h
+}
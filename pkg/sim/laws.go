@@ -0,0 +1,267 @@
+package sim
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"simulator/pkg/schema"
+)
+
+// Tolerances for the operational-law checks, in percent. Little's law holds
+// exactly over a window that contains every request, so any deviation is a
+// bookkeeping error; utilization is compared against the scenario's expected
+// demand and so moves with jitter, caches and faults.
+const (
+	littleTolerancePct      = 1
+	utilizationTolerancePct = 5
+)
+
+// lawCheck compares a measured quantity with what an operational law
+// predicts from other measurements.
+type lawCheck struct {
+	name     string
+	measured float64
+	expected float64
+	tol      float64
+}
+
+func (c lawCheck) deviationPct() float64 {
+	if c.expected == 0 {
+		if c.measured == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (c.measured - c.expected) / c.expected * 100
+}
+
+// CheckLaws verifies a run against the operational laws over the window from
+// time zero to the last completion. Each law compares quantities the engine
+// records separately, so a bookkeeping bug shows up as a deviation:
+//   - Little's law for the system, L = X·W, with L the time-averaged number
+//     of requests that have a stage running or waiting, from the stage
+//     timelines, and W the mean time from the generated arrival to the
+//     recorded end;
+//   - Little's law for the queue, Lq = X·Wq, with Lq from the queue spans
+//     and Wq the part of each request's arrival-to-end time not covered by
+//     its other stages;
+//   - the utilization law, U = X·D, with U the measured GPU busy fraction and
+//     D the expected per-request GPU demand;
+//   - slot occupancy: no two GPU stages hold the same slot of a device at
+//     once, and no stage uses a slot the device does not have;
+//   - flow balance: every request the workload generates, replayed from the
+//     seed, appears exactly once with its generated arrival time, as a
+//     completion or a drop.
+//
+// It returns run metadata with the measured and expected values, deviations,
+// and a comma-separated "law_warnings" entry naming the checks that failed.
+func CheckLaws(s schema.Scenario, seed int64, results []RequestResult) map[string]string {
+	meta := map[string]string{}
+	window := makespanSeconds(results)
+	if len(results) == 0 || window == 0 {
+		return meta
+	}
+	arrivals := requestCount(s)
+	n := float64(len(results))
+	var sojourn, waiting float64 // seconds
+	var present, inQueue []interval
+	var all, work []interval // per-request scratch
+	seen := make([]bool, arrivals)
+	completions, mismatched := 0, 0
+	for _, r := range results {
+		if r.ID < 0 || r.ID >= arrivals || seen[r.ID] {
+			mismatched++
+			continue
+		}
+		seen[r.ID] = true
+		arrival := arrivalAt(s, seed, r.ID).ms()
+		if math.Abs(r.ArrivalMS-arrival) > arrivalToleranceMS {
+			mismatched++
+		}
+		if !r.Dropped {
+			completions++
+		}
+		sojourn += (r.EndMS - arrival) / 1000
+
+		all, work = all[:0], work[:0]
+		for _, st := range r.Stages {
+			all = append(all, interval{st.Start, st.End})
+			if st.Cat == "queue" {
+				inQueue = append(inQueue, interval{st.Start, st.End})
+			} else {
+				work = append(work, interval{st.Start, st.End})
+			}
+		}
+		present = append(present, union(all)...)
+		var served float64
+		for _, iv := range union(work) {
+			served += iv.end - iv.start
+		}
+		waiting += (r.EndMS - arrival - served) / 1000
+	}
+	x := n / window
+
+	checks := []lawCheck{
+		{"little_system", timeAverage(present, window), x * sojourn / n, littleTolerancePct},
+		{"little_queue", timeAverage(inQueue, window), x * waiting / n, littleTolerancePct},
+	}
+	if expected, ok := expectedGPUUtilization(s, float64(completions)/window); ok {
+		devices := float64(s.Target.Devices())
+		measured := busyDeviceSeconds(results, s.Target) / (window * devices)
+		checks = append(checks, lawCheck{"utilization", measured, expected, utilizationTolerancePct})
+	}
+
+	var warnings []string
+	for _, c := range checks {
+		dev := c.deviationPct()
+		meta["law_"+c.name+"_measured"] = formatLaw(c.measured)
+		meta["law_"+c.name+"_expected"] = formatLaw(c.expected)
+		meta["law_"+c.name+"_deviation_pct"] = formatLaw(dev)
+		if math.Abs(dev) > c.tol {
+			warnings = append(warnings, c.name)
+		}
+	}
+
+	conflicts := slotConflicts(results, s.Target.Concurrency)
+	meta["law_slot_conflicts"] = strconv.Itoa(conflicts)
+	if conflicts > 0 {
+		warnings = append(warnings, "slot_occupancy")
+	}
+
+	dropped := len(results) - mismatched - completions
+	meta["law_flow_arrivals"] = strconv.Itoa(arrivals)
+	meta["law_flow_completions"] = strconv.Itoa(completions)
+	meta["law_flow_dropped"] = strconv.Itoa(dropped)
+	meta["law_flow_mismatched"] = strconv.Itoa(mismatched)
+	if mismatched > 0 || completions+dropped != arrivals {
+		warnings = append(warnings, "flow_balance")
+	}
+	if len(warnings) > 0 {
+		meta["law_warnings"] = strings.Join(warnings, ",")
+	}
+	return meta
+}
+
+// arrivalToleranceMS absorbs the nanosecond rounding of the engine clock
+// when matching recorded arrivals against the generator.
+const arrivalToleranceMS = 1e-6
+
+// interval is a [start, end) span in milliseconds.
+type interval struct {
+	start, end float64
+}
+
+// union merges overlapping intervals in place and returns them sorted.
+func union(spans []interval) []interval {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	out := spans[:0]
+	for _, sp := range spans {
+		if sp.end <= sp.start {
+			continue
+		}
+		if k := len(out) - 1; k >= 0 && sp.start <= out[k].end {
+			out[k].end = math.Max(out[k].end, sp.end)
+			continue
+		}
+		out = append(out, sp)
+	}
+	return out
+}
+
+// timeAverage integrates the number of open intervals over the window with
+// an event sweep and returns the time-averaged count.
+func timeAverage(spans []interval, windowS float64) float64 {
+	type edge struct {
+		t     float64
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(spans))
+	for _, sp := range spans {
+		if sp.end > sp.start {
+			edges = append(edges, edge{sp.start, 1}, edge{sp.end, -1})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].t < edges[j].t })
+	var area, last float64
+	open := 0
+	for _, e := range edges {
+		area += float64(open) * (e.t - last)
+		open += e.delta
+		last = e.t
+	}
+	return area / 1000 / windowS
+}
+
+// slotConflicts counts GPU stages that start on a device slot before the
+// previous holder of that slot released it, or on a slot beyond the
+// device's concurrency.
+func slotConflicts(results []RequestResult, concurrency int) int {
+	type slotKey struct{ device, slot int }
+	held := map[slotKey][]interval{}
+	conflicts := 0
+	for _, r := range results {
+		for _, st := range r.Stages {
+			if st.Slot == 0 {
+				continue
+			}
+			if st.Slot > concurrency {
+				conflicts++
+				continue
+			}
+			devices := st.Devices
+			if len(devices) == 0 {
+				devices = []int{0}
+			}
+			for _, d := range devices {
+				k := slotKey{d, st.Slot}
+				held[k] = append(held[k], interval{st.Start, st.End})
+			}
+		}
+	}
+	for _, spans := range held {
+		sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+		for i := 1; i < len(spans); i++ {
+			if spans[i].start < spans[i-1].end-arrivalToleranceMS {
+				conflicts++
+			}
+		}
+	}
+	return conflicts
+}
+
+// expectedGPUUtilization applies the utilization law to the GPU demand the
+// scenario implies, as a busy fraction of all devices.
+func expectedGPUUtilization(s schema.Scenario, throughput float64) (float64, bool) {
+	if s.Streams != nil {
+		// chunked stage spans include gaps waiting on the previous stage,
+		// so they overstate engine busy time
+		return 0, false
+	}
+	demands, _ := Demands(s)
+	var deviceSeconds float64
+	found := false
+	tp := float64(tensorParallel(s.Target))
+	for _, d := range demands {
+		switch {
+		case d.Name == "gpu":
+			deviceSeconds += d.ServiceS
+		case strings.HasPrefix(d.Name, "gpu:pp"):
+			deviceSeconds += d.ServiceS * tp
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return 0, false
+	}
+	// demand is in slot-seconds; each device has Concurrency slots
+	perDevice := deviceSeconds / float64(s.Target.Concurrency) / float64(s.Target.Devices())
+	return throughput * perDevice, true
+}
+
+func formatLaw(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
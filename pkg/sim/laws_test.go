@@ -0,0 +1,115 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func TestCheckLawsHoldForPlainRun(t *testing.T) {
+	s := faultScenario(nil)
+	results, _ := Run(s, 1)
+	meta := CheckLaws(s, 1, results)
+	if w := meta["law_warnings"]; w != "" {
+		t.Fatalf("expected no law violations, got %q: %v", w, meta)
+	}
+	if meta["law_flow_arrivals"] != "400" || meta["law_flow_completions"] != "400" {
+		t.Fatalf("unexpected flow counts: %v", meta)
+	}
+	if meta["law_little_system_measured"] != meta["law_little_system_expected"] {
+		t.Fatalf("Little's law should hold exactly: %v", meta)
+	}
+}
+
+func TestCheckLawsFlagSlowdownUtilization(t *testing.T) {
+	s := faultScenario(&schema.FaultSchedule{Events: []schema.Fault{
+		{Kind: schema.FaultSlowdown, StartS: 0, DurationS: 20, Factor: 2},
+	}})
+	results, _ := Run(s, 1)
+	meta := CheckLaws(s, 1, results)
+	if !strings.Contains(meta["law_warnings"], "utilization") {
+		t.Fatalf("expected utilization deviation under slowdown, got %v", meta)
+	}
+	if strings.Contains(meta["law_warnings"], "little") || strings.Contains(meta["law_warnings"], "flow") {
+		t.Fatalf("bookkeeping laws should still hold: %v", meta)
+	}
+}
+
+func TestCheckLawsCatchBrokenBookkeeping(t *testing.T) {
+	s := faultScenario(nil)
+	s.Workload.RPS = 60 // 40ms of compute on two slots: requests queue
+	fresh := func() []RequestResult {
+		results, _ := Run(s, 1)
+		for i := range results {
+			results[i].Stages = append([]StageTiming(nil), results[i].Stages...)
+		}
+		return results
+	}
+	if w := CheckLaws(s, 1, fresh())["law_warnings"]; strings.Contains(w, "little") || strings.Contains(w, "slot") || strings.Contains(w, "flow") {
+		t.Fatalf("unbroken run should hold: %q", w)
+	}
+
+	expect := func(name string, results []RequestResult, law string) {
+		t.Helper()
+		if w := CheckLaws(s, 1, results)["law_warnings"]; !strings.Contains(w, law) {
+			t.Fatalf("%s: expected a %s warning, got %q", name, law, w)
+		}
+	}
+
+	// a compute stage that waited for its slot, pulled back onto the
+	// previous holder
+	results := fresh()
+	var moved bool
+	for i := 0; i < len(results) && !moved; i++ {
+		stages := results[i].Stages
+		for j := 1; j < len(stages); j++ {
+			if stages[j].Slot > 0 && stages[j-1].Cat == "queue" {
+				stages[j].Start -= 1
+				moved = true
+				break
+			}
+		}
+	}
+	if !moved {
+		t.Fatal("expected a compute stage that waited for its slot")
+	}
+	expect("overlapping slot", results, "slot_occupancy")
+
+	// a wait the engine forgot to record as a queue span
+	results = fresh()
+	for i := range results {
+		stages := results[i].Stages[:0]
+		for _, st := range results[i].Stages {
+			if st.Cat != "queue" {
+				stages = append(stages, st)
+			}
+		}
+		results[i].Stages = stages
+	}
+	expect("missing queue spans", results, "little_system")
+	expect("missing queue spans", results, "little_queue")
+
+	// a generated request that never shows up, and one that shows up twice
+	results = fresh()
+	expect("missing request", results[1:], "flow_balance")
+	expect("duplicate request", append(results, results[3]), "flow_balance")
+
+	// an arrival that does not match the generator
+	results = fresh()
+	results[5].ArrivalMS += 1
+	expect("shifted arrival", results, "flow_balance")
+}
+
+func TestCheckLawsMeasureShardedUtilizationLikeDemand(t *testing.T) {
+	s := parallelScenario(2, 4)
+	s.Workload.RPS = 20
+	s.Target.Concurrency = 4
+	s.Target.Parallel.ActivationBytes = 256 * 1024 * 1024
+	s.Target.Parallel.Interconnect = schema.Interconnect{Kind: schema.InterconnectPCIe, BandwidthGBps: 16}
+	results, _ := Run(s, 1)
+	meta := CheckLaws(s, 1, results)
+	if strings.Contains(meta["law_warnings"], "utilization") {
+		t.Fatalf("activation transfers hold no slot and should not count as busy: %v", meta)
+	}
+}
@@ -0,0 +1,51 @@
+package sim
+
+import "simulator/pkg/schema"
+
+// sharedLink serializes transfers on a named network or storage path. The
+// per-request latency is paid by each transfer but does not hold the link,
+// so only the bandwidth portion is contended.
+type sharedLink struct {
+	cfg  schema.Link
+	free vtime
+}
+
+type linkPool map[string]*sharedLink
+
+func newLinkPool(links []schema.Link) linkPool {
+	p := make(linkPool, len(links))
+	for _, l := range links {
+		p[l.Name] = &sharedLink{cfg: l}
+	}
+	return p
+}
+
+// get returns the link a stage is bound to, or nil for non-link stages.
+func (p linkPool) get(st schema.Stage) *sharedLink {
+	if st.Kind != schema.StageNetwork && st.Kind != schema.StageStorage {
+		return nil
+	}
+	return p[st.Link]
+}
+
+func (l *sharedLink) latency() float64 {
+	return l.cfg.LatencyMS / 1000.0
+}
+
+func (l *sharedLink) latencyTime() vtime {
+	return fromSeconds(l.latency())
+}
+
+// seconds is the unloaded duration of moving bytes over the link.
+func (l *sharedLink) seconds(bytes float64) float64 {
+	return l.latency() + bytes/(l.cfg.BandwidthGBps*1e9)
+}
+
+// reserve books a transfer of total duration dur ready at t and returns its
+// start. The latency phase may overlap the previous transfer's data phase;
+// data phases are serialized.
+func (l *sharedLink) reserve(t, dur vtime) vtime {
+	start := maxTime(t, l.free-l.latencyTime())
+	l.free = start + dur
+	return start
+}
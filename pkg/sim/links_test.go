@@ -0,0 +1,53 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func linkScenario(rps float64) schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = rps
+	s.Workload.Duration = 2
+	s.Links = []schema.Link{
+		{Name: "s3", Kind: schema.StageStorage, BandwidthGBps: 1, LatencyMS: 20},
+		{Name: "nic", Kind: schema.StageNetwork, BandwidthGBps: 10, LatencyMS: 1},
+	}
+	s.Pipeline = []schema.Stage{
+		{Name: "fetch", Kind: schema.StageStorage, Value: 50e6, Link: "s3"},
+		{Name: "compute", Kind: schema.StageTokens, Value: 10},
+		{Name: "respond", Kind: schema.StageNetwork, Value: 1e6, Link: "nic"},
+	}
+	s.Target.Concurrency = 4
+	return s
+}
+
+func TestSharedLinkContention(t *testing.T) {
+	light := linkScenario(2)
+	heavy := linkScenario(40) // 40 * 50MB/s exceeds the 1GB/s link
+	if err := schema.ValidateScenario(heavy); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	a, _ := Run(light, 1)
+	b, tr := Run(heavy, 1)
+	la := Summarize(a, light.Workload.Duration, light.Target)
+	lb := Summarize(b, heavy.Workload.Duration, heavy.Target)
+	if lb.AvgQueueMS <= la.AvgQueueMS {
+		t.Fatalf("expected link contention to queue requests: light=%f heavy=%f", la.AvgQueueMS, lb.AvgQueueMS)
+	}
+
+	// unloaded fetch is latency + bytes/bandwidth, jittered by 5%
+	fetch := a[0].Stages[0]
+	if d := fetch.End - fetch.Start; d < 66 || d > 74 {
+		t.Fatalf("expected ~70ms fetch, got %f", d)
+	}
+
+	lanes := map[string]int{}
+	for _, ev := range tr.Events {
+		lanes[ev.Cat] = ev.Tid
+	}
+	if lanes["storage"] != laneForCat("storage") || lanes["network"] != laneForCat("network") {
+		t.Fatalf("expected dedicated storage/network lanes, got %v", lanes)
+	}
+}
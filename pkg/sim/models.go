@@ -0,0 +1,92 @@
+package sim
+
+import (
+	"math"
+
+	"simulator/pkg/schema"
+)
+
+// residentModel tracks one model's weights on the target.
+type residentModel struct {
+	spec     schema.ModelSpec
+	load     float64 // seconds
+	resident bool
+	readyAt  float64 // when the last load finished
+	lastUsed float64
+}
+
+// modelPool keeps co-located models within the target's weight memory. Swaps
+// are decided greedily in request order: a model evicted here is assumed not
+// to be needed by requests that are still running on it.
+type modelPool struct {
+	capacity float64
+	used     float64
+	policy   schema.EvictionPolicy
+	models   map[string]*residentModel
+	order    []*residentModel // declaration order keeps eviction ties deterministic
+}
+
+func newModelPool(s schema.Scenario) *modelPool {
+	if s.Models == nil {
+		return nil
+	}
+	p := &modelPool{
+		capacity: s.Models.MemoryGB,
+		policy:   s.Models.Eviction,
+		models:   map[string]*residentModel{},
+	}
+	for _, spec := range s.Models.Models {
+		m := &residentModel{spec: spec, load: spec.LoadMS / 1000.0}
+		if spec.LoadMS == 0 {
+			m.load = spec.MemoryGB / s.Target.H2DBandwGB
+		}
+		if spec.Pinned || p.policy == schema.EvictPinned {
+			m.resident = true
+			p.used += spec.MemoryGB
+		}
+		p.models[spec.Name] = m
+		p.order = append(p.order, m)
+	}
+	return p
+}
+
+// acquire makes a model usable for a stage that could start at t. swap is
+// the load (plus any evictions) charged to this request, starting at t;
+// ready is when the weights are usable, which may be later than t when
+// another request's load is still in flight.
+func (p *modelPool) acquire(name string, t float64) (swap, ready float64) {
+	m := p.models[name]
+	if m.resident {
+		m.lastUsed = t
+		return 0, math.Max(t, m.readyAt)
+	}
+	for p.used+m.spec.MemoryGB > p.capacity {
+		victim := p.victim()
+		if victim == nil {
+			break
+		}
+		victim.resident = false
+		p.used -= victim.spec.MemoryGB
+		swap += victim.spec.UnloadMS / 1000.0
+	}
+	swap += m.load
+	m.resident = true
+	m.readyAt = t + swap
+	m.lastUsed = t
+	p.used += m.spec.MemoryGB
+	return swap, m.readyAt
+}
+
+// victim returns the least recently used resident model that may be evicted.
+func (p *modelPool) victim() *residentModel {
+	var best *residentModel
+	for _, m := range p.order {
+		if !m.resident || m.spec.Pinned {
+			continue
+		}
+		if best == nil || m.lastUsed < best.lastUsed {
+			best = m
+		}
+	}
+	return best
+}
@@ -0,0 +1,75 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func multiModelScenario(memGB float64, eviction schema.EvictionPolicy) schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = 10
+	s.Workload.Classes = []schema.RequestClass{
+		{Name: "chat", Weight: 1},
+		{Name: "code", Weight: 1},
+	}
+	s.Pipeline = []schema.Stage{
+		{Name: "route", Kind: schema.StageBranch, Branches: []schema.Branch{
+			{Name: "chat", When: map[string]string{"class": "chat"}, Pipeline: []schema.Stage{
+				{Name: "compute", Kind: schema.StageTokens, Value: 50, Model: "chat-7b"},
+			}},
+			{Name: "code", When: map[string]string{"class": "code"}, Pipeline: []schema.Stage{
+				{Name: "compute", Kind: schema.StageTokens, Value: 50, Model: "code-7b"},
+			}},
+		}},
+	}
+	s.Models = &schema.ModelPool{
+		MemoryGB: memGB,
+		Eviction: eviction,
+		Models: []schema.ModelSpec{
+			{Name: "chat-7b", MemoryGB: 14, LoadMS: 200, UnloadMS: 10},
+			{Name: "code-7b", MemoryGB: 14, LoadMS: 200, UnloadMS: 10},
+		},
+	}
+	return s
+}
+
+func TestModelSwapsWhenMemoryIsTight(t *testing.T) {
+	tight := multiModelScenario(20, schema.EvictLRU)
+	roomy := multiModelScenario(40, schema.EvictLRU)
+	if err := schema.ValidateScenario(tight); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	a, tr := Run(tight, 2)
+	b, _ := Run(roomy, 2)
+	sa := Summarize(a, tight.Workload.Duration, tight.Target)
+	sb := Summarize(b, roomy.Workload.Duration, roomy.Target)
+	if sb.ModelSwaps != 2 {
+		t.Fatalf("expected only the two cold loads with room for both, got %d", sb.ModelSwaps)
+	}
+	if sa.ModelSwaps <= sb.ModelSwaps || sa.P99LatencyMS <= sb.P99LatencyMS {
+		t.Fatalf("expected thrashing when only one model fits: tight=%+v roomy=%+v", sa, sb)
+	}
+	var swapSpan bool
+	for _, ev := range tr.Events {
+		if ev.Cat == "swap" && ev.Tid >= laneGPUBase {
+			swapSpan = true
+		}
+	}
+	if !swapSpan {
+		t.Fatalf("expected swap spans on a GPU slot lane")
+	}
+}
+
+func TestPinnedModelsNeverSwap(t *testing.T) {
+	s := multiModelScenario(40, schema.EvictPinned)
+	results, _ := Run(s, 2)
+	if sum := Summarize(results, s.Workload.Duration, s.Target); sum.ModelSwaps != 0 {
+		t.Fatalf("pinned models should stay resident, got %d swaps", sum.ModelSwaps)
+	}
+
+	s = multiModelScenario(20, schema.EvictPinned)
+	if err := schema.ValidateScenario(s); err == nil {
+		t.Fatalf("expected error when pinned models do not fit")
+	}
+}
@@ -0,0 +1,146 @@
+package sim
+
+import "simulator/pkg/schema"
+
+// laneGPUBase offsets per-slot GPU lanes so they sort after the shared
+// lanes.
+const laneGPUBase = 10
+
+// laneForSlot is the trace lane of concurrency slot slot on GPU gpu.
+func laneForSlot(gpu, slot, concurrency int) int {
+	return laneGPUBase + gpu*concurrency + slot
+}
+
+// parallelPlan splits GPU stages across tensor- and pipeline-parallel groups.
+// Each pipeline stage owns its own set of concurrency slots, so requests can
+// be pipelined across groups while a single request walks them in order.
+type parallelPlan struct {
+	tp, pp   int
+	layers   []int // layers assigned to each pipeline stage
+	total    int
+	actBytes float64
+	link     schema.Interconnect
+	slots    []*slotHeap // per pipeline stage
+	groups   [][]int     // device indices of each pipeline stage
+}
+
+func newParallelPlan(g schema.GPUProfile) *parallelPlan {
+	p := &parallelPlan{tp: 1, pp: 1}
+	if g.Parallel == nil {
+		return p
+	}
+	cfg := g.Parallel
+	p.tp = cfg.TensorParallel
+	p.pp = cfg.PipelineParallel
+	p.total = cfg.Layers
+	p.actBytes = cfg.ActivationBytes
+	p.link = cfg.Interconnect
+	p.layers = make([]int, p.pp)
+	for i := range p.layers {
+		p.layers[i] = cfg.Layers / p.pp
+		if i < cfg.Layers%p.pp {
+			p.layers[i]++
+		}
+	}
+	p.slots = make([]*slotHeap, p.pp)
+	p.groups = make([][]int, p.pp)
+	for i := range p.slots {
+		p.slots[i] = newSlotHeap(g.Concurrency)
+		p.groups[i] = make([]int, p.tp)
+		for j := range p.groups[i] {
+			p.groups[i][j] = i*p.tp + j
+		}
+	}
+	return p
+}
+
+func (p *parallelPlan) sharded() bool {
+	return p.tp*p.pp > 1
+}
+
+// schedule places a GPU stage of single-device duration dur onto the pipeline
+// groups, starting no earlier than ready. Layer compute and per-layer
+// all-reduces are reported as one aggregated span each per pipeline stage.
+// The timings are appended to out. It returns the first start, the final
+// end, the extended timings, and the total time spent waiting for slots.
+func (p *parallelPlan) schedule(st schema.Stage, dur, ready vtime, out []StageTiming) (vtime, vtime, []StageTiming, vtime) {
+	var wait vtime
+	first := vtime(-1)
+	current := ready
+	perLayer := dur.seconds() / float64(p.total) / float64(p.tp)
+	allReduce := allReduceSeconds(p.actBytes, p.tp, p.link)
+	xfer := fromSeconds(p2pSeconds(p.actBytes, p.link))
+
+	for g := 0; g < p.pp; g++ {
+		slot, free := p.slots[g].earliest()
+		start := current
+		if free > start {
+			out = append(out, StageTiming{
+				Start: start.ms(),
+				End:   free.ms(),
+				Name:  "queue",
+				Cat:   "queue",
+			})
+			wait += free - start
+			start = free
+		}
+		if first < 0 {
+			first = start
+		}
+
+		layers := float64(p.layers[g])
+		computeEnd := start + fromSeconds(perLayer*layers)
+		out = append(out, StageTiming{
+			Start:   start.ms(),
+			End:     computeEnd.ms(),
+			Name:    st.Name,
+			Cat:     stageCategory(st),
+			Devices: p.groups[g],
+			Slot:    slot + 1,
+		})
+		end := computeEnd
+		if p.tp > 1 && allReduce > 0 {
+			end = computeEnd + fromSeconds(allReduce*layers)
+			out = append(out, StageTiming{
+				Start: computeEnd.ms(),
+				End:   end.ms(),
+				Name:  "allreduce",
+				Cat:   "comm",
+			})
+		}
+		p.slots[g].occupy(end)
+		current = end
+
+		if g < p.pp-1 && xfer > 0 {
+			out = append(out, StageTiming{
+				Start: current.ms(),
+				End:   (current + xfer).ms(),
+				Name:  activationXfer,
+				Cat:   "comm",
+			})
+			current += xfer
+		}
+	}
+	return first, current, out, wait
+}
+
+// activationXfer names the comm span that moves activations to the next
+// pipeline group. It starts after the sending group released its slot.
+const activationXfer = "activation_xfer"
+
+// allReduceSeconds models a ring all-reduce of bytes across n GPUs.
+func allReduceSeconds(bytes float64, n int, link schema.Interconnect) float64 {
+	if n < 2 || link.BandwidthGBps <= 0 {
+		return 0
+	}
+	steps := float64(2 * (n - 1))
+	return steps/float64(n)*bytes/(link.BandwidthGBps*1e9) + steps*link.LatencyUS/1e6
+}
+
+// p2pSeconds models a point-to-point transfer between neighbouring GPUs.
+func p2pSeconds(bytes float64, link schema.Interconnect) float64 {
+	if link.BandwidthGBps <= 0 {
+		return 0
+	}
+	return bytes/(link.BandwidthGBps*1e9) + link.LatencyUS/1e6
+}
@@ -0,0 +1,96 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func parallelScenario(tp, pp int) schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = 4
+	s.Workload.Duration = 2
+	s.Pipeline = []schema.Stage{
+		{Name: "pre", Kind: schema.StageFixedMs, Value: 1},
+		{Name: "compute", Kind: schema.StageTokens, Value: 400},
+	}
+	s.Target.TokenCost = 0.5
+	s.Target.Concurrency = 1
+	s.Target.Parallel = &schema.ParallelConfig{
+		TensorParallel:   tp,
+		PipelineParallel: pp,
+		Layers:           32,
+		ActivationBytes:  4 * 1024 * 1024,
+		Interconnect: schema.Interconnect{
+			Kind:          schema.InterconnectNVLink,
+			BandwidthGBps: 300,
+			LatencyUS:     5,
+		},
+	}
+	return s
+}
+
+func TestTensorParallelShortensCompute(t *testing.T) {
+	single := parallelScenario(1, 1)
+	sharded := parallelScenario(4, 1)
+
+	a, _ := Run(single, 1)
+	b, tr := Run(sharded, 1)
+	if b[0].LatencyMS >= a[0].LatencyMS {
+		t.Fatalf("expected TP=4 to be faster: single=%f tp4=%f", a[0].LatencyMS, b[0].LatencyMS)
+	}
+
+	var allReduce bool
+	for _, st := range b[0].Stages {
+		if st.Name == "allreduce" && st.Cat == "comm" {
+			allReduce = true
+		}
+	}
+	if !allReduce {
+		t.Fatalf("expected an allreduce comm stage, got %+v", b[0].Stages)
+	}
+
+	lanes := map[int]bool{}
+	for _, ev := range tr.Events {
+		if ev.Cat == "compute" {
+			lanes[ev.Tid] = true
+		}
+	}
+	for gpu := 0; gpu < 4; gpu++ {
+		if !lanes[laneForSlot(gpu, 0, sharded.Target.Concurrency)] {
+			t.Fatalf("missing compute lane for gpu %d: %v", gpu, lanes)
+		}
+	}
+}
+
+func TestPipelineParallelAddsActivationTransfers(t *testing.T) {
+	s := parallelScenario(2, 4)
+	results, _ := Run(s, 1)
+	xfers := 0
+	groups := map[int]bool{}
+	for _, st := range results[0].Stages {
+		if st.Name == "activation_xfer" {
+			xfers++
+		}
+		if st.Cat == "compute" {
+			groups[st.Devices[0]/2] = true
+		}
+	}
+	if xfers != 3 {
+		t.Fatalf("expected 3 activation transfers for pp=4, got %d", xfers)
+	}
+	if len(groups) != 4 {
+		t.Fatalf("expected compute on 4 pipeline groups, got %v", groups)
+	}
+}
+
+func TestAllReduceSeconds(t *testing.T) {
+	link := schema.Interconnect{Kind: schema.InterconnectPCIe, BandwidthGBps: 1, LatencyUS: 0}
+	if got := allReduceSeconds(1e9, 1, link); got != 0 {
+		t.Fatalf("single GPU all-reduce should be free, got %f", got)
+	}
+	// ring all-reduce moves 2*(n-1)/n of the buffer
+	if got := allReduceSeconds(1e9, 4, link); got < 1.49 || got > 1.51 {
+		t.Fatalf("expected 1.5s for 4-way all-reduce, got %f", got)
+	}
+}
@@ -0,0 +1,101 @@
+package sim
+
+import "simulator/pkg/schema"
+
+// gpuCats are the stage categories that keep a GPU slot busy.
+var gpuCats = map[string]bool{"compute": true, "swap": true, "comm": true}
+
+// busyDeviceSeconds sums slot-busy time across devices, normalized by the
+// number of slots per device, so a fully loaded device for 1s counts as 1.
+func busyDeviceSeconds(results []RequestResult, gpu schema.GPUProfile) float64 {
+	var busy float64
+	for _, r := range results {
+		busy += requestBusySeconds(r, gpu)
+	}
+	return busy / float64(gpu.Concurrency)
+}
+
+// requestBusySeconds is one request's share of busyDeviceSeconds before the
+// per-slot normalization.
+func requestBusySeconds(r RequestResult, gpu schema.GPUProfile) float64 {
+	var busy float64
+	for _, st := range r.Stages {
+		if !gpuCats[st.Cat] || st.Cat == "comm" && st.Name == activationXfer {
+			// activation transfers hold no slot, and expected demand
+			// leaves them out too
+			continue
+		}
+		devices := len(st.Devices)
+		if devices == 0 {
+			devices = 1
+			if st.Cat == "comm" {
+				// collectives keep the whole tensor-parallel group busy
+				devices = tensorParallel(gpu)
+			}
+		}
+		busy += (st.End - st.Start) / 1000 * float64(devices)
+	}
+	return busy
+}
+
+func tensorParallel(gpu schema.GPUProfile) int {
+	if gpu.Parallel == nil {
+		return 1
+	}
+	return gpu.Parallel.TensorParallel
+}
+
+// makespanSeconds is the virtual time from zero to the last completion.
+func makespanSeconds(results []RequestResult) float64 {
+	var end float64
+	for _, r := range results {
+		if r.EndMS > end {
+			end = r.EndMS
+		}
+	}
+	return end / 1000
+}
+
+// applyCost fills the energy and cost fields of sum. Each device draws idle
+// power for the whole run plus the active increment while its slots are busy.
+func applyCost(sum *schema.Summary, results []RequestResult, gpu schema.GPUProfile) {
+	var tokens float64
+	for _, r := range results {
+		tokens += r.Tokens
+	}
+	applyCostTotals(sum, gpu, makespanSeconds(results), busyDeviceSeconds(results, gpu), len(results), tokens)
+}
+
+// applyCostTotals is applyCost over precomputed totals: the makespan in
+// seconds, normalized busy device-seconds, the request count and tokens.
+func applyCostTotals(sum *schema.Summary, gpu schema.GPUProfile, wall, busy float64, requests int, tokens float64) {
+	if gpu.IdleWatts == 0 && gpu.ActiveWatts == 0 && gpu.PricePerHour == 0 {
+		return
+	}
+	if wall == 0 {
+		return
+	}
+	devices := float64(gpu.Devices())
+	n := float64(requests)
+
+	active := gpu.ActiveWatts
+	if active < gpu.IdleWatts {
+		active = gpu.IdleWatts
+	}
+	if busy > wall*devices {
+		busy = wall * devices
+	}
+	energy := gpu.IdleWatts*wall*devices + (active-gpu.IdleWatts)*busy
+	if energy > 0 {
+		sum.EnergyJ = energy
+		sum.EnergyPerRequestJ = energy / n
+		sum.AvgPowerW = energy / wall
+	}
+
+	if gpu.PricePerHour > 0 {
+		cost := gpu.PricePerHour * devices * wall / 3600
+		sum.CostUSD = cost
+		sum.CostPer1kUSD = cost / n * 1000
+		sum.TokensPerDollar = tokens / cost
+	}
+}
@@ -0,0 +1,54 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func TestCostAccounting(t *testing.T) {
+	s := schema.Scenario{
+		Name:     "cost",
+		Workload: schema.Workload{Name: "wl", RPS: 10, Duration: 10, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "compute", Kind: schema.StageTokens, Value: 200},
+		},
+		Target: schema.GPUProfile{
+			Name:         "L4",
+			TFLOPS:       120,
+			MemGBps:      300,
+			TokenCost:    0.25,
+			H2DBandwGB:   16,
+			D2HBandwGB:   16,
+			Concurrency:  1,
+			IdleWatts:    20,
+			ActiveWatts:  70,
+			PricePerHour: 0.8,
+		},
+	}
+	results, _ := Run(s, 1)
+	sum := Summarize(results, s.Workload.Duration, s.Target)
+
+	wall := makespanSeconds(results)
+	// ~50% busy: 10 rps * 50ms per request
+	expected := 20*wall + 50*0.5*wall
+	if math.Abs(sum.EnergyJ-expected)/expected > 0.1 {
+		t.Fatalf("expected ~%fJ, got %f", expected, sum.EnergyJ)
+	}
+	if sum.AvgPowerW < 40 || sum.AvgPowerW > 50 {
+		t.Fatalf("expected ~45W average, got %f", sum.AvgPowerW)
+	}
+	if math.Abs(sum.CostPer1kUSD-sum.CostUSD/float64(len(results))*1000) > 1e-9 {
+		t.Fatalf("cost per 1k inconsistent: %+v", sum)
+	}
+	tokensPerDollar := 200 * float64(len(results)) / sum.CostUSD
+	if math.Abs(sum.TokensPerDollar-tokensPerDollar) > 1e-6*tokensPerDollar {
+		t.Fatalf("expected %f tokens/$, got %f", tokensPerDollar, sum.TokensPerDollar)
+	}
+
+	s.Target.PricePerHour, s.Target.IdleWatts, s.Target.ActiveWatts = 0, 0, 0
+	if sum := Summarize(results, s.Workload.Duration, s.Target); sum.EnergyJ != 0 || sum.CostUSD != 0 {
+		t.Fatalf("cost fields should be empty without power or price: %+v", sum)
+	}
+}
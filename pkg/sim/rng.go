@@ -0,0 +1,61 @@
+package sim
+
+// Salts for per-request streams; stage jitter salts hash the stage name.
+const (
+	arrivalStreamSalt = 0x61727276
+	classStreamSalt   = 0x636c6173
+	branchStreamSalt  = 0x6272616e
+)
+
+// uniformSource is the part of *rand.Rand that jittered needs.
+type uniformSource interface {
+	Float64() float64
+}
+
+// requestStream is a splitmix64 generator for draws that belong to a single
+// request. Each stream is derived from the run seed, the request id and a
+// salt naming what is drawn, so the same request gets the same arrival,
+// class, branch and per-stage jitter draws in two scenario variants even
+// when one has extra stages. That is what lets paired A/B runs use common
+// random numbers.
+type requestStream struct {
+	state uint64
+}
+
+func newRequestStream(seed int64, id int, salt uint64) requestStream {
+	r := requestStream{state: uint64(seed) ^ salt*0x9e3779b97f4a7c15}
+	r.state += uint64(id) * 0xbf58476d1ce4e5b9
+	r.next()
+	return r
+}
+
+// requestUniform is the first draw of a request stream, for the many places
+// that need exactly one.
+func requestUniform(seed int64, id int, salt uint64) float64 {
+	r := newRequestStream(seed, id, salt)
+	return r.Float64()
+}
+
+func (r *requestStream) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Float64 returns a uniform draw in [0, 1).
+func (r *requestStream) Float64() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
+
+// stageSalt identifies the n-th stage called name within a request: FNV-1a
+// of the name, inlined so the hot loop does not allocate.
+func stageSalt(name string, n int) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(name); i++ {
+		h ^= uint64(name[i])
+		h *= 1099511628211
+	}
+	return h + uint64(n)
+}
@@ -0,0 +1,122 @@
+package sim
+
+import "simulator/pkg/schema"
+
+// Per-slot engines a stream-bound stage can occupy.
+const (
+	engineCopyIn = iota
+	engineCompute
+	engineCopyOut
+	engineCount
+)
+
+// streamPool models each concurrency slot as a device with separate copy and
+// compute engines shared by a fixed number of streams. A request holds one
+// stream from its first device stage to its last, so with a single stream a
+// slot serializes everything while two or more let copies overlap kernels of
+// other requests.
+type streamPool struct {
+	chunks     int
+	perSlot    int
+	streams    *slotHeap            // slot*perSlot+stream, by free time
+	engineFree [][engineCount]vtime // [slot][engine]
+	binding    streamBinding        // reused; requests hold one at a time
+}
+
+func newStreamPool(s schema.Scenario) *streamPool {
+	if s.Streams == nil {
+		return nil
+	}
+	chunks := s.Streams.TransferChunks
+	if chunks < 1 {
+		chunks = 1
+	}
+	p := &streamPool{
+		chunks:     chunks,
+		perSlot:    s.Streams.PerSlot,
+		streams:    newSlotHeap(s.Target.Concurrency * s.Streams.PerSlot),
+		engineFree: make([][engineCount]vtime, s.Target.Concurrency),
+	}
+	p.binding = streamBinding{pool: p, ready: make([]vtime, chunks)}
+	return p
+}
+
+// streamBinding tracks a request's progress on the stream it was assigned.
+type streamBinding struct {
+	pool  *streamPool
+	slot  int
+	start vtime
+	ready []vtime // per chunk, when the previous stage produced it
+}
+
+// acquire binds the request to the earliest free stream across all slots,
+// lowest slot first on ties. The stream stays at the top of the heap until
+// release, since requests are scheduled one at a time.
+func (p *streamPool) acquire(ready vtime) *streamBinding {
+	b := &p.binding
+	idx, free := p.streams.earliest()
+	b.slot = idx / p.perSlot
+	b.start = maxTime(ready, free)
+	b.sync(b.start)
+	return b
+}
+
+// run executes a stage of duration dur on one of the slot's engines. The
+// stage is split into chunks that start as soon as the matching chunk of the
+// previous stage is ready, which is what lets a chunked H2D overlap compute.
+// It returns the first chunk start, the last chunk end and how long the first
+// chunk waited for the engine.
+func (b *streamBinding) run(engine int, dur vtime) (vtime, vtime, vtime) {
+	free := &b.pool.engineFree[b.slot][engine]
+	chunk := fromSeconds(dur.seconds() / float64(len(b.ready)))
+	firstReady := b.ready[0]
+	var start, prev vtime
+	for j, ready := range b.ready {
+		st := maxTime(ready, maxTime(*free, prev))
+		if j == 0 {
+			start = st
+		}
+		prev = st + chunk
+		b.ready[j] = prev
+	}
+	*free = prev
+	return start, prev, start - firstReady
+}
+
+// sync marks every chunk ready at t, used after host-side stages.
+func (b *streamBinding) sync(t vtime) {
+	for j := range b.ready {
+		b.ready[j] = t
+	}
+}
+
+// release frees the stream once the request's last device stage finishes.
+func (b *streamBinding) release(end vtime) {
+	b.pool.streams.occupy(end)
+}
+
+// isSlotBound reports whether a stage runs on a slot's engines in stream mode.
+func isSlotBound(st schema.Stage) bool {
+	return st.Kind == schema.StageBytes || isGPUStage(st)
+}
+
+func engineFor(st schema.Stage) int {
+	switch stageCategory(st) {
+	case "h2d":
+		return engineCopyIn
+	case "d2h":
+		return engineCopyOut
+	default:
+		return engineCompute
+	}
+}
+
+func lastSlotBoundStage(pipeline []plannedStage) int {
+	last := -1
+	for i, st := range pipeline {
+		if isSlotBound(st.Stage) {
+			last = i
+		}
+	}
+	return last
+}
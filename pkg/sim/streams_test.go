@@ -0,0 +1,61 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func streamScenario(perSlot, chunks int) schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = 40
+	s.Workload.Duration = 2
+	s.Pipeline = []schema.Stage{
+		{Name: "h2d", Kind: schema.StageBytes, Value: 300 * 1024 * 1024},
+		{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		{Name: "d2h", Kind: schema.StageBytes, Value: 100 * 1024 * 1024},
+	}
+	s.Target.Concurrency = 1
+	s.Streams = &schema.StreamConfig{PerSlot: perSlot, TransferChunks: chunks}
+	return s
+}
+
+func TestDoubleBufferingImprovesThroughput(t *testing.T) {
+	single := streamScenario(1, 1)
+	double := streamScenario(2, 1)
+	a, _ := Run(single, 3)
+	b, _ := Run(double, 3)
+	if b[len(b)-1].EndMS >= a[len(a)-1].EndMS {
+		t.Fatalf("two streams should drain sooner: one=%f two=%f", a[len(a)-1].EndMS, b[len(b)-1].EndMS)
+	}
+	sa := Summarize(a, single.Workload.Duration, single.Target)
+	sb := Summarize(b, double.Workload.Duration, double.Target)
+	if sb.AvgQueueMS >= sa.AvgQueueMS {
+		t.Fatalf("expected less queueing with two streams: one=%f two=%f", sa.AvgQueueMS, sb.AvgQueueMS)
+	}
+}
+
+func TestChunkedTransferOverlapsCompute(t *testing.T) {
+	whole := streamScenario(1, 1)
+	whole.Workload.RPS = 1
+	chunked := streamScenario(1, 4)
+	chunked.Workload.RPS = 1
+
+	a, _ := Run(whole, 1)
+	b, _ := Run(chunked, 1)
+	if b[0].LatencyMS >= a[0].LatencyMS {
+		t.Fatalf("chunking should shorten an unloaded request: whole=%f chunked=%f", a[0].LatencyMS, b[0].LatencyMS)
+	}
+	var h2d, compute StageTiming
+	for _, st := range b[0].Stages {
+		switch st.Name {
+		case "h2d":
+			h2d = st
+		case "compute":
+			compute = st
+		}
+	}
+	if compute.Start >= h2d.End {
+		t.Fatalf("compute should start before the whole transfer finishes: h2d=%+v compute=%+v", h2d, compute)
+	}
+}
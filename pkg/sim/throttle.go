@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"math"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/trace"
+)
+
+// clockEpsilon suppresses counter samples for negligible clock changes.
+const clockEpsilon = 0.005
+
+// thermalBuckets is the number of buckets busy time is binned into per
+// time constant, and thermalRing the number kept: four windows, since
+// starts are only roughly ordered and busy intervals end in the future.
+const (
+	thermalBuckets = 64
+	thermalRing    = 4 * thermalBuckets
+)
+
+// thermalModel derives the GPU clock from the power drawn over the last
+// time-constant window. Power scales with the share of busy slots, and the
+// clock falls linearly from 1 at the sustained limit to the throttled ratio
+// at full active power. Busy slot-seconds are kept as a running integral
+// binned into buckets of 1/thermalBuckets of the window, so a clock sample
+// costs the same however many intervals fall in the window; the two partial
+// buckets at the window's edges count pro rata.
+type thermalModel struct {
+	cfg       schema.ThrottleConfig
+	idle      float64
+	active    float64
+	slots     float64
+	width     float64              // bucket width in seconds
+	busy      [thermalRing]float64 // busy slot-seconds per bucket
+	tags      [thermalRing]int64   // bucket each ring entry holds
+	lastClock float64
+}
+
+func newThermalModel(g schema.GPUProfile) *thermalModel {
+	if g.Throttle == nil {
+		return nil
+	}
+	m := &thermalModel{
+		cfg:       *g.Throttle,
+		idle:      g.IdleWatts,
+		active:    g.ActiveWatts,
+		slots:     float64(g.Concurrency),
+		width:     g.Throttle.TimeConstantS / thermalBuckets,
+		lastClock: 1,
+	}
+	for i := range m.tags {
+		m.tags[i] = -1
+	}
+	return m
+}
+
+// clock returns the clock ratio for work starting at t.
+func (m *thermalModel) clock(t float64) float64 {
+	window := m.cfg.TimeConstantS
+	util := math.Min(1, m.busyIn(math.Max(0, t-window), t)/m.slots/window)
+	power := m.idle + (m.active-m.idle)*util
+	if power <= m.cfg.SustainedPowerW {
+		return 1
+	}
+	frac := math.Min(1, (power-m.cfg.SustainedPowerW)/(m.active-m.cfg.SustainedPowerW))
+	return 1 - (1-m.cfg.ThrottledClockRatio)*frac
+}
+
+// scale stretches a compute duration starting at t by the current clock,
+// records the busy interval, and samples the clock counter on tr (when not
+// nil) when it moves. It returns the throttled duration.
+func (m *thermalModel) scale(t, dur float64, tr *trace.Trace) float64 {
+	clk := m.clock(t)
+	if tr != nil && math.Abs(clk-m.lastClock) > clockEpsilon {
+		tr.AddCounter("gpu_clock", t*1000, map[string]float64{"ratio": clk})
+		m.lastClock = clk
+	}
+	scaled := dur / clk
+	m.record(t, t+scaled)
+	return scaled
+}
+
+// record adds the busy interval [start, end) to the buckets it spans.
+func (m *thermalModel) record(start, end float64) {
+	first, last := m.bucket(start), m.bucket(end)
+	if last-first >= thermalRing {
+		first = last - thermalRing + 1 // older buckets would be overwritten
+	}
+	for b := first; b <= last; b++ {
+		lo := math.Max(start, float64(b)*m.width)
+		hi := math.Min(end, float64(b+1)*m.width)
+		if hi <= lo {
+			continue
+		}
+		i := b % thermalRing
+		if m.tags[i] != b {
+			if m.tags[i] > b {
+				continue // fell out of the ring
+			}
+			m.tags[i], m.busy[i] = b, 0
+		}
+		m.busy[i] += hi - lo
+	}
+}
+
+// busyIn returns the busy slot-seconds in [from, to].
+func (m *thermalModel) busyIn(from, to float64) float64 {
+	first, last := m.bucket(from), m.bucket(to)
+	if first == last {
+		return m.at(first) * (to - from) / m.width
+	}
+	sum := m.at(first) * (float64(first+1)*m.width - from) / m.width
+	for b := first + 1; b < last; b++ {
+		sum += m.at(b)
+	}
+	return sum + m.at(last)*(to-float64(last)*m.width)/m.width
+}
+
+func (m *thermalModel) bucket(t float64) int64 {
+	return int64(t / m.width)
+}
+
+// at returns the busy slot-seconds of bucket b, zero once it left the ring.
+func (m *thermalModel) at(b int64) float64 {
+	if i := b % thermalRing; m.tags[i] == b {
+		return m.busy[i]
+	}
+	return 0
+}
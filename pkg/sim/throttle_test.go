@@ -0,0 +1,57 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func throttleScenario(rps float64) schema.Scenario {
+	s := baseScenario()
+	s.Workload.RPS = rps
+	s.Workload.Duration = 60
+	s.Target.TokenCost = 0.2
+	s.Target.IdleWatts = 60
+	s.Target.ActiveWatts = 300
+	s.Target.Throttle = &schema.ThrottleConfig{
+		SustainedPowerW:     200,
+		TimeConstantS:       5,
+		ThrottledClockRatio: 0.7,
+	}
+	return s
+}
+
+func TestThrottlingUnderSustainedLoad(t *testing.T) {
+	busy := throttleScenario(80) // ~80% of slot capacity
+	idle := throttleScenario(20)
+	if err := schema.ValidateScenario(busy); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	a, tr := Run(busy, 1)
+	b, _ := Run(idle, 1)
+	sa := Summarize(a, busy.Workload.Duration, busy.Target)
+	sb := Summarize(b, idle.Workload.Duration, idle.Target)
+	if sa.ThrottleLossPct <= 0 {
+		t.Fatalf("expected throughput loss under sustained load")
+	}
+	if sb.ThrottleLossPct != 0 {
+		t.Fatalf("light load should stay under the power limit, lost %f%%", sb.ThrottleLossPct)
+	}
+	// the first requests run on a cold GPU
+	if a[0].ThrottleMS != 0 {
+		t.Fatalf("first request should not be throttled")
+	}
+
+	var samples int
+	for _, ev := range tr.Events {
+		if ev.Ph == 'C' && ev.Name == "gpu_clock" {
+			samples++
+			if r, _ := ev.ArgFloat("ratio"); r < 0.7 || r > 1 {
+				t.Fatalf("clock ratio out of range: %f", r)
+			}
+		}
+	}
+	if samples == 0 {
+		t.Fatalf("expected a gpu_clock counter track")
+	}
+}
@@ -0,0 +1,163 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/trace"
+)
+
+// RunTraining simulates optimizer steps for one data-parallel rank. All ranks
+// are assumed symmetric, so the gradient all-reduce is the only cross-rank
+// interaction. Data loading runs ahead on CPU workers while the GPU executes
+// H2D, forward and backward for each micro-batch in order.
+func RunTraining(s schema.Scenario, seed int64) (schema.TrainingSummary, trace.Trace) {
+	cfg := *s.Training
+	gpu := s.Target
+	accum := cfg.GradAccumSteps
+	if accum < 1 {
+		accum = 1
+	}
+	bwdRatio := cfg.BackwardRatio
+	if bwdRatio == 0 {
+		bwdRatio = 2
+	}
+	gradBytes := cfg.GradBytesPerParam
+	if gradBytes == 0 {
+		gradBytes = 2
+	}
+	optBytes := cfg.OptimizerBytesPerParam
+	if optBytes == 0 {
+		optBytes = 16
+	}
+	jitter := cfg.JitterPct
+	if jitter == 0 {
+		jitter = 5
+	}
+	rng := rand.New(rand.NewSource(seed))
+	tr := trace.New()
+
+	mbs := float64(cfg.MicroBatchSize)
+	fwdBase := mbs * cfg.FLOPsPerSample / (gpu.TFLOPS * 1e12)
+	h2dBase := mbs * cfg.SampleBytes / (gpu.H2DBandwGB * 1e9)
+	var loadBase float64
+	if cfg.LoaderWorkers > 0 {
+		loadBase = mbs * cfg.LoadMSPerSample / 1000.0 / float64(cfg.LoaderWorkers)
+	}
+	allReduce := allReduceSeconds(cfg.Params*gradBytes, cfg.DataParallel, cfg.Interconnect)
+	optimizer := cfg.Params * optBytes / (gpu.MemGBps * 1e9)
+	var checkpoint float64
+	if cfg.CheckpointEvery > 0 {
+		checkpoint = cfg.CheckpointBytes / (cfg.StorageGBps * 1e9)
+	}
+
+	var (
+		loaderFree, gpuFree float64
+		stepTimes           []float64
+		computeTotal        float64
+		commTotal           float64
+		exposedTotal        float64
+		stallTotal          float64
+		ckptTotal           float64
+	)
+
+	for step := 0; step < cfg.Steps; step++ {
+		stepStart := gpuFree
+		var bwdStart, bwdEnd float64
+
+		for m := 0; m < accum; m++ {
+			loadEnd := loaderFree + jittered(loadBase, jitter, rng)
+			if loadBase > 0 {
+				tr.AddComplete("dataload", "cpu", laneForCat("cpu"), loaderFree*1000, loadEnd*1000)
+			}
+			loaderFree = loadEnd
+
+			start := gpuFree
+			if loadEnd > start {
+				stallTotal += loadEnd - start
+				start = loadEnd
+			}
+			h2dEnd := start + jittered(h2dBase, jitter, rng)
+			if h2dBase > 0 {
+				tr.AddComplete("h2d", "h2d", laneForCat("h2d"), start*1000, h2dEnd*1000)
+			}
+			fwd := jittered(fwdBase, jitter, rng)
+			bwd := jittered(fwdBase*bwdRatio, jitter, rng)
+			fwdEnd := h2dEnd + fwd
+			bwdStart = fwdEnd
+			bwdEnd = fwdEnd + bwd
+			tr.AddComplete("forward", "compute", laneForCat("compute"), h2dEnd*1000, fwdEnd*1000)
+			tr.AddComplete("backward", "compute", laneForCat("compute"), bwdStart*1000, bwdEnd*1000)
+			computeTotal += fwd + bwd
+			gpuFree = bwdEnd
+		}
+
+		// Gradient sync: bucketed all-reduce starts with the last backward
+		// when overlap is enabled, otherwise after it.
+		syncEnd := bwdEnd
+		if allReduce > 0 {
+			arStart := bwdEnd
+			exposed := allReduce
+			if cfg.OverlapGradSync {
+				arStart = bwdStart
+				exposed = math.Max(0, allReduce-(bwdEnd-bwdStart))
+			}
+			syncEnd = bwdEnd + exposed
+			tr.AddComplete("allreduce", "comm", laneForCat("comm"), arStart*1000, (arStart+allReduce)*1000)
+			commTotal += allReduce
+			exposedTotal += exposed
+		}
+
+		optEnd := syncEnd + optimizer
+		tr.AddComplete("optimizer", "compute", laneForCat("compute"), syncEnd*1000, optEnd*1000)
+		computeTotal += optimizer
+		end := optEnd
+
+		if checkpoint > 0 && (step+1)%cfg.CheckpointEvery == 0 {
+			end = optEnd + checkpoint
+			tr.AddComplete("checkpoint", "storage", laneForCat("storage"), optEnd*1000, end*1000)
+			ckptTotal += checkpoint
+		}
+		gpuFree = end
+		stepTimes = append(stepTimes, end-stepStart)
+	}
+	nameLanes(&tr, s)
+	tr.Finalize()
+
+	steps := float64(cfg.Steps)
+	sum := schema.TrainingSummary{
+		Steps:         cfg.Steps,
+		ComputeMS:     computeTotal / steps * 1000,
+		CommMS:        commTotal / steps * 1000,
+		ExposedCommMS: exposedTotal / steps * 1000,
+		DataStallMS:   stallTotal / steps * 1000,
+		CheckpointMS:  ckptTotal * 1000,
+		DurationS:     gpuFree,
+	}
+	sum.MinStepMS = math.Inf(1)
+	for _, st := range stepTimes {
+		sum.StepTimeMS += st
+		sum.MinStepMS = math.Min(sum.MinStepMS, st*1000)
+		sum.MaxStepMS = math.Max(sum.MaxStepMS, st*1000)
+	}
+	meanStep := sum.StepTimeMS / steps
+	sum.StepTimeMS = meanStep * 1000
+	if commTotal > 0 {
+		sum.CommOverlapPct = (commTotal - exposedTotal) / commTotal * 100
+	}
+	samplesPerStep := mbs * float64(accum)
+	if meanStep > 0 {
+		// Model FLOPs exclude the optimizer and count forward+backward only.
+		modelFLOPs := samplesPerStep * cfg.FLOPsPerSample * (1 + bwdRatio)
+		sum.MFU = modelFLOPs / (meanStep * gpu.TFLOPS * 1e12) * 100
+		sum.SamplesPerSec = samplesPerStep * float64(cfg.DataParallel) / meanStep
+	}
+
+	ranks := float64(cfg.DataParallel)
+	active := math.Max(gpu.ActiveWatts, gpu.IdleWatts)
+	sum.EnergyJ = (gpu.IdleWatts*gpuFree + (active-gpu.IdleWatts)*computeTotal) * ranks
+	sum.CostUSD = gpu.PricePerHour * ranks * gpuFree / 3600
+	sum.CostPerStepUSD = sum.CostUSD / steps
+	return sum, tr
+}
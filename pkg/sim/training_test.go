@@ -0,0 +1,92 @@
+package sim
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func trainingScenario() schema.Scenario {
+	return schema.Scenario{
+		Name: "train",
+		Type: schema.ScenarioTraining,
+		Target: schema.GPUProfile{
+			Name:        "A100",
+			TFLOPS:      150,
+			MemGBps:     2000,
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 1,
+		},
+		Training: &schema.TrainingConfig{
+			Steps:           6,
+			MicroBatchSize:  8,
+			GradAccumSteps:  2,
+			DataParallel:    8,
+			Params:          1e9,
+			FLOPsPerSample:  2e12,
+			SampleBytes:     1 << 20,
+			LoaderWorkers:   4,
+			LoadMSPerSample: 2,
+			Interconnect: schema.Interconnect{
+				Kind:          schema.InterconnectNVLink,
+				BandwidthGBps: 200,
+				LatencyUS:     10,
+			},
+			CheckpointEvery: 3,
+			CheckpointBytes: 16e9,
+			StorageGBps:     4,
+		},
+	}
+}
+
+func TestRunTrainingSummary(t *testing.T) {
+	s := trainingScenario()
+	if err := schema.ValidateScenario(s); err != nil {
+		t.Fatalf("scenario should be valid: %v", err)
+	}
+	sum, tr := RunTraining(s, 1)
+	if sum.Steps != 6 || sum.StepTimeMS <= 0 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if sum.MFU <= 0 || sum.MFU > 100 {
+		t.Fatalf("mfu out of range: %f", sum.MFU)
+	}
+	if sum.CommMS <= 0 {
+		t.Fatalf("expected gradient all-reduce time with dp=8")
+	}
+	// two checkpoints of 16GB at 4GB/s
+	if sum.CheckpointMS < 7000 || sum.CheckpointMS > 9000 {
+		t.Fatalf("expected ~8000ms of checkpoints, got %f", sum.CheckpointMS)
+	}
+	if sum.MaxStepMS < sum.MinStepMS+3000 {
+		t.Fatalf("checkpoint steps should be slower: min=%f max=%f", sum.MinStepMS, sum.MaxStepMS)
+	}
+	names := map[string]bool{}
+	for _, ev := range tr.Events {
+		names[ev.Name] = true
+	}
+	for _, n := range []string{"dataload", "h2d", "forward", "backward", "allreduce", "optimizer", "checkpoint"} {
+		if !names[n] {
+			t.Fatalf("missing %s span in trace", n)
+		}
+	}
+}
+
+func TestOverlapGradSyncHidesComm(t *testing.T) {
+	s := trainingScenario()
+	s.Training.CheckpointEvery = 0
+	serial, _ := RunTraining(s, 1)
+
+	s.Training.OverlapGradSync = true
+	overlapped, _ := RunTraining(s, 1)
+	if overlapped.CommOverlapPct <= 0 {
+		t.Fatalf("expected some overlap, got %f", overlapped.CommOverlapPct)
+	}
+	if serial.CommOverlapPct != 0 {
+		t.Fatalf("expected no overlap without bucketing, got %f", serial.CommOverlapPct)
+	}
+	if overlapped.StepTimeMS >= serial.StepTimeMS {
+		t.Fatalf("overlap should shorten steps: serial=%f overlapped=%f", serial.StepTimeMS, overlapped.StepTimeMS)
+	}
+}
@@ -0,0 +1,180 @@
+// Package sketch provides a mergeable quantile sketch with relative-error
+// guarantees (DDSketch, Masson et al. 2019), for latency distributions too
+// large to keep as sorted slices.
+package sketch
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultAccuracy is the relative error used when New is given zero.
+const DefaultAccuracy = 0.01
+
+// maxBins bounds memory; beyond it the lowest bins are collapsed, which only
+// costs accuracy at the bottom of the distribution.
+const maxBins = 4096
+
+// minIndexable is the smallest value that gets its own bin; anything at or
+// below it counts as zero.
+const minIndexable = 1e-9
+
+// Sketch estimates quantiles of non-negative values. Any quantile it returns
+// is within the configured relative accuracy of an actual value at that
+// rank. Sketches with the same accuracy can be merged.
+type Sketch struct {
+	accuracy float64
+	logGamma float64
+	offset   int // bin key of bins[0]
+	bins     []uint64
+	zeros    uint64
+	count    uint64
+	sum      float64
+	min      float64
+	max      float64
+}
+
+// New returns an empty sketch with the given relative accuracy in (0, 1).
+func New(accuracy float64) *Sketch {
+	if accuracy <= 0 || accuracy >= 1 {
+		accuracy = DefaultAccuracy
+	}
+	gamma := (1 + accuracy) / (1 - accuracy)
+	return &Sketch{accuracy: accuracy, logGamma: math.Log(gamma), min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Accuracy returns the sketch's relative accuracy.
+func (s *Sketch) Accuracy() float64 { return s.accuracy }
+
+// Add records one value; negative values are recorded as zero.
+func (s *Sketch) Add(v float64) {
+	if v < 0 || math.IsNaN(v) {
+		v = 0
+	}
+	s.count++
+	s.sum += v
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+	if v <= minIndexable {
+		s.zeros++
+		return
+	}
+	s.addKey(s.key(v), 1)
+}
+
+func (s *Sketch) key(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value is the representative of bin k, equidistant in relative terms from
+// the bin's bounds.
+func (s *Sketch) value(k int) float64 {
+	return 2 * math.Exp(float64(k)*s.logGamma) / (1 + math.Exp(s.logGamma))
+}
+
+func (s *Sketch) addKey(k int, n uint64) {
+	if len(s.bins) == 0 {
+		s.offset = k
+		s.bins = append(s.bins, 0)
+	}
+	if k < s.offset {
+		grow := s.offset - k
+		bins := make([]uint64, len(s.bins)+grow)
+		copy(bins[grow:], s.bins)
+		s.bins, s.offset = bins, k
+	}
+	for k-s.offset >= len(s.bins) {
+		s.bins = append(s.bins, 0)
+	}
+	s.bins[k-s.offset] += n
+	if len(s.bins) > maxBins {
+		s.collapse()
+	}
+}
+
+// collapse folds the lowest bins into one so at most maxBins remain.
+func (s *Sketch) collapse() {
+	extra := len(s.bins) - maxBins
+	var folded uint64
+	for _, n := range s.bins[:extra+1] {
+		folded += n
+	}
+	s.bins = append([]uint64(nil), s.bins[extra:]...)
+	s.bins[0] = folded
+	s.offset += extra
+}
+
+// Merge adds o's values into s. Both must have the same accuracy.
+func (s *Sketch) Merge(o *Sketch) error {
+	if o == nil || o.count == 0 {
+		return nil
+	}
+	if o.accuracy != s.accuracy {
+		return fmt.Errorf("cannot merge sketches with accuracy %g and %g", s.accuracy, o.accuracy)
+	}
+	for i, n := range o.bins {
+		if n > 0 {
+			s.addKey(o.offset+i, n)
+		}
+	}
+	s.zeros += o.zeros
+	s.count += o.count
+	s.sum += o.sum
+	s.min = math.Min(s.min, o.min)
+	s.max = math.Max(s.max, o.max)
+	return nil
+}
+
+// Count returns the number of recorded values.
+func (s *Sketch) Count() int { return int(s.count) }
+
+// Sum returns the exact sum of recorded values.
+func (s *Sketch) Sum() float64 { return s.sum }
+
+// Mean returns the exact mean, or 0 when empty.
+func (s *Sketch) Mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Min returns the exact minimum, or 0 when empty.
+func (s *Sketch) Min() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.min
+}
+
+// Max returns the exact maximum, or 0 when empty.
+func (s *Sketch) Max() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.max
+}
+
+// Quantile returns the nearest-rank q-th percentile (q in [0, 100]), the
+// same rank convention the simulator uses for exact percentiles.
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q / 100 * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	seen := s.zeros
+	if seen >= rank {
+		return s.Min()
+	}
+	for i, n := range s.bins {
+		seen += n
+		if seen >= rank {
+			v := s.value(s.offset + i)
+			return math.Max(s.min, math.Min(s.max, v))
+		}
+	}
+	return s.max
+}
@@ -0,0 +1,91 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func exact(sorted []float64, q float64) float64 {
+	idx := int(math.Ceil(q/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func TestQuantilesWithinRelativeAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := New(0.01)
+	vals := make([]float64, 100000)
+	for i := range vals {
+		vals[i] = math.Exp(rng.NormFloat64()*1.5 + 3) // log-normal latencies in ms
+		s.Add(vals[i])
+	}
+	sort.Float64s(vals)
+	for _, q := range []float64{1, 50, 90, 99, 99.9} {
+		got, want := s.Quantile(q), exact(vals, q)
+		if math.Abs(got-want)/want > 0.01 {
+			t.Fatalf("p%v: got %v want %v", q, got, want)
+		}
+	}
+	if s.Count() != len(vals) || s.Max() != vals[len(vals)-1] || s.Quantile(100) != s.Max() {
+		t.Fatalf("count and extremes should be exact")
+	}
+}
+
+func TestMergeMatchesSingleSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	all, a, b := New(0), New(0), New(0)
+	for i := 0; i < 20000; i++ {
+		v := rng.ExpFloat64() * 10
+		all.Add(v)
+		if i%3 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []float64{50, 90, 99} {
+		if a.Quantile(q) != all.Quantile(q) {
+			t.Fatalf("p%v: merged %v, single %v", q, a.Quantile(q), all.Quantile(q))
+		}
+	}
+	if err := a.Merge(New(0.05)); err != nil {
+		t.Fatal("merging an empty sketch should be a no-op")
+	}
+	c := New(0.05)
+	c.Add(1)
+	if err := a.Merge(c); err == nil {
+		t.Fatal("expected an error merging different accuracies")
+	}
+}
+
+func TestZerosAndBoundedBins(t *testing.T) {
+	s := New(0.01)
+	s.Add(0)
+	s.Add(-1)
+	for v := 1e-6; v < 1e12; v *= 1.001 {
+		s.Add(v)
+	}
+	if len(s.bins) > maxBins {
+		t.Fatalf("bins grew to %d", len(s.bins))
+	}
+	if s.Quantile(0) != 0 {
+		t.Fatalf("expected zero minimum, got %v", s.Quantile(0))
+	}
+	if p := s.Quantile(99); math.Abs(p-exactLogSpaced(99))/exactLogSpaced(99) > 0.01 {
+		t.Fatalf("upper quantiles should survive collapsing: %v", p)
+	}
+}
+
+// exactLogSpaced is the q-th percentile of the log-spaced values in
+// TestZerosAndBoundedBins, ignoring the two zeros.
+func exactLogSpaced(q float64) float64 {
+	lo, hi := math.Log(1e-6), math.Log(1e12)
+	return math.Exp(lo + q/100*(hi-lo))
+}
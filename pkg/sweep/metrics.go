@@ -0,0 +1,43 @@
+package sweep
+
+import (
+	"reflect"
+	"strings"
+
+	"simulator/pkg/schema"
+)
+
+// summaryMetrics are the numeric JSON fields of schema.Summary.
+var summaryMetrics = func() map[string]bool {
+	out := map[string]bool{}
+	for k := range Metrics(schema.Summary{}) {
+		out[k] = true
+	}
+	return out
+}()
+
+// IsMetric reports whether name is a numeric summary field.
+func IsMetric(name string) bool {
+	return summaryMetrics[name]
+}
+
+// Metrics flattens the numeric fields of a summary by JSON name, so callers
+// can address objectives and outputs as "p99_ms" or "throughput_rps".
+func Metrics(sum schema.Summary) map[string]float64 {
+	out := map[string]float64{}
+	v := reflect.ValueOf(sum)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Float64:
+			out[name] = f.Float()
+		case reflect.Int:
+			out[name] = float64(f.Int())
+		}
+	}
+	return out
+}
@@ -0,0 +1,432 @@
+// Package sweep expands a base scenario into a set of points by overriding
+// fields addressed with JSON paths, and runs every point through the
+// simulator on a bounded worker pool.
+package sweep
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"simulator/pkg/schema"
+	"simulator/pkg/sim"
+)
+
+// Mode selects how points are drawn from the axes.
+type Mode string
+
+const (
+	ModeGrid   Mode = "grid"   // cartesian product of every axis' values
+	ModeList   Mode = "list"   // i-th value of every axis forms point i
+	ModeRandom Mode = "random" // Samples independent draws
+)
+
+// MaxPoints bounds the number of points one sweep may expand to.
+const MaxPoints = 10000
+
+// Axis overrides one scenario field. Path is a JSON path into the scenario
+// such as "workload.rps" or "pipeline[1].value". Values lists the settings
+// for grid and list sweeps; random sweeps draw from Values when given,
+// otherwise uniformly from [Min, Max] (log-uniformly when Log is set).
+type Axis struct {
+	Path   string        `json:"path"`
+	Values []interface{} `json:"values,omitempty"`
+	Min    float64       `json:"min,omitempty"`
+	Max    float64       `json:"max,omitempty"`
+	Log    bool          `json:"log,omitempty"`
+}
+
+// Spec describes a sweep.
+type Spec struct {
+	Scenario schema.Scenario `json:"scenario"`
+	Axes     []Axis          `json:"axes"`
+	Mode     Mode            `json:"mode,omitempty"`    // default grid
+	Samples  int             `json:"samples,omitempty"` // random mode
+	Seed     int64           `json:"seed,omitempty"`
+	Workers  int             `json:"workers,omitempty"`
+
+	// FixedSeed runs every point with the same seed instead of seed+i, so
+	// differences between points come from the overrides alone.
+	FixedSeed bool `json:"fixed_seed,omitempty"`
+
+	// Slots, when set, is a semaphore shared with other sweeps: each point
+	// holds a slot while it runs, so all of them together run at most
+	// cap(Slots) points at once.
+	Slots chan struct{} `json:"-"`
+}
+
+// Point is one assignment of values to axis paths.
+type Point struct {
+	Index  int                    `json:"index"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// Result is one row of the sweep table.
+type Result struct {
+	Point
+	Summary *schema.Summary `json:"summary,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Points expands the spec's axes according to its mode.
+func Points(spec Spec) ([]Point, error) {
+	if len(spec.Axes) == 0 {
+		return nil, fmt.Errorf("at least one axis is required")
+	}
+	for i, a := range spec.Axes {
+		if a.Path == "" {
+			return nil, fmt.Errorf("axes[%d].path is required", i)
+		}
+	}
+	switch spec.Mode {
+	case "", ModeGrid:
+		return gridPoints(spec.Axes)
+	case ModeList:
+		return listPoints(spec.Axes)
+	case ModeRandom:
+		return randomPoints(spec.Axes, spec.Samples, spec.Seed)
+	default:
+		return nil, fmt.Errorf("unknown sweep mode %q", spec.Mode)
+	}
+}
+
+func gridPoints(axes []Axis) ([]Point, error) {
+	total := 1
+	for i, a := range axes {
+		if len(a.Values) == 0 {
+			return nil, fmt.Errorf("axes[%d].values is required for grid sweeps", i)
+		}
+		total *= len(a.Values)
+		if total > MaxPoints {
+			return nil, fmt.Errorf("grid expands to more than %d points", MaxPoints)
+		}
+	}
+	points := make([]Point, total)
+	for n := range points {
+		values := map[string]interface{}{}
+		rem := n
+		// last axis varies fastest
+		for i := len(axes) - 1; i >= 0; i-- {
+			k := len(axes[i].Values)
+			values[axes[i].Path] = axes[i].Values[rem%k]
+			rem /= k
+		}
+		points[n] = Point{Index: n, Values: values}
+	}
+	return points, nil
+}
+
+func listPoints(axes []Axis) ([]Point, error) {
+	n := len(axes[0].Values)
+	for i, a := range axes {
+		if len(a.Values) != n || n == 0 {
+			return nil, fmt.Errorf("axes[%d].values must be non-empty and match the other axes in length", i)
+		}
+	}
+	if n > MaxPoints {
+		return nil, fmt.Errorf("list has more than %d points", MaxPoints)
+	}
+	points := make([]Point, n)
+	for j := range points {
+		values := map[string]interface{}{}
+		for _, a := range axes {
+			values[a.Path] = a.Values[j]
+		}
+		points[j] = Point{Index: j, Values: values}
+	}
+	return points, nil
+}
+
+func randomPoints(axes []Axis, samples int, seed int64) ([]Point, error) {
+	if samples < 1 || samples > MaxPoints {
+		return nil, fmt.Errorf("samples must be between 1 and %d", MaxPoints)
+	}
+	for i, a := range axes {
+		if len(a.Values) > 0 {
+			continue
+		}
+		if a.Max < a.Min {
+			return nil, fmt.Errorf("axes[%d].max must be >= min", i)
+		}
+		if a.Log && a.Min <= 0 {
+			return nil, fmt.Errorf("axes[%d].min must be > 0 for log sampling", i)
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	points := make([]Point, samples)
+	for j := range points {
+		values := map[string]interface{}{}
+		for _, a := range axes {
+			switch {
+			case len(a.Values) > 0:
+				values[a.Path] = a.Values[rng.Intn(len(a.Values))]
+			case a.Log:
+				lo, hi := math.Log(a.Min), math.Log(a.Max)
+				values[a.Path] = math.Exp(lo + rng.Float64()*(hi-lo))
+			default:
+				values[a.Path] = a.Min + rng.Float64()*(a.Max-a.Min)
+			}
+		}
+		points[j] = Point{Index: j, Values: values}
+	}
+	return points, nil
+}
+
+// Apply returns base with every path in values overridden, validated.
+func Apply(base schema.Scenario, values map[string]interface{}) (schema.Scenario, error) {
+	raw, err := json.Marshal(base)
+	if err != nil {
+		return base, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return base, err
+	}
+	// parents sort before their children, so "target" is replaced before
+	// "target.concurrency" is overridden
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		v, err := normalize(values[path])
+		if err != nil {
+			return base, fmt.Errorf("path %q: %w", path, err)
+		}
+		if doc, err = setPath(doc, path, v); err != nil {
+			return base, err
+		}
+	}
+	raw, err = json.Marshal(doc)
+	if err != nil {
+		return base, err
+	}
+	var out schema.Scenario
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return base, fmt.Errorf("applying overrides: %w", err)
+	}
+	if err := schema.ValidateScenario(out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// normalize converts v to the generic JSON form so later paths can descend
+// into it.
+func normalize(v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(raw, &out)
+	return out, err
+}
+
+// setPath sets the value at a dotted path with optional [i] indices, creating
+// intermediate objects as needed. A leading "$." is accepted.
+func setPath(doc interface{}, path string, v interface{}) (interface{}, error) {
+	tokens, err := splitPath(path)
+	if err != nil {
+		return doc, err
+	}
+	return setTokens(doc, tokens, v, path)
+}
+
+func setTokens(node interface{}, tokens []string, v interface{}, path string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	tok := tokens[0]
+	if strings.HasPrefix(tok, "[") {
+		idx, err := strconv.Atoi(tok[1 : len(tok)-1])
+		if err != nil {
+			return node, fmt.Errorf("path %q: bad index %s", path, tok)
+		}
+		arr, ok := node.([]interface{})
+		if !ok || idx < 0 || idx >= len(arr) {
+			return node, fmt.Errorf("path %q: index %d out of range", path, idx)
+		}
+		child, err := setTokens(arr[idx], tokens[1:], v, path)
+		if err != nil {
+			return node, err
+		}
+		arr[idx] = child
+		return arr, nil
+	}
+	obj, ok := node.(map[string]interface{})
+	if node == nil {
+		obj, ok = map[string]interface{}{}, true
+	}
+	if !ok {
+		return node, fmt.Errorf("path %q: %s is not an object", path, tok)
+	}
+	child, err := setTokens(obj[tok], tokens[1:], v, path)
+	if err != nil {
+		return node, err
+	}
+	obj[tok] = child
+	return obj, nil
+}
+
+// Leaves flattens s to its leaf values keyed by path in the "a.b[2].c" form
+// Lookup and Apply accept. Empty objects and arrays count as leaves.
+func Leaves(s schema.Scenario) (map[string]interface{}, error) {
+	doc, err := normalize(s)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	flatten(doc, "", out)
+	return out, nil
+}
+
+func flatten(node interface{}, prefix string, out map[string]interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = v
+		}
+		for k, child := range v {
+			p := k
+			if prefix != "" {
+				p = prefix + "." + k
+			}
+			flatten(child, p, out)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			out[prefix] = v
+		}
+		for i, child := range v {
+			flatten(child, fmt.Sprintf("%s[%d]", prefix, i), out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+// Lookup returns the value at path in s, in its generic JSON form.
+func Lookup(s schema.Scenario, path string) (interface{}, error) {
+	tokens, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := normalize(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, tok := range tokens {
+		if strings.HasPrefix(tok, "[") {
+			idx, err := strconv.Atoi(tok[1 : len(tok)-1])
+			arr, ok := node.([]interface{})
+			if err != nil || !ok || idx < 0 || idx >= len(arr) {
+				return nil, fmt.Errorf("path %q: index %s out of range", path, tok)
+			}
+			node = arr[idx]
+			continue
+		}
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %q: %s is not an object", path, tok)
+		}
+		if node, ok = obj[tok]; !ok {
+			return nil, fmt.Errorf("path %q: %s is not set", path, tok)
+		}
+	}
+	return node, nil
+}
+
+// splitPath turns "a.b[2].c" into ["a", "b", "[2]", "c"].
+func splitPath(path string) ([]string, error) {
+	p := strings.TrimPrefix(path, "$.")
+	var tokens []string
+	for _, part := range strings.Split(p, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("path %q: empty segment", path)
+		}
+		name := part
+		var idx []string
+		if i := strings.Index(part, "["); i >= 0 {
+			name = part[:i]
+			rest := part[i:]
+			for rest != "" {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("path %q: malformed index", path)
+				}
+				idx = append(idx, rest[:end+1])
+				rest = rest[end+1:]
+			}
+		}
+		if name != "" {
+			tokens = append(tokens, name)
+		}
+		tokens = append(tokens, idx...)
+	}
+	return tokens, nil
+}
+
+// Run simulates every point with at most workers in flight and calls done
+// as each finishes; done is called from a single goroutine at a time. Point
+// i is seeded with seed+i (or seed with FixedSeed) so results do not depend
+// on scheduling.
+func Run(spec Spec, points []Point, seed int64, done func(Result)) {
+	workers := spec.Workers
+	if workers < 1 {
+		workers = 4
+	}
+	jobs := make(chan Point)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				pointSeed := seed + int64(p.Index)
+				if spec.FixedSeed {
+					pointSeed = seed
+				}
+				if spec.Slots != nil {
+					spec.Slots <- struct{}{}
+				}
+				res := runPoint(spec.Scenario, p, pointSeed)
+				if spec.Slots != nil {
+					<-spec.Slots
+				}
+				mu.Lock()
+				done(res)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range points {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func runPoint(base schema.Scenario, p Point, seed int64) Result {
+	res := Result{Point: p}
+	sc, err := Apply(base, p.Values)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if sc.IsTraining() {
+		res.Error = "sweeps support serving scenarios only"
+		return res
+	}
+	out := sim.RunWithOptions(sc, seed, sim.Options{TraceEvery: -1})
+	sum := sim.Summarize(out.Results, sc.Workload.Duration, sc.Target)
+	res.Summary = &sum
+	return res
+}
@@ -0,0 +1,112 @@
+package sweep
+
+import (
+	"testing"
+
+	"simulator/pkg/schema"
+)
+
+func baseScenario() schema.Scenario {
+	return schema.Scenario{
+		Name:     "sweep",
+		Workload: schema.Workload{Name: "wl", RPS: 10, Duration: 2, Batch: 1},
+		Pipeline: []schema.Stage{
+			{Name: "pre", Kind: schema.StageFixedMs, Value: 1},
+			{Name: "compute", Kind: schema.StageTokens, Value: 100},
+		},
+		Target: schema.GPUProfile{
+			Name:        "GPU",
+			TFLOPS:      100,
+			MemGBps:     2000,
+			TokenCost:   0.2,
+			H2DBandwGB:  25,
+			D2HBandwGB:  25,
+			Concurrency: 1,
+		},
+	}
+}
+
+func TestGridPointsCartesian(t *testing.T) {
+	points, err := Points(Spec{Axes: []Axis{
+		{Path: "workload.rps", Values: []interface{}{10.0, 20.0, 40.0}},
+		{Path: "target.concurrency", Values: []interface{}{1.0, 2.0}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 6 {
+		t.Fatalf("expected 6 points, got %d", len(points))
+	}
+	if points[1].Values["workload.rps"] != 10.0 || points[1].Values["target.concurrency"] != 2.0 {
+		t.Fatalf("last axis should vary fastest: %+v", points[1])
+	}
+}
+
+func TestRandomPointsDeterministic(t *testing.T) {
+	spec := Spec{Mode: ModeRandom, Samples: 5, Seed: 3, Axes: []Axis{
+		{Path: "workload.rps", Min: 1, Max: 100, Log: true},
+	}}
+	a, err := Points(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Points(spec)
+	for i := range a {
+		v := a[i].Values["workload.rps"].(float64)
+		if v < 1 || v > 100 || v != b[i].Values["workload.rps"] {
+			t.Fatalf("unexpected sample %d: %v vs %v", i, v, b[i].Values)
+		}
+	}
+}
+
+func TestApplyOverridesNestedPaths(t *testing.T) {
+	sc, err := Apply(baseScenario(), map[string]interface{}{
+		"$.pipeline[1].value": 300.0,
+		"target.concurrency":  4.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Pipeline[1].Value != 300 || sc.Target.Concurrency != 4 {
+		t.Fatalf("overrides not applied: %+v", sc)
+	}
+	// a whole-object override lands before overrides inside it
+	replaced := baseScenario().Target
+	replaced.Name = "other"
+	sc, err = Apply(baseScenario(), map[string]interface{}{
+		"target.concurrency": 3.0,
+		"target":             replaced,
+	})
+	if err != nil || sc.Target.Name != "other" || sc.Target.Concurrency != 3 {
+		t.Fatalf("nested override after parent failed: %+v %v", sc.Target, err)
+	}
+	if _, err := Apply(baseScenario(), map[string]interface{}{"pipeline[5].value": 1.0}); err == nil {
+		t.Fatal("expected out-of-range index to fail")
+	}
+	if _, err := Apply(baseScenario(), map[string]interface{}{"target.concurrency": 0.0}); err == nil {
+		t.Fatal("expected invalid scenario to fail validation")
+	}
+}
+
+func TestRunCollectsEveryPoint(t *testing.T) {
+	spec := Spec{Scenario: baseScenario(), Workers: 3, Mode: ModeList, Axes: []Axis{
+		{Path: "workload.rps", Values: []interface{}{5.0, 20.0, 45.0, -1.0}},
+	}}
+	points, err := Points(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]Result, len(points))
+	Run(spec, points, 1, func(r Result) { rows[r.Index] = r })
+	for i, r := range rows[:3] {
+		if r.Summary == nil || r.Error != "" {
+			t.Fatalf("point %d failed: %+v", i, r)
+		}
+	}
+	if rows[3].Error == "" {
+		t.Fatal("expected negative rps to be rejected")
+	}
+	if rows[2].Summary.AvgQueueMS <= rows[0].Summary.AvgQueueMS {
+		t.Fatalf("queueing should grow with rps: %f vs %f", rows[0].Summary.AvgQueueMS, rows[2].Summary.AvgQueueMS)
+	}
+}
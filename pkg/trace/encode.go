@@ -0,0 +1,173 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Marshal returns the trace as Chrome trace JSON, one event per line.
+func (t *Trace) Marshal() ([]byte, error) {
+	if len(t.Events) == 0 {
+		return []byte(`{"traceEvents":[]}`), nil
+	}
+	b := append([]byte(nil), `{"traceEvents":[`...)
+	var err error
+	for i := range t.Events {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, '\n')
+		if b, err = appendEvent(b, &t.Events[i]); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, "\n]}"...), nil
+}
+
+// MarshalJSON encodes the event in the trace event format.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return appendEvent(nil, &e)
+}
+
+// appendEvent writes ev with pid 1, the scope of instant events, the id of
+// async events and the flow binding of complete events. Zero cat, dur and
+// tid are left out.
+func appendEvent(b []byte, ev *Event) ([]byte, error) {
+	var err error
+	b = append(b, `{"name":`...)
+	b = appendString(b, ev.Name)
+	if ev.Cat != "" {
+		b = append(b, `,"cat":`...)
+		b = appendString(b, ev.Cat)
+	}
+	b = append(b, `,"ph":"`...)
+	b = append(b, byte(ev.Ph), '"')
+	b = append(b, `,"ts":`...)
+	if b, err = appendFloat(b, ev.Ts); err != nil {
+		return nil, err
+	}
+	if ev.Dur != 0 {
+		b = append(b, `,"dur":`...)
+		if b, err = appendFloat(b, ev.Dur); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, `,"pid":1`...)
+	if ev.Tid != 0 {
+		b = append(b, `,"tid":`...)
+		b = strconv.AppendInt(b, int64(ev.Tid), 10)
+	}
+	switch {
+	case ev.Ph == 'i':
+		b = append(b, `,"s":"t"`...)
+	case ev.Ph == 'b' || ev.Ph == 'e':
+		b = append(b, `,"id":`...)
+		b = strconv.AppendInt(b, int64(ev.ID), 10)
+	case ev.Ph == 'X' && ev.Flow != 0:
+		b = append(b, `,"bind_id":`...)
+		b = strconv.AppendInt(b, int64(ev.ID), 10)
+		if ev.Flow&FlowIn != 0 {
+			b = append(b, `,"flow_in":true`...)
+		}
+		if ev.Flow&FlowOut != 0 {
+			b = append(b, `,"flow_out":true`...)
+		}
+	}
+	if ev.Args != nil {
+		b = append(b, `,"args":`...)
+		switch args := ev.Args.(type) {
+		case *RequestArgs:
+			b = args.appendJSON(b)
+			b = append(b, '}')
+		case *SpanArgs:
+			if b, err = args.appendJSON(b); err != nil {
+				return nil, err
+			}
+		default:
+			raw, err := json.Marshal(ev.Args)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, raw...)
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendJSON writes the args as json.Marshal would, without the closing
+// brace so SpanArgs can extend them.
+func (a *RequestArgs) appendJSON(b []byte) []byte {
+	b = append(b, `{"request_id":`...)
+	b = strconv.AppendInt(b, int64(a.RequestID), 10)
+	if a.Class != "" {
+		b = append(b, `,"class":`...)
+		b = appendString(b, a.Class)
+	}
+	return b
+}
+
+// appendJSON writes the args as json.Marshal would.
+func (a *SpanArgs) appendJSON(b []byte) ([]byte, error) {
+	var err error
+	b = a.RequestArgs.appendJSON(b)
+	if a.Bytes != 0 {
+		b = append(b, `,"bytes":`...)
+		if b, err = appendFloat(b, a.Bytes); err != nil {
+			return nil, err
+		}
+	}
+	if a.Tokens != 0 {
+		b = append(b, `,"tokens":`...)
+		if b, err = appendFloat(b, a.Tokens); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// appendFloat formats f like encoding/json: plain notation except for
+// very small or very large magnitudes.
+func appendFloat(b []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("trace: unsupported value %v", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// e-07 -> e-7
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString writes s as a JSON string, escaping quotes, backslashes and
+// control characters.
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case c == '\t':
+			b = append(b, '\\', 't')
+		case c < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
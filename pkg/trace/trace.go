@@ -1,23 +1,127 @@
 package trace
 
-import (
-	"encoding/json"
+import "sort"
+
+// Phase is an event's "ph" field, e.g. 'X' for a complete event.
+type Phase byte
+
+// Flow marks how a complete event takes part in the flow named by its ID.
+type Flow uint8
+
+const (
+	FlowIn  Flow = 1 << iota // the flow arrives at this slice
+	FlowOut                  // the flow leaves from this slice
 )
 
-// Event represents a Chrome Trace event.
+// Event represents a Chrome Trace event. It is kept small because traced
+// runs hold millions of them; fields that are fixed by the phase, such as
+// pid, an instant's scope or a flow's binding, are only written by the
+// JSON encoding.
 type Event struct {
-	Name string  `json:"name"`
-	Cat  string  `json:"cat,omitempty"`
-	Ph   string  `json:"ph"`
-	Ts   float64 `json:"ts"`            // microseconds
-	Dur  float64 `json:"dur,omitempty"` // microseconds
-	Pid  int     `json:"pid,omitempty"`
-	Tid  int     `json:"tid,omitempty"`
+	Name string
+	Cat  string
+	Ts   float64 // microseconds
+	Dur  float64 // microseconds
+	Tid  int
+	ID   int32 // async span id, or the flow a complete event is bound to
+	Ph   Phase
+	Flow Flow
+
+	// Args are shown when the event is selected: a map, or a struct such
+	// as *RequestArgs or *SpanArgs that marshals to a JSON object.
+	Args interface{}
+}
+
+// RequestArgs are the args shared by all spans of a simulated request,
+// typed so emitting a span does not allocate a map.
+type RequestArgs struct {
+	RequestID int    `json:"request_id"`
+	Class     string `json:"class,omitempty"`
+}
+
+// SpanArgs extend a request's args with what one span moved or processed.
+// Zero amounts are left out.
+type SpanArgs struct {
+	*RequestArgs
+	Bytes  float64 `json:"bytes,omitempty"`
+	Tokens float64 `json:"tokens,omitempty"`
+}
+
+// Arg returns the arg named key, whichever form the event's args take.
+// Zero amounts of SpanArgs are absent, as in the JSON encoding.
+func (e *Event) Arg(key string) (interface{}, bool) {
+	switch args := e.Args.(type) {
+	case map[string]interface{}:
+		v, ok := args[key]
+		return v, ok
+	case *RequestArgs:
+		return args.arg(key)
+	case *SpanArgs:
+		switch key {
+		case "bytes":
+			return args.Bytes, args.Bytes != 0
+		case "tokens":
+			return args.Tokens, args.Tokens != 0
+		}
+		return args.RequestArgs.arg(key)
+	}
+	return nil, false
+}
+
+// ArgFloat returns the numeric arg named key.
+func (e *Event) ArgFloat(key string) (float64, bool) {
+	v, _ := e.Arg(key)
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// ArgString returns the string arg named key.
+func (e *Event) ArgString(key string) (string, bool) {
+	v, _ := e.Arg(key)
+	s, ok := v.(string)
+	return s, ok
+}
+
+// ArgMap returns the event's args keyed by name. Map args are returned as
+// is; typed args are copied into a new map.
+func (e *Event) ArgMap() map[string]interface{} {
+	if args, ok := e.Args.(map[string]interface{}); ok || e.Args == nil {
+		return args
+	}
+	out := map[string]interface{}{}
+	for _, key := range typedArgKeys {
+		if v, ok := e.Arg(key); ok {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// typedArgKeys are the keys RequestArgs and SpanArgs can hold.
+var typedArgKeys = []string{"request_id", "class", "bytes", "tokens"}
+
+func (a *RequestArgs) arg(key string) (interface{}, bool) {
+	switch key {
+	case "request_id":
+		return a.RequestID, true
+	case "class":
+		return a.Class, a.Class != ""
+	}
+	return nil, false
 }
 
 // Trace holds a list of events.
 type Trace struct {
 	Events []Event `json:"traceEvents"`
+
+	process   string
+	threads   map[int]string
+	finalized bool
 }
 
 // New creates an empty trace.
@@ -25,24 +129,150 @@ func New() Trace {
 	return Trace{Events: []Event{}}
 }
 
+// NewSized creates an empty trace with room for about n events, so long
+// runs do not repeatedly regrow the event slice.
+func NewSized(n int) Trace {
+	return Trace{Events: make([]Event, 0, n)}
+}
+
 // AddComplete adds a complete event given start/end in milliseconds.
 func (t *Trace) AddComplete(name, cat string, tid int, startMs, endMs float64) {
+	t.AddCompleteArgs(name, cat, tid, startMs, endMs, nil)
+}
+
+// AddCompleteArgs adds a complete event with args shown when the slice is
+// selected. The args are stored as is and may be shared between events.
+func (t *Trace) AddCompleteArgs(name, cat string, tid int, startMs, endMs float64, args interface{}) {
 	ev := Event{
 		Name: name,
 		Cat:  cat,
-		Ph:   "X",
+		Ph:   'X',
 		Ts:   startMs * 1000, // to microseconds
 		Dur:  (endMs - startMs) * 1000,
-		Pid:  1,
 		Tid:  tid,
+		Args: args,
 	}
 	t.Events = append(t.Events, ev)
 }
 
-// Finalize no-op placeholder to mirror interface for later enrichments.
-func (t *Trace) Finalize() {}
+// LinkFlow chains the complete events at the given indices of Events into
+// one flow with id, in order. The events are bound in place (flow events
+// v2), so a flow adds no events of its own. Fewer than two events link
+// nothing.
+func (t *Trace) LinkFlow(id int32, events []int) {
+	if len(events) < 2 {
+		return
+	}
+	for i, idx := range events {
+		ev := &t.Events[idx]
+		ev.ID = id
+		if i > 0 {
+			ev.Flow |= FlowIn
+		}
+		if i < len(events)-1 {
+			ev.Flow |= FlowOut
+		}
+	}
+}
+
+// AddAsyncBegin opens a nestable async span identified by cat and id.
+// Async spans are not tied to a thread, so they may overlap freely; spans
+// opened with the same cat and id nest.
+func (t *Trace) AddAsyncBegin(name, cat string, id int32, tsMs float64, args interface{}) {
+	t.Events = append(t.Events, Event{
+		Name: name,
+		Cat:  cat,
+		Ph:   'b',
+		Ts:   tsMs * 1000,
+		ID:   id,
+		Args: args,
+	})
+}
+
+// AddAsyncEnd closes the innermost async span opened with the same name,
+// cat and id.
+func (t *Trace) AddAsyncEnd(name, cat string, id int32, tsMs float64) {
+	t.Events = append(t.Events, Event{
+		Name: name,
+		Cat:  cat,
+		Ph:   'e',
+		Ts:   tsMs * 1000,
+		ID:   id,
+	})
+}
+
+// AddInstant adds a thread-scoped instant event at tsMs.
+func (t *Trace) AddInstant(name, cat string, tid int, tsMs float64) {
+	t.Events = append(t.Events, Event{
+		Name: name,
+		Cat:  cat,
+		Ph:   'i',
+		Ts:   tsMs * 1000,
+		Tid:  tid,
+	})
+}
 
-// Marshal returns JSON bytes.
-func (t *Trace) Marshal() ([]byte, error) {
-	return json.MarshalIndent(t, "", "  ")
+// AddCounter adds a counter sample at tsMs; each key in values becomes a
+// series of the named counter track.
+func (t *Trace) AddCounter(name string, tsMs float64, values map[string]float64) {
+	args := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		args[k] = v
+	}
+	t.Events = append(t.Events, Event{
+		Name: name,
+		Ph:   'C',
+		Ts:   tsMs * 1000,
+		Args: args,
+	})
+}
+
+// SetProcessName names the process all events belong to.
+func (t *Trace) SetProcessName(name string) {
+	t.process = name
+}
+
+// SetThreadName names the lane of tid. Names of lanes that end up without
+// events are left out by Finalize.
+func (t *Trace) SetThreadName(tid int, name string) {
+	if t.threads == nil {
+		t.threads = map[int]string{}
+	}
+	t.threads[tid] = name
+}
+
+// Finalize appends metadata events naming the process and every named
+// thread that has events, with threads sorted by tid. An empty trace stays
+// empty, and calling it again is a no-op.
+func (t *Trace) Finalize() {
+	if t.finalized || len(t.Events) == 0 {
+		return
+	}
+	t.finalized = true
+	if t.process != "" {
+		t.Events = append(t.Events, Event{
+			Name: "process_name",
+			Ph:   'M',
+			Args: map[string]interface{}{"name": t.process},
+		})
+	}
+	used := map[int]bool{}
+	for _, ev := range t.Events {
+		if ev.Ph != 'M' && ev.Ph != 'C' {
+			used[ev.Tid] = true
+		}
+	}
+	tids := make([]int, 0, len(used))
+	for tid := range used {
+		if _, ok := t.threads[tid]; ok {
+			tids = append(tids, tid)
+		}
+	}
+	sort.Ints(tids)
+	for _, tid := range tids {
+		t.Events = append(t.Events,
+			Event{Name: "thread_name", Ph: 'M', Tid: tid, Args: map[string]interface{}{"name": t.threads[tid]}},
+			Event{Name: "thread_sort_index", Ph: 'M', Tid: tid, Args: map[string]interface{}{"sort_index": tid}},
+		)
+	}
 }
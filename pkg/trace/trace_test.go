@@ -0,0 +1,162 @@
+package trace
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFinalizeNamesUsedLanesOnce(t *testing.T) {
+	var empty Trace
+	empty.SetProcessName("p")
+	empty.Finalize()
+	if len(empty.Events) != 0 {
+		t.Fatalf("an empty trace should stay empty, got %d events", len(empty.Events))
+	}
+
+	tr := New()
+	tr.SetProcessName("sim")
+	tr.SetThreadName(3, "compute")
+	tr.SetThreadName(1, "cpu")
+	tr.SetThreadName(7, "unused")
+	tr.AddCompleteArgs("prefill", "compute", 3, 1, 2, &RequestArgs{RequestID: 4})
+	tr.AddComplete("pre", "cpu", 1, 0, 1)
+	tr.AddCounter("queue", 0, map[string]float64{"depth": 1})
+	tr.Finalize()
+	tr.Finalize()
+
+	var names []string
+	var tids []int
+	for _, ev := range tr.Events {
+		if ev.Ph != 'M' {
+			continue
+		}
+		names = append(names, ev.Name)
+		tids = append(tids, ev.Tid)
+	}
+	want := []string{"process_name", "thread_name", "thread_sort_index", "thread_name", "thread_sort_index"}
+	if len(names) != len(want) {
+		t.Fatalf("metadata %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("metadata %v, want %v", names, want)
+		}
+	}
+	if tids[1] != 1 || tids[3] != 3 {
+		t.Fatalf("thread metadata should be sorted by tid, got %v", tids)
+	}
+	if args, ok := tr.Events[0].Args.(*RequestArgs); !ok || args.RequestID != 4 {
+		t.Fatalf("args not attached: %+v", tr.Events[0])
+	}
+}
+
+func TestLinkFlowBindsSlices(t *testing.T) {
+	tr := New()
+	for i := 0; i < 3; i++ {
+		tr.AddComplete("stage", "cpu", i+1, float64(i), float64(i+1))
+	}
+	tr.LinkFlow(9, []int{0})
+	if tr.Events[0].Flow != 0 {
+		t.Fatalf("a single slice should not start a flow")
+	}
+	tr.LinkFlow(9, []int{0, 1, 2})
+	want := []Flow{FlowOut, FlowIn | FlowOut, FlowIn}
+	for i, ev := range tr.Events {
+		if ev.ID != 9 || ev.Flow != want[i] {
+			t.Fatalf("slice %d: %+v should be bound to flow 9 with %v", i, ev, want[i])
+		}
+	}
+	if len(tr.Events) != 3 {
+		t.Fatalf("linking should add no events, got %d", len(tr.Events))
+	}
+}
+
+func TestAsyncSpansShareID(t *testing.T) {
+	tr := New()
+	tr.AddAsyncBegin("request", "request", 12, 1, &RequestArgs{RequestID: 12, Class: "chat"})
+	tr.AddAsyncEnd("request", "request", 12, 4)
+	b, e := tr.Events[0], tr.Events[1]
+	if b.Ph != 'b' || e.Ph != 'e' || b.ID != 12 || e.ID != 12 || b.Cat != e.Cat {
+		t.Fatalf("unexpected async events %+v %+v", b, e)
+	}
+	if b.Ts != 1000 || e.Ts != 4000 || b.Args.(*RequestArgs).Class != "chat" {
+		t.Fatalf("unexpected async timing or args %+v %+v", b, e)
+	}
+}
+
+func TestArgAccessorsReadMapAndTypedArgs(t *testing.T) {
+	tr := New()
+	req := &RequestArgs{RequestID: 4, Class: "chat"}
+	tr.AddCompleteArgs("prefill", "compute", 3, 1, 2, &SpanArgs{RequestArgs: req, Tokens: 20})
+	tr.AddCounter("queue_depth", 1, map[string]float64{"requests": 2})
+	span, counter := tr.Events[0], tr.Events[1]
+	if id, ok := span.ArgFloat("request_id"); !ok || id != 4 {
+		t.Fatalf("request_id %v %v", id, ok)
+	}
+	if class, _ := span.ArgString("class"); class != "chat" {
+		t.Fatalf("class %q", class)
+	}
+	if _, ok := span.Arg("bytes"); ok {
+		t.Fatal("zero bytes should be absent")
+	}
+	if m := span.ArgMap(); len(m) != 3 || m["tokens"] != 20.0 {
+		t.Fatalf("span args %v", m)
+	}
+	if v, ok := counter.ArgFloat("requests"); !ok || v != 2 {
+		t.Fatalf("counter value %v %v", v, ok)
+	}
+	if _, ok := counter.ArgString("requests"); ok {
+		t.Fatal("a number is not a string")
+	}
+}
+
+func TestMarshalWritesTraceEventFormat(t *testing.T) {
+	tr := New()
+	req := &RequestArgs{RequestID: 4, Class: "chat"}
+	tr.AddCompleteArgs("pre\"fill", "compute", 3, 1, 2.5, &SpanArgs{RequestArgs: req, Tokens: 20})
+	tr.AddCompleteArgs("d2h", "d2h", 2, 2.5, 3, &SpanArgs{RequestArgs: &RequestArgs{RequestID: 4}, Bytes: 1 << 20})
+	tr.AddComplete("post", "cpu", 1, 3, 4)
+	tr.Events[len(tr.Events)-1].Args = req
+	tr.LinkFlow(4, []int{0, 1})
+	tr.AddAsyncBegin("request", "request", 0, 0.5, nil)
+	tr.AddInstant("fault", "fault", 5, 0)
+	tr.Events[len(tr.Events)-1].Ts = 1e-7 // exponent notation
+	tr.AddCounter("queue_depth", 1, map[string]float64{"requests": 2})
+	raw, err := tr.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		TraceEvents []map[string]interface{} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, raw)
+	}
+	want := []string{
+		`{"args":{"class":"chat","request_id":4,"tokens":20},"bind_id":4,"cat":"compute","dur":1500,"flow_out":true,"name":"pre\"fill","ph":"X","pid":1,"tid":3,"ts":1000}`,
+		`{"args":{"bytes":1048576,"request_id":4},"bind_id":4,"cat":"d2h","dur":500,"flow_in":true,"name":"d2h","ph":"X","pid":1,"tid":2,"ts":2500}`,
+		`{"args":{"class":"chat","request_id":4},"cat":"cpu","dur":1000,"name":"post","ph":"X","pid":1,"tid":1,"ts":3000}`,
+		`{"cat":"request","id":0,"name":"request","ph":"b","pid":1,"ts":500}`,
+		`{"cat":"fault","name":"fault","ph":"i","pid":1,"s":"t","tid":5,"ts":1e-7}`,
+		`{"args":{"requests":2},"name":"queue_depth","ph":"C","pid":1,"ts":1000}`,
+	}
+	if len(doc.TraceEvents) != len(want) {
+		t.Fatalf("expected %d events, got %s", len(want), raw)
+	}
+	for i, ev := range doc.TraceEvents {
+		got, _ := json.Marshal(ev)
+		if string(got) != want[i] {
+			t.Fatalf("event %d:\n got %s\nwant %s", i, got, want[i])
+		}
+	}
+	for _, args := range []interface{}{tr.Events[0].Args, tr.Events[1].Args, req} {
+		fast, err := appendEvent(nil, &Event{Name: "x", Ph: 'X', Args: args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, _ := json.Marshal(args)
+		if want := `{"name":"x","ph":"X","ts":0,"pid":1,"args":` + string(plain) + `}`; string(fast) != want {
+			t.Fatalf("typed args should encode like encoding/json:\n got %s\nwant %s", fast, want)
+		}
+	}
+}
@@ -13,7 +13,7 @@ import { saveScenarioEntry, loadScenario, deleteScenario, loadIndex, loadLastSce
 import AboutAurix from './components/AboutAurix'
 import HeaderBar from './components/HeaderBar'
 import Footer from './components/Footer'
-import { computeDiagnosticsFromTrace } from './utils/diagnostics'
+import { fromServerDiagnostics } from './utils/diagnostics'
 import Sweeps from './components/Sweeps.tsx'
 
 const API = '' // proxied to 8080 via Vite config
@@ -75,12 +75,11 @@ export default function App() {
       return
     }
     let cancelled = false
-    fetch(`${API}/v1/runs/${run.id}/trace`)
-      .then((r) => r.json())
+    fetch(`${API}/v1/runs/${run.id}/diagnostics`)
+      .then((r) => (r.ok ? r.json() : null))
       .then((json) => {
         if (cancelled) return
-        const diag = computeDiagnosticsFromTrace(json)
-        setDiagnostics(diag)
+        setDiagnostics(json ? fromServerDiagnostics(json) : null)
       })
       .catch(() => !cancelled && setDiagnostics(null))
     return () => { cancelled = true }
@@ -146,7 +145,7 @@ export default function App() {
       const summary = data.summary
       const breakdown = data.breakdown || (await (await fetch(`${API}/v1/runs/${runId}/breakdown`)).json())
       const tracePath = data.artifacts?.trace
-      const newRun = { id: runId, summary, trace: tracePath, breakdown, scenario: sc }
+      const newRun = { id: runId, summary, trace: tracePath, breakdown, estimate: data.estimate, scenario: sc }
       setRun(newRun)
       addRun(newRun)
       setActiveTab('results')
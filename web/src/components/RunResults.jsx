@@ -41,6 +41,7 @@ export default function RunResults({ run, loading, error, onOpenTimeline, diagno
       </div>
 
       <ResultCards summary={run?.summary} runId={run?.id} trace={run?.trace} onOpenTimeline={onOpenTimeline} />
+      <EstimateCard estimate={run?.estimate} summary={run?.summary} />
 
       {whatIfRun && (
         <Card className="p-3 space-y-2">
@@ -82,6 +83,39 @@ function SummaryLines({ summary, label }) {
   )
 }
 
+function EstimateCard({ estimate, summary }) {
+  if (!estimate?.bottleneck) return null
+  const b = estimate.bottleneck
+  return (
+    <Card className="p-3 space-y-2 text-sm">
+      <div className="text-slate-200 font-semibold">Analytic estimate</div>
+      <div className="text-slate-400 text-xs">
+        Bottleneck {b.name} ({b.servers} servers, {fmt(b.service_ms)} ms/request) at {fmt(b.utilization * 100)}% utilization
+      </div>
+      {!estimate.stable && <div className="text-red-400 text-xs">{estimate.warning}</div>}
+      {estimate.models?.length > 0 && (
+        <table className="w-full text-xs text-slate-300">
+          <thead>
+            <tr className="text-slate-400 text-left">
+              <th>Model</th><th>Mean wait (ms)</th><th>Mean latency (ms)</th><th>p99 latency (ms)</th>
+            </tr>
+          </thead>
+          <tbody>
+            {estimate.models.map((m) => (
+              <tr key={m.model}>
+                <td>{m.model}</td><td>{fmt(m.mean_wait_ms)}</td><td>{fmt(m.mean_latency_ms)}</td><td>{fmt(m.p99_latency_ms)}</td>
+              </tr>
+            ))}
+            <tr className="text-emerald-300">
+              <td>Simulated</td><td>{fmt(summary?.avg_queue_ms)}</td><td>—</td><td>{fmt(summary?.p99_ms)}</td>
+            </tr>
+          </tbody>
+        </table>
+      )}
+    </Card>
+  )
+}
+
 function fmt(v) {
   if (v === undefined || v === null || Number.isNaN(v)) return '—'
   return typeof v === 'number' ? v.toFixed(2) : v
@@ -105,7 +139,7 @@ async function duplicateAndRun(run, profile, ctx) {
     const summary = data.summary
     const breakdown = data.breakdown || (await (await fetch(`${backendUrl}/v1/runs/${runId}/breakdown`)).json())
     const tracePath = data.artifacts?.trace
-    const newRun = { id: runId, summary, trace: tracePath, breakdown, scenario }
+    const newRun = { id: runId, summary, trace: tracePath, breakdown, estimate: data.estimate, scenario }
     setWhatIfRun(newRun)
     addRun(newRun)
     setRun(newRun)
@@ -6,7 +6,7 @@ export default function StageAggregates({ aggregates }) {
       {aggregates.map((a, i) => (
         <div key={i} className="flex justify-between bg-slate-800/50 border border-slate-700 rounded px-2 py-1">
           <span className="text-slate-200">{a.name} ({a.category})</span>
-          <span className="text-slate-400">avg {a.avg_ms.toFixed(2)} ms • total {a.total_ms.toFixed(1)} ms</span>
+          <span className="text-slate-400">avg {a.avg_ms.toFixed(2)} • p50 {(a.p50_ms ?? 0).toFixed(2)} • p99 {(a.p99_ms ?? 0).toFixed(2)} • max {(a.max_ms ?? 0).toFixed(2)} ms • total {a.total_ms.toFixed(1)} ms</span>
         </div>
       ))}
     </div>
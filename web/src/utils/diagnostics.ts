@@ -11,6 +11,21 @@ export type Diagnostics = {
   saturation: { type: 'gpu' | 'queue'; start: number; end: number }[]
 }
 
+// fromServerDiagnostics adapts GET /v1/runs/{id}/diagnostics to the shape
+// the timeline and diagnosis card expect.
+export function fromServerDiagnostics(d: any): Diagnostics {
+  return {
+    primary: d.primary,
+    evidence: d.evidence || [],
+    shares: d.shares,
+    gpuBusy: d.gpu_busy_percent,
+    transferBusy: d.transfer_busy_percent,
+    heat: d.heat || {},
+    binWidth: d.bin_width_ms,
+    saturation: (d.saturation || []).map((w: any) => ({ type: w.resource, start: w.start_ms, end: w.end_ms })),
+  }
+}
+
 export function computeDiagnosticsFromTrace(json: any): Diagnostics | null {
   const spans = parseTraceToSpans(json)
   if (!spans.length) return null